@@ -0,0 +1,219 @@
+// Package apperr is the error model shared by every handler. Handlers return
+// an *Error and ErrorHandler turns it into the one error body the API uses:
+//
+//	{"code": "not_found", "error": "Order not found"}
+//
+// Validation failures add the offending fields:
+//
+//	{"code": "validation_failed", "error": "Validation failed",
+//	 "fields": [{"field": "email", "message": "is required"}]}
+//
+// The code is the stable, machine-readable part; the message is for people
+// and may change. Every code always comes with the same HTTP status:
+//
+//	bad_request           400  malformed body, query or path parameter
+//	validation_failed     400  well-formed input that breaks a rule; see fields
+//	invalid_token         400  reset or activation token is unknown, used or expired
+//	insufficient_stock    400  not enough quantity on hand for a sale or order
+//	insufficient_payment  400  amount received is less than the total
+//	invalid_state         400  the record is not in a state that allows this, e.g. an order that is no longer pending
+//	unauthorized          401  missing, invalid or revoked access token
+//	invalid_credentials   401  wrong email or password
+//	session_expired       401  refresh token is unknown, revoked or expired
+//	forbidden             403  signed in but not allowed to do this
+//	account_inactive      403  correct password, but the account is disabled or not activated
+//	not_found             404  the record or route does not exist
+//	method_not_allowed    405  the route exists but not for this method
+//	conflict              409  clashes with existing data, e.g. a duplicate or the last admin
+//	email_taken           409  the email already belongs to a user or supplier
+//	no_open_shift         409  the cashier must open a shift first
+//	payload_too_large     413  request body is over the size limit
+//	login_throttled       429  too many failed logins; see the Retry-After header
+//	internal_error        500  unexpected failure; details are only logged
+//	mail_failed           502  the mail server did not accept an email
+//	service_unavailable   503  a dependency such as the database is down
+//
+// Codes are part of the API contract: add new ones freely, but never rename
+// or reuse an existing code.
+package apperr
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/logging"
+)
+
+type Code string
+
+const (
+	CodeBadRequest          Code = "bad_request"
+	CodeValidation          Code = "validation_failed"
+	CodeInvalidToken        Code = "invalid_token"
+	CodeInsufficientStock   Code = "insufficient_stock"
+	CodeInsufficientPayment Code = "insufficient_payment"
+	CodeInvalidState        Code = "invalid_state"
+	CodeUnauthorized        Code = "unauthorized"
+	CodeInvalidCredentials  Code = "invalid_credentials"
+	CodeSessionExpired      Code = "session_expired"
+	CodeForbidden           Code = "forbidden"
+	CodeAccountInactive     Code = "account_inactive"
+	CodeNotFound            Code = "not_found"
+	CodeMethodNotAllowed    Code = "method_not_allowed"
+	CodeConflict            Code = "conflict"
+	CodeEmailTaken          Code = "email_taken"
+	CodeNoOpenShift         Code = "no_open_shift"
+	CodePriceMismatch       Code = "price_mismatch"
+	CodePayloadTooLarge     Code = "payload_too_large"
+	CodeLoginThrottled      Code = "login_throttled"
+	CodeInternal            Code = "internal_error"
+	CodeMailFailed          Code = "mail_failed"
+	CodeUnavailable         Code = "service_unavailable"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:          fiber.StatusBadRequest,
+	CodeValidation:          fiber.StatusBadRequest,
+	CodeInvalidToken:        fiber.StatusBadRequest,
+	CodeInsufficientStock:   fiber.StatusBadRequest,
+	CodeInsufficientPayment: fiber.StatusBadRequest,
+	CodeInvalidState:        fiber.StatusBadRequest,
+	CodeUnauthorized:        fiber.StatusUnauthorized,
+	CodeInvalidCredentials:  fiber.StatusUnauthorized,
+	CodeSessionExpired:      fiber.StatusUnauthorized,
+	CodeForbidden:           fiber.StatusForbidden,
+	CodeAccountInactive:     fiber.StatusForbidden,
+	CodeNotFound:            fiber.StatusNotFound,
+	CodeMethodNotAllowed:    fiber.StatusMethodNotAllowed,
+	CodeConflict:            fiber.StatusConflict,
+	CodeEmailTaken:          fiber.StatusConflict,
+	CodeNoOpenShift:         fiber.StatusConflict,
+	CodePriceMismatch:       fiber.StatusConflict,
+	CodePayloadTooLarge:     fiber.StatusRequestEntityTooLarge,
+	CodeLoginThrottled:      fiber.StatusTooManyRequests,
+	CodeInternal:            fiber.StatusInternalServerError,
+	CodeMailFailed:          fiber.StatusBadGateway,
+	CodeUnavailable:         fiber.StatusServiceUnavailable,
+}
+
+// FieldError explains why one input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an API error. Err keeps the underlying cause for the server log;
+// it is never sent to the client.
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status is the HTTP status that goes with the error's code.
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return fiber.StatusInternalServerError
+}
+
+// WithFields attaches field details to the error.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap is New with the cause that led to the error.
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func BadRequest(message string) *Error   { return New(CodeBadRequest, message) }
+func Unauthorized(message string) *Error { return New(CodeUnauthorized, message) }
+func Forbidden(message string) *Error    { return New(CodeForbidden, message) }
+func NotFound(message string) *Error     { return New(CodeNotFound, message) }
+func Conflict(message string) *Error     { return New(CodeConflict, message) }
+
+// Validation reports input that broke one or more rules.
+func Validation(fields ...FieldError) *Error {
+	return New(CodeValidation, "Validation failed").WithFields(fields...)
+}
+
+// Internal reports an unexpected failure. message is shown to the client and
+// err is only logged.
+func Internal(message string, err error) *Error {
+	return Wrap(err, CodeInternal, message)
+}
+
+// fiberCodes maps the statuses Fiber itself raises, e.g. for unknown routes,
+// to codes.
+var fiberCodes = map[int]Code{
+	fiber.StatusBadRequest:            CodeBadRequest,
+	fiber.StatusUnauthorized:          CodeUnauthorized,
+	fiber.StatusForbidden:             CodeForbidden,
+	fiber.StatusNotFound:              CodeNotFound,
+	fiber.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	fiber.StatusConflict:              CodeConflict,
+	fiber.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	fiber.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+type body struct {
+	Code   Code         `json:"code"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// ErrorHandler is the fiber.Config.ErrorHandler: it writes every error a
+// handler or middleware returns in the shared error body.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var appErr *Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &appErr):
+	case errors.As(err, &fiberErr):
+		code, ok := fiberCodes[fiberErr.Code]
+		if !ok {
+			code = CodeInternal
+			if fiberErr.Code < fiber.StatusInternalServerError {
+				code = CodeBadRequest
+			}
+		}
+		appErr = New(code, fiberErr.Message)
+	default:
+		appErr = Internal("Internal server error", err)
+	}
+
+	status := appErr.Status()
+	if status >= fiber.StatusInternalServerError {
+		logging.From(c.UserContext()).Error("request failed",
+			"method", c.Method(),
+			"path", c.Path(),
+			"code", appErr.Code,
+			"error", appErr,
+		)
+	}
+
+	return c.Status(status).JSON(body{
+		Code:   appErr.Code,
+		Error:  appErr.Message,
+		Fields: appErr.Fields,
+	})
+}
@@ -0,0 +1,87 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func respond(t *testing.T, handler fiber.Handler) (int, body) {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/", handler)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got body
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("response is not the error body: %s", raw)
+	}
+	return resp.StatusCode, got
+}
+
+func TestErrorHandlerWritesAppErrors(t *testing.T) {
+	status, got := respond(t, func(c *fiber.Ctx) error {
+		return Validation(FieldError{Field: "email", Message: "is required"})
+	})
+
+	if status != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+	if got.Code != CodeValidation || got.Error != "Validation failed" {
+		t.Errorf("body = %+v", got)
+	}
+	if len(got.Fields) != 1 || got.Fields[0].Field != "email" {
+		t.Errorf("fields = %+v", got.Fields)
+	}
+}
+
+func TestErrorHandlerHidesInternalCauses(t *testing.T) {
+	status, got := respond(t, func(c *fiber.Ctx) error {
+		return errors.New("pq: relation \"orders\" does not exist")
+	})
+
+	if status != fiber.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", status, fiber.StatusInternalServerError)
+	}
+	if got.Code != CodeInternal || got.Error != "Internal server error" {
+		t.Errorf("body = %+v", got)
+	}
+}
+
+func TestErrorHandlerMapsFiberErrors(t *testing.T) {
+	status, got := respond(t, func(c *fiber.Ctx) error {
+		return fiber.ErrNotFound
+	})
+
+	if status != fiber.StatusNotFound || got.Code != CodeNotFound {
+		t.Errorf("status = %d, body = %+v", status, got)
+	}
+}
+
+func TestEveryCodeHasAStatus(t *testing.T) {
+	for _, code := range []Code{
+		CodeBadRequest, CodeValidation, CodeInvalidToken, CodeInsufficientStock,
+		CodeInsufficientPayment, CodeInvalidState, CodeUnauthorized, CodeInvalidCredentials,
+		CodeSessionExpired, CodeForbidden, CodeAccountInactive, CodeNotFound,
+		CodeMethodNotAllowed, CodeConflict, CodeEmailTaken, CodeNoOpenShift,
+		CodePriceMismatch, CodePayloadTooLarge, CodeLoginThrottled, CodeInternal, CodeMailFailed, CodeUnavailable,
+	} {
+		if _, ok := statuses[code]; !ok {
+			t.Errorf("%s has no HTTP status", code)
+		}
+	}
+}
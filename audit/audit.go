@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// ignoredFields never count as a change on their own.
+var ignoredFields = map[string]bool{
+	"UpdatedAt":  true,
+	"updated_at": true,
+}
+
+// Actor is who made a change and from where.
+type Actor struct {
+	ID   uint
+	Name string
+	Role string
+	Type string
+	IP   string
+}
+
+// ActorOf is the authenticated caller of c. Requests without a token, such
+// as a password reset, get an actor with only the IP set.
+func ActorOf(c *fiber.Ctx) Actor {
+	actor := Actor{IP: c.IP()}
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return actor
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return actor
+	}
+
+	id, _ := claims["id"].(float64)
+	actor.ID = uint(id)
+	actor.Name, _ = claims["user_name"].(string)
+	actor.Role, _ = claims["role"].(string)
+	actor.Type = models.SubjectUser
+	if actor.Role == "supplier" {
+		actor.Type = models.SubjectSupplier
+	}
+	return actor
+}
+
+// RecordTx writes an audit entry inside the transaction that makes the
+// change, so the two commit or roll back together: an error here must fail
+// the change. before is nil for creates and after is nil for deletes.
+func RecordTx(tx *gorm.DB, actor Actor, action, entity string, entityID interface{}, before, after interface{}) error {
+	entry := models.AuditLog{
+		ActorID:   actor.ID,
+		ActorName: actor.Name,
+		ActorRole: actor.Role,
+		ActorType: actor.Type,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  fmt.Sprint(entityID),
+		Changes:   "null",
+		IP:        actor.IP,
+	}
+
+	beforeDoc, beforeJSON := document(before)
+	afterDoc, afterJSON := document(after)
+	entry.Before = beforeJSON
+	entry.After = afterJSON
+
+	if changes := diff(beforeDoc, afterDoc); len(changes) > 0 {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return fmt.Errorf("encoding audit changes: %w", err)
+		}
+		entry.Changes = string(b)
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// document converts v to a generic JSON object and its encoded form. The
+// encoded form is "null" when there is nothing to store.
+func document(v interface{}) (map[string]interface{}, string) {
+	if v == nil {
+		return nil, "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, "null"
+	}
+	var doc map[string]interface{}
+	if json.Unmarshal(b, &doc) != nil {
+		return nil, string(b)
+	}
+	return doc, string(b)
+}
+
+type change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// diff lists the top-level fields that differ between before and after.
+func diff(before, after map[string]interface{}) map[string]change {
+	changes := map[string]change{}
+	for key, to := range after {
+		if ignoredFields[key] {
+			continue
+		}
+		if from, ok := before[key]; !ok || !reflect.DeepEqual(from, to) {
+			changes[key] = change{From: from, To: to}
+		}
+	}
+	for key, from := range before {
+		if _, ok := after[key]; !ok && !ignoredFields[key] {
+			changes[key] = change{From: from, To: nil}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+// CreateAdmin adds an admin account. It is how the first admin is created
+// now that accounts can only be added by other admins.
+func CreateAdmin(db *gorm.DB, username, email, password string) error {
+	email = strings.TrimSpace(email)
+	if username == "" || email == "" {
+		return errors.New("username and email are required")
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	if len(password) > utils.MaxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", utils.MaxPasswordBytes)
+	}
+
+	// Users and suppliers share the login form, so the email must be free in both
+	if err := services.CheckEmailAvailable(db, email, 0); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	admin := models.User{
+		UserName: username,
+		Email:    email,
+		Password: hash,
+		Role:     "admin",
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Created admin %q (id %d)", admin.UserName, admin.ID)
+	return nil
+}
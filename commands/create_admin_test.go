@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/testutil"
+)
+
+func TestCreateAdminRefusesATakenEmail(t *testing.T) {
+	db := testutil.NewDB(t)
+	if err := db.Create(&models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateAdmin(db, "root", " albay@example.com ", "long-enough"); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("supplier's email: err = %v, want email taken", err)
+	}
+	if err := CreateAdmin(db, "root", "root@example.com", "long-enough"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateAdmin(db, "root2", "root@example.com", "long-enough"); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("admin's email: err = %v, want email taken", err)
+	}
+
+	var admins int64
+	db.Model(&models.User{}).Where("role = ?", "admin").Count(&admins)
+	if admins != 1 {
+		t.Errorf("admins = %d, want 1", admins)
+	}
+}
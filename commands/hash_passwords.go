@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/m/models"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+// HashPasswords replaces every plaintext password left in the users and
+// suppliers tables with a bcrypt hash. Accounts that log in are upgraded
+// automatically; this covers the ones that never do.
+func HashPasswords(db *gorm.DB) error {
+	var users []models.User
+	if err := db.Select("id", "password").Find(&users).Error; err != nil {
+		return err
+	}
+
+	userCount := 0
+	for _, user := range users {
+		if user.Password == "" || utils.IsPasswordHash(user.Password) {
+			continue
+		}
+		hash, err := utils.HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("password", hash).Error; err != nil {
+			return err
+		}
+		userCount++
+	}
+
+	var suppliers []models.Supplier
+	if err := db.Select("id", "password").Find(&suppliers).Error; err != nil {
+		return err
+	}
+
+	supplierCount := 0
+	for _, supplier := range suppliers {
+		if supplier.Password == "" || utils.IsPasswordHash(supplier.Password) {
+			continue
+		}
+		hash, err := utils.HashPassword(supplier.Password)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Supplier{}).Where("id = ?", supplier.ID).Update("password", hash).Error; err != nil {
+			return err
+		}
+		supplierCount++
+	}
+
+	log.Printf("Hashed %d user and %d supplier passwords", userCount, supplierCount)
+	return nil
+}
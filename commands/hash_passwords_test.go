@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"github.com/m/utils"
+)
+
+func TestHashPasswordsIsSafeToRunTwice(t *testing.T) {
+	db := testutil.NewDB(t)
+	hashed, err := utils.HashPassword("already")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []models.User{
+		{UserName: "legacy", Email: "legacy@example.com", Password: "plain-user"},
+		{UserName: "modern", Email: "modern@example.com", Password: hashed},
+		{UserName: "invited", Email: "invited@example.com"},
+	}
+	supplier := models.Supplier{StoreName: "Legacy Store", Email: "store@example.com", Password: "plain-store"}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	passwords := func() map[string]string {
+		got := map[string]string{}
+		var rows []models.User
+		db.Order("id").Find(&rows)
+		for _, u := range rows {
+			got[u.Email] = u.Password
+		}
+		var s models.Supplier
+		db.First(&s, supplier.ID)
+		got[s.Email] = s.Password
+		return got
+	}
+
+	if err := HashPasswords(db); err != nil {
+		t.Fatal(err)
+	}
+	first := passwords()
+	for email, password := range map[string]string{"legacy@example.com": "plain-user", "store@example.com": "plain-store"} {
+		if ok, rehash := utils.VerifyPassword(first[email], password); !ok || rehash {
+			t.Errorf("%s: stored %q does not verify as a current hash", email, first[email])
+		}
+	}
+	if first["modern@example.com"] != hashed {
+		t.Error("an existing hash was rehashed")
+	}
+	if first["invited@example.com"] != "" {
+		t.Error("an account without a password was given one")
+	}
+
+	if err := HashPasswords(db); err != nil {
+		t.Fatal(err)
+	}
+	for email, password := range passwords() {
+		if password != first[email] {
+			t.Errorf("%s changed on the second run", email)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mrand "math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+// SeedEmailDomain marks every account the seed command creates. Rows are
+// recognised by it (and by belonging to those accounts) when a seed is
+// replaced, so real data in the same database is never touched.
+const SeedEmailDomain = "seed.otop.example"
+
+// SeedOptions sizes the demo dataset. The same options, Seed and Until
+// always produce the same rows.
+type SeedOptions struct {
+	Suppliers           int       // supplier stores, spread across provinces
+	ProductsPerSupplier int       // inventory and catalog products per store
+	Months              int       // months of POS history ending at Until
+	SalesPerDay         int       // average POS transactions per day
+	Orders              int       // restock orders, pending and verified
+	Seed                uint64    // random seed
+	Until               time.Time // last day of sales history
+	Password            string    // password for every seeded account; empty makes them unusable
+
+	// Allow seeds without APP_ENV, but only a database that holds nothing
+	// except an earlier seed
+	Allow bool
+}
+
+// DefaultSeedOptions is a dataset big enough for the dashboards to look
+// lived in while seeding in a few seconds.
+func DefaultSeedOptions() SeedOptions {
+	return SeedOptions{
+		Suppliers:           12,
+		ProductsPerSupplier: 6,
+		Months:              3,
+		SalesPerDay:         25,
+		Orders:              40,
+		Seed:                1,
+		Until:               time.Now().AddDate(0, 0, -1),
+	}
+}
+
+// Seed replaces the demo dataset in db. It only runs when env, APP_ENV, is
+// development or staging, or when env is unset and opts.Allow is given for a
+// database with no real data. Everything happens in one transaction, so a
+// failed run leaves the previous seed in place.
+func Seed(db *gorm.DB, env string, opts SeedOptions) error {
+	switch env {
+	case "development", "staging":
+	case "":
+		if !opts.Allow {
+			return errors.New("APP_ENV is not set, so this may be a production database; set APP_ENV=development or staging, or pass -allow to seed a database without real data")
+		}
+	default:
+		return fmt.Errorf("refusing to seed a database with APP_ENV=%s", env)
+	}
+	if opts.Suppliers < 1 || opts.ProductsPerSupplier < 1 || opts.Months < 1 || opts.SalesPerDay < 0 || opts.Orders < 0 {
+		return errors.New("suppliers, products and months must be at least 1; sales and orders cannot be negative")
+	}
+
+	password := opts.Password
+	if password == "" {
+		// Nobody knows this, so the seeded accounts cannot be logged into
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = hex.EncodeToString(buf)
+	} else if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s := &seeder{
+		opts: opts,
+		rnd:  mrand.New(mrand.NewPCG(opts.Seed, 0x07095eed)),
+		hash: hash,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		s.db = tx
+		if env == "" {
+			if n, err := realRows(tx); err != nil {
+				return err
+			} else if n > 0 {
+				return fmt.Errorf("refusing to seed without APP_ENV: the database has %d rows that are not seed data", n)
+			}
+		}
+		if err := clearSeed(tx); err != nil {
+			return fmt.Errorf("removing the previous seed: %w", err)
+		}
+		for _, step := range []struct {
+			name string
+			run  func() error
+		}{
+			{"staff", s.staff},
+			{"suppliers", s.suppliers},
+			{"products", s.products},
+			{"orders", s.orders},
+			{"sales", s.sales},
+		} {
+			if err := step.run(); err != nil {
+				return fmt.Errorf("seeding %s: %w", step.name, err)
+			}
+		}
+		log.Printf("Seeded %d suppliers, %d products, %d orders, %d shifts and %d transactions",
+			len(s.stores), len(s.stock), s.orderCount, s.shiftCount, s.txnCount)
+		return nil
+	})
+}
+
+// realRows counts accounts, stock, orders and sales that no seed created.
+func realRows(tx *gorm.DB) (int64, error) {
+	pattern := "%@" + SeedEmailDomain
+	suppliers := tx.Unscoped().Model(&models.Supplier{}).Select("id").Where("email LIKE ?", pattern)
+	users := tx.Unscoped().Model(&models.User{}).Select("id").Where("email LIKE ?", pattern)
+
+	var total int64
+	for _, query := range []*gorm.DB{
+		tx.Unscoped().Model(&models.User{}).Where("email NOT LIKE ?", pattern),
+		tx.Unscoped().Model(&models.Supplier{}).Where("email NOT LIKE ?", pattern),
+		tx.Unscoped().Model(&models.OtopProducts{}).Where("supplier_id NOT IN (?)", suppliers),
+		tx.Unscoped().Model(&models.Product{}).Where("supplier_id NOT IN (?)", suppliers),
+		tx.Unscoped().Model(&models.Order{}).Where("supplier_id NOT IN (?)", suppliers),
+		tx.Unscoped().Model(&models.Transaction{}).Where("cashier_id NOT IN (?)", users),
+	} {
+		var n int64
+		if err := query.Count(&n).Error; err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// clearSeed hard-deletes the previous seed, children first.
+func clearSeed(tx *gorm.DB) error {
+	pattern := "%@" + SeedEmailDomain
+	suppliers := tx.Unscoped().Model(&models.Supplier{}).Select("id").Where("email LIKE ?", pattern)
+	users := tx.Unscoped().Model(&models.User{}).Select("id").Where("email LIKE ?", pattern)
+	transactions := tx.Unscoped().Model(&models.Transaction{}).Select("id").Where("cashier_id IN (?)", users)
+
+	steps := []*gorm.DB{
+		tx.Unscoped().Where("supplier_id IN (?)", suppliers).Delete(&models.SoldItems{}),
+		tx.Where("transaction_id IN (?)", transactions).Delete(&models.TransactionItem{}),
+		tx.Where("transaction_id IN (?)", transactions).Delete(&models.TransactionSupplier{}),
+		tx.Where("transaction_id IN (?)", transactions).Delete(&models.Payment{}),
+		tx.Unscoped().Where("cashier_id IN (?)", users).Delete(&models.Transaction{}),
+		tx.Where("cashier_id IN (?)", users).Delete(&models.Shift{}),
+		tx.Unscoped().Where("supplier_id IN (?)", suppliers).Delete(&models.Order{}),
+		tx.Unscoped().Where("supplier_id IN (?)", suppliers).Delete(&models.OtopProducts{}),
+		tx.Unscoped().Where("supplier_id IN (?)", suppliers).Delete(&models.Product{}),
+		tx.Where("subject_type = ? AND subject_id IN (?)", models.SubjectUser, users).Delete(&models.Session{}),
+		tx.Where("subject_type = ? AND subject_id IN (?)", models.SubjectSupplier, suppliers).Delete(&models.Session{}),
+		tx.Where("subject_type = ? AND subject_id IN (?)", models.SubjectUser, users).Delete(&models.AccountToken{}),
+		tx.Where("subject_type = ? AND subject_id IN (?)", models.SubjectSupplier, suppliers).Delete(&models.AccountToken{}),
+	}
+	for _, step := range steps {
+		if step.Error != nil {
+			return step.Error
+		}
+	}
+	// Parents last, once nothing selects through them any more
+	if err := tx.Unscoped().Where("email LIKE ?", pattern).Delete(&models.Supplier{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("email LIKE ?", pattern).Delete(&models.User{}).Error
+}
+
+type seeder struct {
+	db   *gorm.DB
+	opts SeedOptions
+	rnd  *mrand.Rand
+	hash string
+
+	admin    models.User
+	cashiers []models.User
+	stores   []models.Supplier
+	catalog  []models.Product
+	stock    []models.OtopProducts
+
+	orderCount, shiftCount, txnCount int
+}
+
+var seedProvinces = []string{
+	"Ilocos Norte", "Pangasinan", "Benguet", "Pampanga", "Batangas", "Quezon",
+	"Albay", "Camarines Sur", "Iloilo", "Cebu", "Bohol", "Leyte",
+	"Bukidnon", "Davao del Sur", "South Cotabato", "Zamboanga del Sur",
+}
+
+var seedStoreNouns = []string{"Crafts", "Delicacies", "Weaves", "Farms", "Treasures", "Pasalubong"}
+
+type seedItem struct {
+	name, category string
+	price          float64
+}
+
+var seedItems = []seedItem{
+	{"Dried Mangoes", "Food", 165},
+	{"Ube Halaya", "Food", 220},
+	{"Barako Coffee", "Food", 280},
+	{"Chicharon", "Food", 95},
+	{"Pastillas de Leche", "Food", 120},
+	{"Muscovado Sugar", "Food", 140},
+	{"Bagoong", "Food", 150},
+	{"Tablea Chocolate", "Food", 180},
+	{"Piaya", "Food", 85},
+	{"Calamansi Juice", "Food", 75},
+	{"Abaca Bag", "Non-Food", 650},
+	{"Inabel Blanket", "Non-Food", 1450},
+	{"Rattan Basket", "Non-Food", 390},
+	{"Banig Mat", "Non-Food", 520},
+	{"Capiz Lamp", "Non-Food", 890},
+	{"Wooden Spoon Set", "Non-Food", 260},
+	{"Shell Necklace", "Non-Food", 180},
+	{"Pina Fabric Scarf", "Non-Food", 1200},
+}
+
+func (s *seeder) email(local string) string {
+	return local + "@" + SeedEmailDomain
+}
+
+func (s *seeder) staff() error {
+	s.admin = models.User{UserName: "seed_admin", Email: s.email("admin"), Password: s.hash, Role: "admin"}
+	if err := s.db.Create(&s.admin).Error; err != nil {
+		return err
+	}
+	for i := 1; i <= 2; i++ {
+		cashier := models.User{
+			UserName: fmt.Sprintf("seed_cashier%d", i),
+			Email:    s.email(fmt.Sprintf("cashier%d", i)),
+			Password: s.hash,
+			Role:     "cashier",
+		}
+		if err := s.db.Create(&cashier).Error; err != nil {
+			return err
+		}
+		s.cashiers = append(s.cashiers, cashier)
+	}
+	return nil
+}
+
+func (s *seeder) suppliers() error {
+	for i := 0; i < s.opts.Suppliers; i++ {
+		province := seedProvinces[i%len(seedProvinces)]
+		noun := seedStoreNouns[s.rnd.IntN(len(seedStoreNouns))]
+		store := models.Supplier{
+			StoreName:   fmt.Sprintf("%s %s %02d", province, noun, i+1),
+			Email:       s.email(fmt.Sprintf("supplier%02d", i+1)),
+			PhoneNumber: fmt.Sprintf("09%09d", s.rnd.IntN(1_000_000_000)),
+			Address:     fmt.Sprintf("Poblacion, %s", province),
+			Password:    s.hash,
+			Status:      models.SupplierActive,
+		}
+		// Leave a few invitations outstanding
+		if i%5 == 4 {
+			store.Status = models.SupplierPending
+		}
+		if err := s.db.Create(&store).Error; err != nil {
+			return err
+		}
+		s.stores = append(s.stores, store)
+	}
+	return nil
+}
+
+func (s *seeder) products() error {
+	for _, store := range s.stores {
+		for _, n := range s.rnd.Perm(len(seedItems))[:min(s.opts.ProductsPerSupplier, len(seedItems))] {
+			item := seedItems[n]
+			// Prices vary a little from store to store, in whole pesos
+			price := math.Round(item.price * (0.85 + 0.3*s.rnd.Float64()))
+			description := fmt.Sprintf("%s from %s", item.name, store.Address)
+
+			product := models.Product{
+				Name:        item.name,
+				Description: description,
+				Price:       price,
+				Quantity:    int64(20 + s.rnd.IntN(200)),
+				SupplierID:  store.ID,
+				Category:    item.category,
+			}
+			if err := s.db.Create(&product).Error; err != nil {
+				return err
+			}
+			s.catalog = append(s.catalog, product)
+
+			stock := models.OtopProducts{
+				Name:        item.name,
+				Description: description,
+				Price:       price,
+				Quantity:    int64(40 + s.rnd.IntN(300)),
+				Category:    item.category,
+				SupplierID:  store.ID,
+				StoreName:   store.StoreName,
+			}
+			if err := s.db.Create(&stock).Error; err != nil {
+				return err
+			}
+			s.stock = append(s.stock, stock)
+		}
+	}
+	return nil
+}
+
+func (s *seeder) start() time.Time {
+	until := s.opts.Until
+	last := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, until.Location())
+	return last.AddDate(0, -s.opts.Months, 1)
+}
+
+func (s *seeder) orders() error {
+	start := s.start()
+	days := int(s.opts.Until.Sub(start).Hours()/24) + 1
+	purchased := make(map[uint]int)
+
+	for i := 0; i < s.opts.Orders; i++ {
+		product := s.catalog[s.rnd.IntN(len(s.catalog))]
+		orderDate := start.AddDate(0, 0, s.rnd.IntN(days)).Add(time.Duration(8*60+s.rnd.IntN(9*60)) * time.Minute)
+		// Older orders have mostly been verified; recent ones are still pending
+		status := services.OrderVerified
+		if orderDate.After(s.opts.Until.AddDate(0, 0, -14)) || s.rnd.IntN(5) == 0 {
+			status = services.OrderPending
+		}
+		order := models.Order{
+			AdminID:     s.admin.ID,
+			SupplierID:  product.SupplierID,
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Quantity:    int64(10 + 5*s.rnd.IntN(10)),
+			Price:       product.Price,
+			OrderDate:   orderDate,
+			Status:      status,
+			Descriptiom: "Restock of " + product.Name,
+			CreatedAt:   orderDate,
+			UpdatedAt:   orderDate,
+		}
+		if err := s.db.Create(&order).Error; err != nil {
+			return err
+		}
+		if status == services.OrderVerified {
+			purchased[order.SupplierID]++
+		}
+		s.orderCount++
+	}
+
+	for _, store := range s.stores {
+		if err := s.db.Model(&models.Supplier{}).Where("id = ?", store.ID).Update("purchased", purchased[store.ID]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sales rings up SalesPerDay transactions a day, busier at weekends, split
+// across the cashiers' closed shifts. Every line is mirrored in sold_items,
+// as POS checkout does.
+func (s *seeder) sales() error {
+	for day := s.start(); !day.After(s.opts.Until); day = day.AddDate(0, 0, 1) {
+		count := s.opts.SalesPerDay
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			count = count * 3 / 2
+		}
+		if count > 0 {
+			count += s.rnd.IntN(count/3+1) - count/6
+		}
+
+		for c, cashier := range s.cashiers {
+			share := count / len(s.cashiers)
+			if c < count%len(s.cashiers) {
+				share++
+			}
+			if err := s.shift(day, cashier, share); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *seeder) shift(day time.Time, cashier models.User, sales int) error {
+	opened := day.Add(8 * time.Hour)
+	closed := day.Add(17 * time.Hour)
+	shift := models.Shift{
+		CashierID:   cashier.ID,
+		Status:      models.ShiftOpen,
+		OpenedAt:    opened,
+		OpeningCash: 2000,
+		CreatedAt:   opened,
+	}
+	if err := s.db.Create(&shift).Error; err != nil {
+		return err
+	}
+
+	for i := 0; i < sales; i++ {
+		at := opened.Add(time.Duration(s.rnd.IntN(9*3600)) * time.Second)
+		cash, err := s.transaction(at, cashier, shift.ID)
+		if err != nil {
+			return err
+		}
+		shift.CashSales += cash
+	}
+
+	shift.Status = models.ShiftClosed
+	shift.ClosedAt = &closed
+	shift.TransactionCount = int64(sales)
+	shift.ExpectedCash = shift.OpeningCash + shift.CashSales
+	shift.CountedCash = shift.ExpectedCash
+	// Now and then the drawer is a little off
+	if s.rnd.IntN(10) == 0 {
+		shift.CountedCash += float64(s.rnd.IntN(101) - 50)
+	}
+	shift.Variance = shift.CountedCash - shift.ExpectedCash
+	shift.UpdatedAt = closed
+	s.shiftCount++
+	return s.db.Save(&shift).Error
+}
+
+func (s *seeder) transaction(at time.Time, cashier models.User, shiftID uint) (float64, error) {
+	var items []models.TransactionItem
+	var suppliers []uint
+	var total, vat float64
+	for _, n := range s.rnd.Perm(len(s.stock))[:min(1+s.rnd.IntN(4), len(s.stock))] {
+		product := s.stock[n]
+		quantity := int64(1 + s.rnd.IntN(3))
+		line := float64(quantity) * product.Price
+		lineVAT := math.Round(line*services.VATRate/(1+services.VATRate)*100) / 100
+		items = append(items, models.TransactionItem{
+			ProductID:  product.ID,
+			Quantity:   quantity,
+			Price:      product.Price,
+			Total:      line,
+			VATClass:   models.VATVatable,
+			VAT:        lineVAT,
+			SupplierID: product.SupplierID,
+			CreatedAt:  at,
+			UpdatedAt:  at,
+		})
+		total += line
+		vat += lineVAT
+		if !slices.Contains(suppliers, product.SupplierID) {
+			suppliers = append(suppliers, product.SupplierID)
+		}
+	}
+
+	payments := s.payments(total)
+	var received, change, cash float64
+	for _, p := range payments {
+		received += p.Amount
+		change += p.Change
+		if p.Method == models.TenderCash {
+			cash += p.Amount - p.Change
+		}
+	}
+
+	txn := models.Transaction{
+		Subtotal:     total,
+		VatableSales: math.Round((total-vat)*100) / 100,
+		VAT:          math.Round(vat*100) / 100,
+		Total:        total,
+		Received:     received,
+		Change:       change,
+		SupplierID:   suppliers[0],
+		ShiftID:      &shiftID,
+		CashierID:    cashier.ID,
+		CreatedAt:    at,
+	}
+	txn.UpdatedAt = at
+	if err := s.db.Omit("TransactionItems", "Payments").Create(&txn).Error; err != nil {
+		return 0, err
+	}
+
+	links := make([]models.TransactionSupplier, 0, len(suppliers))
+	for _, id := range suppliers {
+		links = append(links, models.TransactionSupplier{TransactionID: txn.ID, SupplierID: id})
+	}
+	sold := make([]models.SoldItems, 0, len(items))
+	for i := range payments {
+		payments[i].TransactionID = txn.ID
+		payments[i].CreatedAt = at
+	}
+	for i := range items {
+		items[i].TransactionID = txn.ID
+		sold = append(sold, models.SoldItems{
+			Model:        gorm.Model{CreatedAt: at, UpdatedAt: at},
+			ProductID:    items[i].ProductID,
+			QuantitySold: items[i].Quantity,
+			TotalAmount:  items[i].Total,
+			SoldDate:     at,
+		})
+	}
+	for _, batch := range []interface{}{&items, &links, &payments, &sold} {
+		if err := s.db.Create(batch).Error; err != nil {
+			return 0, err
+		}
+	}
+	s.txnCount++
+	return cash, nil
+}
+
+// payments settles total the way customers do: mostly cash in round notes,
+// often GCash, Maya or a card, and now and then part wallet, part cash.
+func (s *seeder) payments(total float64) []models.Payment {
+	reference := func() string { return fmt.Sprintf("%012d", s.rnd.Int64N(1_000_000_000_000)) }
+	cash := func(amount float64) models.Payment {
+		// Customers mostly hand over round notes
+		received := amount
+		if s.rnd.IntN(3) > 0 {
+			received = math.Ceil(amount/100) * 100
+			if s.rnd.IntN(2) == 0 {
+				received = math.Ceil(amount/500) * 500
+			}
+		}
+		return models.Payment{Method: models.TenderCash, Amount: received, Change: received - amount}
+	}
+
+	switch n := s.rnd.IntN(100); {
+	case n < 60:
+		return []models.Payment{cash(total)}
+	case n < 90:
+		method := []string{models.TenderGCash, models.TenderGCash, models.TenderMaya, models.TenderCard}[s.rnd.IntN(4)]
+		return []models.Payment{{Method: method, Amount: total, Reference: reference()}}
+	default:
+		wallet := math.Floor(total / 2)
+		return []models.Payment{
+			{Method: models.TenderGCash, Amount: wallet, Reference: reference()},
+			cash(total - wallet),
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+func smallSeed() SeedOptions {
+	return SeedOptions{
+		Suppliers:           3,
+		ProductsPerSupplier: 2,
+		Months:              1,
+		SalesPerDay:         4,
+		Orders:              5,
+		Seed:                7,
+		Until:               time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+type seedSnapshot struct {
+	suppliers, products, orders, shifts, transactions, soldItems int64
+	revenue, soldAmount                                          float64
+	names                                                        []string
+}
+
+func snapshot(t *testing.T, db *gorm.DB) seedSnapshot {
+	t.Helper()
+
+	var s seedSnapshot
+	for _, c := range []struct {
+		model interface{}
+		n     *int64
+	}{
+		{&models.Supplier{}, &s.suppliers},
+		{&models.OtopProducts{}, &s.products},
+		{&models.Order{}, &s.orders},
+		{&models.Shift{}, &s.shifts},
+		{&models.Transaction{}, &s.transactions},
+		{&models.SoldItems{}, &s.soldItems},
+	} {
+		if err := db.Model(c.model).Count(c.n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&models.Transaction{}).Select("COALESCE(SUM(total), 0)").Scan(&s.revenue)
+	db.Model(&models.SoldItems{}).Select("COALESCE(SUM(total_amount), 0)").Scan(&s.soldAmount)
+	db.Model(&models.OtopProducts{}).Order("id").Pluck("name", &s.names)
+	return s
+}
+
+func TestSeedIsRepeatableAndLeavesRealDataAlone(t *testing.T) {
+	db := testutil.NewDB(t)
+	real := models.Supplier{StoreName: "Real Store", Email: "owner@real.example"}
+	if err := db.Create(&real).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Seed(db, "development", smallSeed()); err != nil {
+		t.Fatal(err)
+	}
+	first := snapshot(t, db)
+	if first.suppliers != 4 || first.products != 6 || first.orders != 5 || first.transactions == 0 {
+		t.Fatalf("first seed = %+v", first)
+	}
+	if first.revenue != first.soldAmount {
+		t.Errorf("transactions total %.2f but sold items total %.2f", first.revenue, first.soldAmount)
+	}
+
+	if err := Seed(db, "development", smallSeed()); err != nil {
+		t.Fatal(err)
+	}
+	second := snapshot(t, db)
+	if second.suppliers != first.suppliers || second.transactions != first.transactions ||
+		second.soldItems != first.soldItems || second.revenue != first.revenue || second.shifts != first.shifts {
+		t.Errorf("reseed changed the dataset:\nfirst  %+v\nsecond %+v", first, second)
+	}
+	for i := range first.names {
+		if first.names[i] != second.names[i] {
+			t.Errorf("product %d = %q, was %q", i, second.names[i], first.names[i])
+		}
+	}
+
+	var kept models.Supplier
+	if err := db.First(&kept, real.ID).Error; err != nil {
+		t.Errorf("real supplier removed: %v", err)
+	}
+}
+
+func TestSeedRefusesProduction(t *testing.T) {
+	db := testutil.NewDB(t)
+	if err := Seed(db, "production", smallSeed()); err == nil {
+		t.Fatal("seeded a production database")
+	}
+	var n int64
+	db.Model(&models.User{}).Count(&n)
+	if n != 0 {
+		t.Errorf("users = %d after a refused seed", n)
+	}
+}
+
+func TestSeedWithoutAppEnvNeedsAllowAndNoRealData(t *testing.T) {
+	db := testutil.NewDB(t)
+	opts := smallSeed()
+	if err := Seed(db, "", opts); err == nil {
+		t.Fatal("seeded without APP_ENV or -allow")
+	}
+
+	opts.Allow = true
+	if err := Seed(db, "", opts); err != nil {
+		t.Fatalf("seeding an empty database with -allow: %v", err)
+	}
+	// A database holding only an earlier seed can be reseeded.
+	if err := Seed(db, "", opts); err != nil {
+		t.Fatalf("reseeding with -allow: %v", err)
+	}
+
+	real := models.Supplier{StoreName: "Real Store", Email: "owner@real.example"}
+	if err := db.Create(&real).Error; err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, db)
+	if err := Seed(db, "", opts); err == nil {
+		t.Fatal("seeded a database with real data without APP_ENV")
+	}
+	if after := snapshot(t, db); after.transactions != before.transactions || after.suppliers != before.suppliers {
+		t.Errorf("refused seed changed the database: %+v -> %+v", before, after)
+	}
+}
+
+func TestSeedRefusesUnknownEnv(t *testing.T) {
+	db := testutil.NewDB(t)
+	opts := smallSeed()
+	opts.Allow = true
+	if err := Seed(db, "prod", opts); err == nil {
+		t.Fatal("seeded with an unknown APP_ENV")
+	}
+}
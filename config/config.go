@@ -0,0 +1,274 @@
+// Package config loads the server settings from environment variables and an
+// optional env-style file into one validated struct.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Env         string // APP_ENV: development, staging or production; empty when unset
+	Port        string
+	CORSOrigins string // comma-separated, "*" allows any origin
+	FrontendURL string // base URL used in emailed links
+	Server      ServerConfig
+	Log         LogConfig
+	Sales       SalesConfig
+	Database    DatabaseConfig
+	SMTP        SMTPConfig
+	JWT         JWTConfig
+}
+
+// ServerConfig bounds how long a connection may take and how much it may send.
+// ShutdownTimeout is how long in-flight requests get to finish on SIGTERM.
+type ServerConfig struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	BodyLimit       int // bytes
+}
+
+// LogConfig sets how much is logged. Database queries slower than
+// SlowQuery are logged as warnings; zero turns that off.
+type LogConfig struct {
+	Level     slog.Level
+	SlowQuery time.Duration
+
+	// MetricsToken, when set, must be sent as a bearer token to read
+	// /metrics.
+	MetricsToken string
+}
+
+// SalesConfig holds the POS rules that vary by hub. DiscountCategories are
+// the product categories the senior citizen and PWD discount applies to.
+// Products in VATExemptCategories or ZeroRatedCategories carry no VAT unless
+// the product says otherwise; everything else is vatable.
+type SalesConfig struct {
+	DiscountCategories  []string
+	VATExemptCategories []string
+	ZeroRatedCategories []string
+}
+
+// appEnvs are the environments APP_ENV can name. Unset, the server behaves as
+// in development, but commands that must never touch production refuse to
+// run.
+var appEnvs = []string{"development", "staging", "production"}
+
+// productCategories are the categories products can have.
+var productCategories = []string{"Food", "Non-Food"}
+
+type DatabaseConfig struct {
+	Host            string
+	Port            int
+	Name            string
+	User            string
+	Password        string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DSN is the PostgreSQL connection string for the settings. Every value is
+// quoted, so passwords may hold spaces, quotes and backslashes.
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=%s",
+		dsnValue(d.Host), d.Port, dsnValue(d.User), dsnValue(d.Name), dsnValue(d.Password), dsnValue(d.SSLMode))
+}
+
+// dsnQuoter escapes a value for a single-quoted keyword=value DSN.
+var dsnQuoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func dsnValue(value string) string {
+	return "'" + dsnQuoter.Replace(value) + "'"
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// JWTConfig holds the signing key list; see utils.LoadSigningKeys for the
+// format of Keys.
+type JWTConfig struct {
+	ActiveKeyID string
+	Keys        string
+}
+
+// Load reads .env and the file named by CONFIG_FILE when they exist, then the
+// environment. Variables already set in the environment win over both files.
+// The returned error lists every missing or malformed setting.
+func Load() (*Config, error) {
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := godotenv.Load(file); err != nil {
+			return nil, fmt.Errorf("reading config file %s: %w", file, err)
+		}
+	}
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+
+	r := &reader{}
+	cfg := &Config{
+		Env:         r.choice("APP_ENV", "", appEnvs),
+		Port:        r.str("PORT", "8097"),
+		CORSOrigins: r.str("CORS_ORIGINS", "*"),
+		FrontendURL: r.required("FRONTEND_URL"),
+		Server: ServerConfig{
+			ReadTimeout:     r.duration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:    r.duration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:     r.duration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			ShutdownTimeout: r.duration("SERVER_SHUTDOWN_TIMEOUT", 20*time.Second),
+			BodyLimit:       r.integer("SERVER_BODY_LIMIT", 1<<20),
+		},
+		Log: LogConfig{
+			Level:        r.level("LOG_LEVEL", slog.LevelInfo),
+			SlowQuery:    r.duration("LOG_SLOW_QUERY", 200*time.Millisecond),
+			MetricsToken: r.str("METRICS_TOKEN", ""),
+		},
+		Sales: SalesConfig{
+			DiscountCategories:  r.list("STATUTORY_DISCOUNT_CATEGORIES", "Food", productCategories),
+			VATExemptCategories: r.list("VAT_EXEMPT_CATEGORIES", "", productCategories),
+			ZeroRatedCategories: r.list("VAT_ZERO_RATED_CATEGORIES", "", productCategories),
+		},
+		Database: DatabaseConfig{
+			Host:            r.required("DB_HOST"),
+			Port:            r.integer("DB_PORT", 5432),
+			Name:            r.required("DB_NAME"),
+			User:            r.required("DB_USER"),
+			Password:        r.str("DB_PASSWORD", ""),
+			SSLMode:         r.str("DB_SSLMODE", "disable"),
+			MaxOpenConns:    r.integer("DB_MAX_OPEN_CONNS", 20),
+			MaxIdleConns:    r.integer("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: r.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		},
+		SMTP: SMTPConfig{
+			Host:     r.required("SMTP_HOST"),
+			Port:     r.integer("SMTP_PORT", 587),
+			Username: r.str("SMTP_USERNAME", ""),
+			Password: r.str("SMTP_PASSWORD", ""),
+			From:     r.required("SMTP_FROM"),
+		},
+		JWT: JWTConfig{
+			ActiveKeyID: r.required("JWT_ACTIVE_KEY_ID"),
+			Keys:        r.required("JWT_KEYS"),
+		},
+	}
+
+	if cfg.Server.BodyLimit == 0 {
+		r.problems = append(r.problems, "SERVER_BODY_LIMIT must be greater than 0")
+	}
+	for _, category := range cfg.Sales.VATExemptCategories {
+		if slices.Contains(cfg.Sales.ZeroRatedCategories, category) {
+			r.problems = append(r.problems, fmt.Sprintf("%s cannot be in both VAT_EXEMPT_CATEGORIES and VAT_ZERO_RATED_CATEGORIES", category))
+		}
+	}
+	if cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		r.problems = append(r.problems, "DB_MAX_IDLE_CONNS cannot be larger than DB_MAX_OPEN_CONNS")
+	}
+
+	if len(r.problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(r.problems, "\n  - "))
+	}
+	return cfg, nil
+}
+
+// reader collects every problem instead of stopping at the first one.
+type reader struct {
+	problems []string
+}
+
+func (r *reader) str(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return fallback
+}
+
+func (r *reader) required(key string) string {
+	value := r.str(key, "")
+	if value == "" {
+		r.problems = append(r.problems, key+" is required")
+	}
+	return value
+}
+
+func (r *reader) integer(key string, fallback int) int {
+	value := r.str(key, "")
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be a non-negative whole number, got %q", key, value))
+		return fallback
+	}
+	return n
+}
+
+func (r *reader) duration(key string, fallback time.Duration) time.Duration {
+	value := r.str(key, "")
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be a duration such as 30m, got %q", key, value))
+		return fallback
+	}
+	return d
+}
+
+func (r *reader) level(key string, fallback slog.Level) slog.Level {
+	value := r.str(key, "")
+	if value == "" {
+		return fallback
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be debug, info, warn or error, got %q", key, value))
+		return fallback
+	}
+	return level
+}
+
+// choice reads a value that must be one of allowed, ignoring case.
+func (r *reader) choice(key, fallback string, allowed []string) string {
+	value := strings.ToLower(r.str(key, fallback))
+	if value != fallback && !slices.Contains(allowed, value) {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+		return fallback
+	}
+	return value
+}
+
+// list reads a comma-separated list whose entries must each be one of
+// allowed.
+func (r *reader) list(key, fallback string, allowed []string) []string {
+	var values []string
+	for _, value := range strings.Split(r.str(key, fallback), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !slices.Contains(allowed, value) {
+			r.problems = append(r.problems, fmt.Sprintf("%s entries must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDSN(t *testing.T) {
+	for name, password := range map[string]string{
+		"plain":     "secret",
+		"empty":     "",
+		"space":     "two words",
+		"quote":     "it's",
+		"backslash": `back\slash`,
+		"keywords":  "x sslmode=require host=evil",
+		"mixed":     ` \'=" `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := DatabaseConfig{Host: "db.internal", Port: 6432, Name: "otop pos", User: "o'brien", Password: password, SSLMode: "disable"}
+			got, err := pgconn.ParseConfig(d.DSN())
+			if err != nil {
+				t.Fatalf("%s: %v", d.DSN(), err)
+			}
+			if got.Host != d.Host || got.Port != uint16(d.Port) || got.Database != d.Name || got.User != d.User || got.Password != d.Password || got.TLSConfig != nil {
+				t.Errorf("%s parsed as host=%q port=%d dbname=%q user=%q password=%q tls=%v",
+					d.DSN(), got.Host, got.Port, got.Database, got.User, got.Password, got.TLSConfig != nil)
+			}
+		})
+	}
+}
+
+// setEnv sets the variables Load requires, then overrides.
+func setEnv(t *testing.T, overrides map[string]string) {
+	t.Helper()
+
+	env := map[string]string{
+		"CONFIG_FILE":       "",
+		"FRONTEND_URL":      "https://pos.example.com",
+		"DB_HOST":           "localhost",
+		"DB_NAME":           "otop",
+		"DB_USER":           "otop",
+		"SMTP_HOST":         "smtp.example.com",
+		"SMTP_FROM":         "pos@example.com",
+		"JWT_ACTIVE_KEY_ID": "k1",
+		"JWT_KEYS":          "k1:secret",
+	}
+	for key, value := range overrides {
+		env[key] = value
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	setEnv(t, nil)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "8097" || cfg.Database.Port != 5432 || cfg.Database.SSLMode != "disable" || cfg.SMTP.Port != 587 {
+		t.Errorf("ports and modes = %+v", cfg)
+	}
+	if cfg.Server.ReadTimeout != 15*time.Second || cfg.Server.BodyLimit != 1<<20 || cfg.Log.Level != slog.LevelInfo {
+		t.Errorf("server = %+v, log = %+v", cfg.Server, cfg.Log)
+	}
+	if !slices.Equal(cfg.Sales.DiscountCategories, []string{"Food"}) || len(cfg.Sales.VATExemptCategories) != 0 {
+		t.Errorf("sales = %+v", cfg.Sales)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for name, tc := range map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"missing host":          {map[string]string{"DB_HOST": ""}, "DB_HOST is required"},
+		"bad port":              {map[string]string{"DB_PORT": "x"}, `DB_PORT must be a non-negative whole number, got "x"`},
+		"negative timeout":      {map[string]string{"SERVER_READ_TIMEOUT": "-1s"}, "SERVER_READ_TIMEOUT must be a duration"},
+		"bad level":             {map[string]string{"LOG_LEVEL": "loud"}, "LOG_LEVEL must be debug, info, warn or error"},
+		"unknown env":           {map[string]string{"APP_ENV": "qa"}, "APP_ENV must be one of"},
+		"unknown category":      {map[string]string{"VAT_EXEMPT_CATEGORIES": "Toys"}, `got "Toys"`},
+		"exempt and zero":       {map[string]string{"VAT_EXEMPT_CATEGORIES": "Food", "VAT_ZERO_RATED_CATEGORIES": "Food"}, "Food cannot be in both"},
+		"zero body limit":       {map[string]string{"SERVER_BODY_LIMIT": "0"}, "SERVER_BODY_LIMIT must be greater than 0"},
+		"idle above open":       {map[string]string{"DB_MAX_OPEN_CONNS": "2", "DB_MAX_IDLE_CONNS": "3"}, "DB_MAX_IDLE_CONNS cannot be larger"},
+		"every problem at once": {map[string]string{"DB_HOST": "", "SMTP_HOST": ""}, "DB_HOST is required\n  - SMTP_HOST is required"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setEnv(t, tc.env)
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/m/models"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+var errAccountTokenInvalid = errors.New("token is invalid, used or expired")
+
+// account is a sign-in identity from either the suppliers or the users table.
+type account struct {
+	SubjectType string
+	ID          uint
+	Name        string
+	Email       string
+	Password    string
+	Role        string
+	Active      bool             // false until the account may sign in
+	Supplier    *models.Supplier // set when SubjectType is SubjectSupplier
+}
+
+// findAccountByEmail looks an email up the same way UnifiedLogin does:
+// suppliers first, then staff users.
+func (h *Handler) findAccountByEmail(email string) (account, error) {
+	var supplier models.Supplier
+	err := h.DB.Where("email = ?", email).First(&supplier).Error
+	if err == nil {
+		return account{
+			SubjectType: models.SubjectSupplier,
+			ID:          supplier.ID,
+			Name:        supplier.StoreName,
+			Email:       supplier.Email,
+			Password:    supplier.Password,
+			Role:        "supplier",
+			Active:      supplier.Status == models.SupplierActive,
+			Supplier:    &supplier,
+		}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account{}, err
+	}
+
+	var user models.User
+	if err := h.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return account{}, err
+	}
+	return account{
+		SubjectType: models.SubjectUser,
+		ID:          user.ID,
+		Name:        user.UserName,
+		Email:       user.Email,
+		Password:    user.Password,
+		Role:        user.Role,
+		Active:      !user.Disabled,
+	}, nil
+}
+
+func accountModel(subjectType string) interface{} {
+	if subjectType == models.SubjectSupplier {
+		return &models.Supplier{}
+	}
+	return &models.User{}
+}
+
+// setAccountPassword stores a new bcrypt hash for the account.
+func setAccountPassword(tx *gorm.DB, subjectType string, id uint, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return tx.Model(accountModel(subjectType)).Where("id = ?", id).Update("password", hash).Error
+}
+
+// issueAccountToken creates a single-use token for purpose, replacing any
+// earlier unused token of the same purpose for the account.
+func (h *Handler) issueAccountToken(purpose, subjectType string, subjectID uint, ttl time.Duration) (string, error) {
+	var token string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = issueAccountTokenTx(tx, purpose, subjectType, subjectID, ttl)
+		return err
+	})
+	return token, err
+}
+
+// issueAccountTokenTx is issueAccountToken inside an existing transaction.
+func issueAccountTokenTx(tx *gorm.DB, purpose, subjectType string, subjectID uint, ttl time.Duration) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Where("purpose = ? AND subject_type = ? AND subject_id = ? AND used_at IS NULL", purpose, subjectType, subjectID).
+		Delete(&models.AccountToken{}).Error; err != nil {
+		return "", err
+	}
+	err = tx.Create(&models.AccountToken{
+		Purpose:     purpose,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		TokenHash:   utils.HashToken(token),
+		ExpiresAt:   time.Now().Add(ttl),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeAccountToken marks a token as used inside tx and returns it. The
+// conditional update makes sure two requests cannot both use the same token.
+func consumeAccountToken(tx *gorm.DB, purpose, token string) (models.AccountToken, error) {
+	var accountToken models.AccountToken
+	err := tx.Where("purpose = ? AND token_hash = ?", purpose, utils.HashToken(token)).First(&accountToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return accountToken, errAccountTokenInvalid
+	}
+	if err != nil {
+		return accountToken, err
+	}
+
+	now := time.Now()
+	if accountToken.UsedAt != nil || now.After(accountToken.ExpiresAt) {
+		return accountToken, errAccountTokenInvalid
+	}
+
+	result := tx.Model(&models.AccountToken{}).
+		Where("id = ? AND used_at IS NULL", accountToken.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return accountToken, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return accountToken, errAccountTokenInvalid
+	}
+
+	return accountToken, nil
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/listing"
+	"github.com/m/models"
+)
+
+// AuditListing is how audit entries can be filtered and sorted.
+var AuditListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"entity":     {Column: "entity"},
+		"entity_id":  {Column: "entity_id"},
+		"action":     {Column: "action"},
+		"actor_type": {Column: "actor_type"},
+		"actor_id":   {Column: "actor_id", Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+	DateColumn:  "created_at",
+}
+
+// GetAuditLogs lists audit entries, newest first. Supported filters:
+// entity, entity_id, action, actor_type, actor_id and from/to (YYYY-MM-DD,
+// inclusive).
+func (h *Handler) GetAuditLogs(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), AuditListing)
+	if err != nil {
+		return err
+	}
+
+	logs, err := listing.Find[models.AuditLog](h.DB, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch audit logs", err)
+	}
+
+	return c.JSON(logs)
+}
@@ -1,216 +1,103 @@
 package controllers
 
 import (
-	// "fmt"
-	"strings"
+	"context"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/m/database"
+	"github.com/m/apperr"
+	"github.com/m/logging"
 	"github.com/m/models"
 	"github.com/m/utils"
-	"gopkg.in/gomail.v2"
-	// "golang.org/x/crypto/bcrypt"
-	// "gopkg.in/gomail.v2"
 )
 
-func Register(c *fiber.Ctx) error {
-	var user models.User
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user data"})
-	}
-
-	// Check if the email (username) is already registered
-	var existingUser models.User
-	if err := database.DB.Where("username = ?", user.UserName).First(&existingUser).Error; err == nil {
-		// Send notification that email is already registered
-		m := gomail.NewMessage()
-		m.SetHeader("From", "giemacazar@gmail.com")
-		m.SetHeader("To", user.Email)
-		m.SetHeader("Subject", "Registration Attempt Failed")
-		m.SetBody("text/plain", "Hello,\n\nAn account with this email/username already exists. If this wasn't you, please ignore this message.")
-
-		d := gomail.NewDialer("smtp.gmail.com", 587, "giemacazar@gmail.com", "wtga mbuz ooxc ymzs")
-
-		// Attempt to send the email
-		if err := d.DialAndSend(m); err != nil {
-			// Log error or handle it
-		}
+func (h *Handler) UnifiedLogin(c *fiber.Ctx) error {
+	var creds LoginRequest
 
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email is already registered"})
-	}
-
-	// Validate the role
-	if user.Role != "admin" && user.Role != "cashier" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role. Only 'admin' and 'cashier' roles are allowed."})
+	// Parse the request body to get credentials
+	if err := bind(c, &creds); err != nil {
+		return err
 	}
 
-	// Validate username format
-	if user.Role == "admin" && !strings.HasSuffix(user.UserName, "_admin") {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Admin username must end with '_admin'"})
-	}
-	if user.Role == "cashier" && !strings.HasSuffix(user.UserName, "_cashier") {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cashier username must end with '_cashier'"})
+	// Look the email up in the supplier table first, then the user table
+	acct, err := h.checkCredentials(c.UserContext(), c.IP(), creds.Email, creds.Password)
+	if err != nil {
+		return loginError(c, err)
 	}
 
-	// Save user
-	if err := database.DB.Create(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving user"})
+	// Start a session for the account
+	tokens, err := h.Sessions.Start(acct.SubjectType, acct.ID)
+	if err != nil {
+		return apperr.Internal("Error generating token", err)
 	}
+	response := sessionResponse(tokens)
+	response["role"] = acct.Role
 
-	// Send registration success email
-	m := gomail.NewMessage()
-	m.SetHeader("From", "giemacazar@gmail.com")
-	m.SetHeader("To", user.Email)
-	m.SetHeader("Subject", "Registration Confirmation")
-	m.SetBody("text/plain", "Hello "+user.UserName+",\n\nYour registration was successful. Welcome to our platform!")
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, "giemacazar@gmail.com", "wtga mbuz ooxc ymzs")
-
-	if err := d.DialAndSend(m); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error sending registration email"})
+	// Suppliers also get their store details
+	if acct.Supplier != nil {
+		response["supplier_id"] = acct.Supplier.ID
+		response["store_name"] = acct.Supplier.StoreName
+		response["phone_number"] = acct.Supplier.PhoneNumber
+		response["addres"] = acct.Supplier.Address
+		response["id"] = acct.Supplier.ID
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "User registered successfully"})
+	return c.JSON(response)
 }
 
-// func Register(c *fiber.Ctx) error {
-// 	var user models.User
-// 	if err := c.BodyParser(&user); err != nil {
-// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user data"})
-// 	}
-
-// 	// Check if the email is already registered
-// 	var existingUser models.User
-// 	if err := database.DB.Where("username = ?", user.UserName).First(&existingUser).Error; err == nil {
-// 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email is already registered"})
-// 	}
-
-// 	// Check if the role is either "admin" or "cashier"
-// 	if user.Role != "admin" && user.Role != "cashier" {
-// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid role. Only 'admin' and 'cashier' roles are allowed."})
-// 	}
-
-// 	// Validate the username based on the role
-// 	if user.Role == "admin" && !strings.HasSuffix(user.UserName, "_admin") {
-// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Admin username must end with '_admin'"})
-// 	}
-// 	if user.Role == "cashier" && !strings.HasSuffix(user.UserName, "_cashier") {
-// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cashier username must end with '_cashier'"})
-// 	}
-
-// 	// Save the user to the database
-// 	if err := database.DB.Create(&user).Error; err != nil {
-// 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving user"})
-// 	}
-
-// 	// Send email notification
-// 	m := gomail.NewMessage()
-// 	m.SetHeader("From", "giemacazar@gmail.com")
-// 	m.SetHeader("To", user.Email)
-// 	m.SetHeader("Subject", "Registration Confirmation")
-// 	m.SetBody("text/plain", "Hello "+user.UserName+",\n\nYour registration was successful. Welcome to our platform!")
-
-// 	d := gomail.NewDialer("smtp.gmail.com", 587, "giemacazar@gmail.com", "wtga mbuz ooxc ymzs")
-
-// 	// Send the email
-// 	if err := d.DialAndSend(m); err != nil {
-// 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error sending registration email"})
-// 	}
-
-//		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "User registered successfully"})
-//	}
-func UnifiedLogin(c *fiber.Ctx) error {
-	var creds struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	// Parse the request body to get credentials
-	if err := c.BodyParser(&creds); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid login data"})
-	}
-
-	// Check if the email exists in the supplier table first
-	var storedSupplier models.Supplier
-	if err := database.DB.Where("email = ?", creds.Email).First(&storedSupplier).Error; err == nil {
-		// If the supplier exists, check if the password matches
-		if storedSupplier.Password != creds.Password {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
-		}
-
-		// Generate token for the supplier
-		token, err := utils.GenerateToken(storedSupplier.StoreName, "supplier", storedSupplier.ID, storedSupplier.ID)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error generating token"})
-		}
-
-		// Return the token, role, and supplier details for the supplier
-		return c.JSON(fiber.Map{
-			"token":        token,
-			"role":         "supplier",
-			"supplier_id":  storedSupplier.ID,
-			"store_name":   storedSupplier.StoreName,
-			"phone_number": storedSupplier.PhoneNumber,
-			"addres":       storedSupplier.Address,
-			"id":           storedSupplier.ID,
-		})
+// rehashPassword upgrades a legacy plaintext (or outdated) password after a
+// successful login. Failures are logged only; the login itself still succeeds.
+func (h *Handler) rehashPassword(ctx context.Context, model interface{}, id uint, password string) {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		logging.From(ctx).Error("hashing password", "error", err)
+		return
 	}
-
-	// If not found in the supplier table, check in the user table
-	var storedUser models.User
-	if err := database.DB.Where("email = ?", creds.Email).First(&storedUser).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not found"})
+	if err := h.DB.Model(model).Where("id = ?", id).Update("password", hash).Error; err != nil {
+		logging.From(ctx).Error("upgrading stored password", "error", err)
 	}
+}
 
-	// If the password does not match for the user
-	if storedUser.Password != creds.Password {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+// Logout revokes the caller's session, which invalidates both its access
+// token and its refresh token.
+func (h *Handler) Logout(c *fiber.Ctx) error {
+	sessionID, _, _, ok := tokenSubject(c)
+	if !ok {
+		return apperr.Unauthorized("Unauthorized")
 	}
 
-	// Generate token for the user
-	token, err := utils.GenerateToken(storedUser.UserName, storedUser.Role, storedUser.ID, 0)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error generating token"})
+	if err := h.Sessions.Revoke(sessionID); err != nil {
+		return apperr.Internal("Failed to log out", err)
 	}
 
-	// Return the token and role for the user
-	return c.JSON(fiber.Map{
-		"token": token,
-		"role":  storedUser.Role,
-	})
-}
-
-func Logout(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"message": "Logout successful, please clear your token",
+		"message": "Logout successful",
 	})
 }
 
-func SupplierLogin(c *fiber.Ctx) error {
-	var creds models.Supplier
-	if err := c.BodyParser(&creds); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid login data"})
+func (h *Handler) SupplierLogin(c *fiber.Ctx) error {
+	var creds LoginRequest
+	if err := bind(c, &creds); err != nil {
+		return err
 	}
 
-	var storedSupplier models.Supplier
-	if err := database.DB.Where("email = ?", creds.Email).First(&storedSupplier).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Supplier not found"})
+	acct, err := h.checkCredentials(c.UserContext(), c.IP(), creds.Email, creds.Password)
+	if err == nil && acct.SubjectType != models.SubjectSupplier {
+		err = errInvalidCredentials
 	}
-
-	if storedSupplier.Password != creds.Password {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid credentials"})
+	if err != nil {
+		return loginError(c, err)
 	}
 
-	token, err := utils.GenerateToken(storedSupplier.StoreName, "supplier", storedSupplier.ID, storedSupplier.ID)
+	tokens, err := h.Sessions.Start(models.SubjectSupplier, acct.ID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error generating token"})
+		return apperr.Internal("Error generating token", err)
 	}
+	response := sessionResponse(tokens)
 
-	return c.JSON(fiber.Map{"token": token, "role": "supplier"})
+	response["role"] = "supplier"
+	return c.JSON(response)
 }
 
-func SupplierLogout(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"message": "Supplier logout successful, please clear your token",
-	})
+func (h *Handler) SupplierLogout(c *fiber.Ctx) error {
+	return h.Logout(c)
 }
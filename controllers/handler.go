@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/m/services"
+	"gorm.io/gorm"
+)
+
+// Handler serves the inventory, sales, order, supplier, reporting, catalog,
+// shift, staff account and session routes.
+// It only talks to the services it is given, so tests can swap in fakes or a
+// throwaway database.
+type Handler struct {
+	Inventory services.Inventory
+	Sales     services.Sales
+	Orders    services.Orders
+	Suppliers services.Suppliers
+	Reporting services.Reporting
+	Catalog   services.Catalog
+	Shifts    services.Shifts
+	Users     services.Users
+	Sessions  services.Sessions
+
+	// DB backs the account, login and audit handlers that have no service
+	// of their own.
+	DB *gorm.DB
+	// Mailer sends account emails; their links point at FrontendURL.
+	Mailer      MailSender
+	FrontendURL string
+}
+
+// MailSender sends plain-text email; *utils.Mailer is the real one.
+type MailSender interface {
+	Send(to, subject, body string) error
+}
+
+// NewHandler serves the routes from s and db. Set Mailer and FrontendURL
+// before serving routes that send email.
+func NewHandler(db *gorm.DB, s *services.Services) *Handler {
+	return &Handler{
+		DB:        db,
+		Inventory: s.Inventory,
+		Sales:     s.Sales,
+		Orders:    s.Orders,
+		Suppliers: s.Suppliers,
+		Reporting: s.Reporting,
+		Catalog:   s.Catalog,
+		Shifts:    s.Shifts,
+		Users:     s.Users,
+		Sessions:  s.Sessions,
+	}
+}
+
+// paramID reads a numeric route parameter; anything else reads as 0, which
+// matches no record.
+func paramID(value string) uint {
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,783 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+const testCashierID = 7
+
+// newHandler serves from db with a fake mailer; see mailerOf.
+func newHandler(db *gorm.DB) *Handler {
+	h := NewHandler(db, services.New(db, services.DefaultSalesRules()))
+	h.Mailer = &fakeMailer{sent: make(chan sentMail, 10)}
+	h.FrontendURL = "https://pos.example.com"
+	return h
+}
+
+// newTestApp serves h's routes the way routes.UserRoutes does, minus the
+// token check, with every request signed in as the given account.
+func newTestApp(h *Handler, id uint, role string) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Use(testutil.AsUser(id, role))
+	app.Get("/api/otop/products", h.GetOtopProducts)
+	app.Post("/api/otop/products", h.CreateOtopProduct)
+	app.Post("/api/otop/POS", h.POSController)
+	app.Post("/api/otop/sold_items", h.RecordSoldItem)
+	app.Post("/api/otop/getSummary", h.GetSalesSummary)
+	app.Post("/api/otop/tenderSummary", h.GetTenderSalesSummary)
+	app.Post("/api/otop/vatSummary", h.GetVATSalesSummary)
+	app.Get("/api/reports/discounts", h.GetDiscountRegister)
+	app.Post("/api/shifts/close", h.CloseShift)
+	app.Get("/api/otop/solds_products", h.GetAllSoldItems)
+	app.Post("/products/confirm/:id", h.ConfirmOrders)
+	app.Put("/orders/:id/confirm", h.ConfirmOrder)
+	app.Get("/orders/:supplier_id", h.GetSupplierOrders)
+	return app
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, path string, body interface{}) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, respBody
+}
+
+func seedOtopProduct(t *testing.T, db *gorm.DB, quantity int64) (models.Supplier, models.OtopProducts) {
+	t.Helper()
+
+	supplier := models.Supplier{StoreName: "Lola's Kakanin", Email: "lola@example.com", Status: models.SupplierActive}
+	if err := db.Create(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.OtopProducts{
+		Name:        "Puto",
+		Description: "Steamed rice cake",
+		Price:       25,
+		Quantity:    quantity,
+		Category:    "Food",
+		SupplierID:  supplier.ID,
+		StoreName:   supplier.StoreName,
+	}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	return supplier, product
+}
+
+func openTestShift(t *testing.T, db *gorm.DB) models.Shift {
+	t.Helper()
+
+	shift := models.Shift{CashierID: testCashierID, Status: models.ShiftOpen, OpenedAt: time.Now()}
+	if err := db.Create(&shift).Error; err != nil {
+		t.Fatal(err)
+	}
+	return shift
+}
+
+func checkoutBody(productID uint, quantity int64) fiber.Map {
+	return fiber.Map{
+		"items": []fiber.Map{
+			{"product_id": productID, "quantity": quantity, "price": 25, "total": 25 * quantity},
+		},
+		"received": 100,
+		"total":    25 * quantity,
+		"change":   100 - 25*quantity,
+	}
+}
+
+func stockOf(t *testing.T, db *gorm.DB, productID uint) int64 {
+	t.Helper()
+
+	var product models.OtopProducts
+	if err := db.First(&product, productID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product.Quantity
+}
+
+func TestPOSCheckoutRequiresOpenShift(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", checkoutBody(product.ID, 2))
+	if status != fiber.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusConflict, body)
+	}
+	var errBody struct {
+		Code apperr.Code `json:"code"`
+	}
+	if err := json.Unmarshal(body, &errBody); err != nil || errBody.Code != apperr.CodeNoOpenShift {
+		t.Errorf("body = %s, want code %q", body, apperr.CodeNoOpenShift)
+	}
+	if got := stockOf(t, db, product.ID); got != 10 {
+		t.Errorf("stock = %d, want it untouched at 10", got)
+	}
+}
+
+func TestPOSCheckoutDeductsStockAndLinksShift(t *testing.T) {
+	db := testutil.NewDB(t)
+	supplier, product := seedOtopProduct(t, db, 10)
+	shift := openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", checkoutBody(product.ID, 3))
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	if got := stockOf(t, db, product.ID); got != 7 {
+		t.Errorf("stock = %d, want 7", got)
+	}
+
+	var transaction models.Transaction
+	if err := db.First(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if transaction.ShiftID == nil || *transaction.ShiftID != shift.ID {
+		t.Errorf("transaction shift = %v, want %d", transaction.ShiftID, shift.ID)
+	}
+	if transaction.SupplierID != supplier.ID {
+		t.Errorf("transaction supplier = %d, want %d", transaction.SupplierID, supplier.ID)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("entity = ?", "otop_product").Count(&audits)
+	if audits != 1 {
+		t.Errorf("audit entries = %d, want 1 for the stock change", audits)
+	}
+}
+
+func TestPOSCheckoutRejectsShortPayment(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	body := checkoutBody(product.ID, 5)
+	body["received"] = 100
+	body["total"] = 125
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", body)
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusBadRequest, resp)
+	}
+}
+
+func TestPOSCheckoutRejectsClientPrices(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	body := fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4, "price": 1, "total": 4}},
+		"received": 4,
+		"total":    4,
+	}
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", body)
+	if status != fiber.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusConflict, resp)
+	}
+	var got struct {
+		Code   apperr.Code         `json:"code"`
+		Fields []apperr.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(resp, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != apperr.CodePriceMismatch || len(got.Fields) != 3 || got.Fields[0].Field != "items[0].price" {
+		t.Errorf("body = %s", resp)
+	}
+	if got := stockOf(t, db, product.ID); got != 10 {
+		t.Errorf("stock = %d, want 10", got)
+	}
+
+	// Without the till's figures the server prices the sale itself
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4}},
+		"received": 200,
+	})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var receipt struct {
+		Total, VAT, Change float64
+	}
+	if err := json.Unmarshal(resp, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Total != 100 || receipt.VAT != 10.71 || receipt.Change != 100 {
+		t.Errorf("receipt = %s", resp)
+	}
+}
+
+func TestPOSCheckoutSplitsTenders(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	// Wallet payments need the app's reference number
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4}},
+		"payments": []fiber.Map{{"method": "gcash", "amount": 100}},
+	})
+	if status != fiber.StatusBadRequest || !strings.Contains(string(resp), "payments[0].reference") {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+
+	// Only cash is given change
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4}},
+		"payments": []fiber.Map{{"method": "card", "amount": 150, "reference": "A1"}},
+	})
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+
+	// ₱100 of puto: ₱60 by GCash, ₱50 cash with ₱10 change
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items": []fiber.Map{{"product_id": product.ID, "quantity": 4}},
+		"payments": []fiber.Map{
+			{"method": "gcash", "amount": 60, "reference": "GC-123"},
+			{"method": "cash", "amount": 50},
+		},
+		"change": 10,
+	})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var receipt struct {
+		Received, Change float64
+		Payments         []models.Payment
+	}
+	if err := json.Unmarshal(resp, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Received != 110 || receipt.Change != 10 || len(receipt.Payments) != 2 || receipt.Payments[1].Change != 10 {
+		t.Errorf("receipt = %s", resp)
+	}
+
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/tenderSummary", fiber.Map{"interval": "monthly"})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var summary map[string]map[string]float64
+	if err := json.Unmarshal(resp, &summary); err != nil {
+		t.Fatal(err)
+	}
+	month := summary[time.Now().Month().String()]
+	if month["cash"] != 40 || month["gcash"] != 60 || month["card"] != 0 {
+		t.Errorf("this month = %v", month)
+	}
+
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/shifts/close", fiber.Map{"counted_cash": 40})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var shift models.Shift
+	if err := json.Unmarshal(resp, &shift); err != nil {
+		t.Fatal(err)
+	}
+	if shift.CashSales != 40 || shift.Variance != 0 || shift.Tenders["gcash"] != 60 || shift.Tenders["maya"] != 0 {
+		t.Errorf("closed shift = %s", resp)
+	}
+}
+
+func TestPOSCheckoutRecordsPWDDiscount(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4}},
+		"received": 100,
+		"discount": fiber.Map{"type": "pwd"},
+	})
+	if status != fiber.StatusBadRequest || !strings.Contains(string(resp), "discount.id_number") {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+
+	// ₱100 of food: ₱89.29 without VAT, less ₱17.86
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/POS", fiber.Map{
+		"items":    []fiber.Map{{"product_id": product.ID, "quantity": 4, "price": 25}},
+		"received": 100,
+		"discount": fiber.Map{"type": "pwd", "id_number": "PWD-77", "name": "Juan dela Cruz"},
+	})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var receipt struct {
+		Total, Discount, VAT float64
+		Statutory            models.StatutoryDiscount `json:"statutory_discount"`
+	}
+	if err := json.Unmarshal(resp, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Total != 71.43 || receipt.Discount != 17.86 || receipt.VAT != 0 || receipt.Statutory.VATExemption != 10.71 {
+		t.Errorf("receipt = %s", resp)
+	}
+
+	if status, resp := doJSON(t, app, fiber.MethodGet, "/api/reports/discounts?month=June", nil); status != fiber.StatusBadRequest {
+		t.Errorf("bad month: status = %d: %s", status, resp)
+	}
+	status, resp = doJSON(t, app, fiber.MethodGet, "/api/reports/discounts?type=pwd", nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var register services.DiscountRegister
+	if err := json.Unmarshal(resp, &register); err != nil {
+		t.Fatal(err)
+	}
+	if register.Totals.Count != 1 || register.Entries[0].Name != "Juan dela Cruz" || register.Totals.Discount != 17.86 {
+		t.Errorf("register = %s", resp)
+	}
+}
+
+func TestPOSCheckoutShowsVATBreakdown(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+	if err := db.Model(&product).Update("vat_class", models.VATZeroRated).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", checkoutBody(product.ID, 4))
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var receipt services.VATBreakdown
+	if err := json.Unmarshal(resp, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.ZeroRatedSales != 100 || receipt.VAT != 0 || receipt.VatableSales != 0 || receipt.Total != 100 {
+		t.Errorf("receipt = %s", resp)
+	}
+
+	if status, resp := doJSON(t, app, fiber.MethodPost, "/api/otop/vatSummary", fiber.Map{"interval": "hourly"}); status != fiber.StatusBadRequest {
+		t.Errorf("bad interval: status = %d: %s", status, resp)
+	}
+	status, resp = doJSON(t, app, fiber.MethodPost, "/api/otop/vatSummary", fiber.Map{"interval": "monthly"})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	var summary map[string]services.VATBreakdown
+	if err := json.Unmarshal(resp, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if month := summary[time.Now().Month().String()]; month.ZeroRatedSales != 100 || month.Total != 100 {
+		t.Errorf("this month = %+v", month)
+	}
+}
+
+func TestPOSCheckoutValidatesItems(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	for name, tc := range map[string]struct {
+		body  fiber.Map
+		field string
+	}{
+		"empty cart":        {fiber.Map{"items": []fiber.Map{}, "received": 100, "total": 25}, "items"},
+		"negative quantity": {checkoutBody(product.ID, -2), "items[0].quantity"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/POS", tc.body)
+			if status != fiber.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+			}
+			var got struct {
+				Code   apperr.Code         `json:"code"`
+				Fields []apperr.FieldError `json:"fields"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Code != apperr.CodeValidation {
+				t.Errorf("code = %q, want %q", got.Code, apperr.CodeValidation)
+			}
+			found := false
+			for _, f := range got.Fields {
+				found = found || f.Field == tc.field
+			}
+			if !found {
+				t.Errorf("fields = %+v, want an error for %s", got.Fields, tc.field)
+			}
+		})
+	}
+	if got := stockOf(t, db, product.ID); got != 10 {
+		t.Errorf("stock = %d, want 10", got)
+	}
+}
+
+func TestCreateOtopProductIgnoresServerOwnedFields(t *testing.T) {
+	db := testutil.NewDB(t)
+	supplier, _ := seedOtopProduct(t, db, 10)
+	app := newTestApp(newHandler(db), 1, "admin")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/products", fiber.Map{
+		"id":                9999,
+		"sequential_number": "SEQ-9999",
+		"supplier_id":       supplier.ID + 100,
+		"name":              "Bibingka",
+		"description":       "Baked rice cake",
+		"price":             40,
+		"quantity":          5,
+		"category":          "Food",
+		"store_name":        supplier.StoreName,
+	})
+	if status != fiber.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusCreated, body)
+	}
+
+	var created models.OtopProducts
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 9999 || created.SequentialNumber == "SEQ-9999" {
+		t.Errorf("client set id %d / sequential number %q", created.ID, created.SequentialNumber)
+	}
+	if created.SupplierID != supplier.ID {
+		t.Errorf("supplier_id = %d, want %d from the store", created.SupplierID, supplier.ID)
+	}
+}
+
+func TestRecordSoldItemRejectsInsufficientStock(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 2)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/sold_items", []fiber.Map{
+		{"id": product.ID, "quantity": 5},
+	})
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+	}
+	if got := stockOf(t, db, product.ID); got != 2 {
+		t.Errorf("stock = %d, want it untouched at 2", got)
+	}
+}
+
+func TestRecordSoldItemDeductsStock(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/sold_items", []fiber.Map{
+		{"id": product.ID, "quantity": 4},
+	})
+	if status != fiber.StatusCreated {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	if got := stockOf(t, db, product.ID); got != 6 {
+		t.Errorf("stock = %d, want 6", got)
+	}
+
+	var sold models.SoldItems
+	if err := db.First(&sold).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sold.TotalAmount != 100 {
+		t.Errorf("total amount = %v, want 100", sold.TotalAmount)
+	}
+}
+
+func TestConfirmOrdersDeductsProductStock(t *testing.T) {
+	db := testutil.NewDB(t)
+	supplier := models.Supplier{StoreName: "Habi", Email: "habi@example.com", Status: models.SupplierActive}
+	db.Create(&supplier)
+	product := models.Product{Name: "Banig", Price: 300, Quantity: 5, Category: "Non-Food", SupplierID: supplier.ID}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{SupplierID: supplier.ID, ProductID: product.ID, Quantity: 2, Status: services.OrderPending}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(db)
+
+	otherSupplier := newTestApp(h, supplier.ID+1, "supplier")
+	if status, body := doJSON(t, otherSupplier, fiber.MethodPost, "/products/confirm/"+fmt.Sprint(order.ID), nil); status != fiber.StatusForbidden {
+		t.Fatalf("other supplier: status = %d, want %d: %s", status, fiber.StatusForbidden, body)
+	}
+
+	app := newTestApp(h, supplier.ID, "supplier")
+	if status, body := doJSON(t, app, fiber.MethodPost, "/products/confirm/"+fmt.Sprint(order.ID), nil); status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+
+	db.First(&product, product.ID)
+	if product.Quantity != 3 {
+		t.Errorf("product stock = %d, want 3", product.Quantity)
+	}
+	db.First(&order, order.ID)
+	if order.Status != services.OrderVerified {
+		t.Errorf("order status = %q, want %q", order.Status, services.OrderVerified)
+	}
+
+	if status, _ := doJSON(t, app, fiber.MethodPost, "/products/confirm/"+fmt.Sprint(order.ID), nil); status != fiber.StatusBadRequest {
+		t.Errorf("second confirm: status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+func TestSuppliersOnlyReachTheirOwnOrders(t *testing.T) {
+	db := testutil.NewDB(t)
+	var suppliers [2]models.Supplier
+	var orders [2]models.Order
+	for i, name := range []string{"Habi", "Lala"} {
+		suppliers[i] = models.Supplier{StoreName: name, Email: strings.ToLower(name) + "@example.com", Status: models.SupplierActive}
+		if err := db.Create(&suppliers[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		orders[i] = models.Order{SupplierID: suppliers[i].ID, Quantity: 1, Status: services.OrderPending}
+		if err := db.Create(&orders[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newHandler(db)
+	a, b := suppliers[0], suppliers[1]
+	asA := newTestApp(h, a.ID, "supplier")
+
+	// A body naming B is ignored; A is still not B's supplier
+	status, body := doJSON(t, asA, fiber.MethodPut, fmt.Sprintf("/orders/%d/confirm", orders[1].ID), fiber.Map{"supplier_id": b.ID})
+	if status != fiber.StatusForbidden {
+		t.Errorf("confirm B's order as A: status = %d, want %d: %s", status, fiber.StatusForbidden, body)
+	}
+	var order models.Order
+	db.First(&order, orders[1].ID)
+	if order.Status != services.OrderPending {
+		t.Errorf("B's order status = %q, want it still pending", order.Status)
+	}
+	if status, body := doJSON(t, asA, fiber.MethodPut, fmt.Sprintf("/orders/%d/confirm", orders[0].ID), nil); status != fiber.StatusOK {
+		t.Errorf("confirm own order: status = %d: %s", status, body)
+	}
+
+	if status, _ := doJSON(t, asA, fiber.MethodGet, fmt.Sprintf("/orders/%d", b.ID), nil); status != fiber.StatusForbidden {
+		t.Errorf("list B's orders as A: status = %d, want %d", status, fiber.StatusForbidden)
+	}
+	if status, _ := doJSON(t, asA, fiber.MethodGet, fmt.Sprintf("/orders/%d", a.ID), nil); status != fiber.StatusOK {
+		t.Errorf("list own orders: status = %d", status)
+	}
+	admin := newTestApp(h, 1, "admin")
+	if status, _ := doJSON(t, admin, fiber.MethodGet, fmt.Sprintf("/orders/%d", b.ID), nil); status != fiber.StatusOK {
+		t.Errorf("admin lists B's orders: status = %d", status)
+	}
+}
+
+func TestGetSalesSummaryMonthly(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	sold := models.SoldItems{ProductID: product.ID, QuantitySold: 2}
+	if err := db.Create(&sold).Error; err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(newHandler(db), 1, "admin")
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/otop/getSummary", fiber.Map{"interval": "monthly"})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	var summary map[string]float64
+	if err := json.Unmarshal(body, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary) != 12 {
+		t.Errorf("buckets = %d, want 12 months", len(summary))
+	}
+	if got := summary[time.Now().Month().String()]; got != 50 {
+		t.Errorf("this month = %v, want 50", got)
+	}
+
+	if status, _ := doJSON(t, app, fiber.MethodPost, "/api/otop/getSummary", fiber.Map{"interval": "hourly"}); status != fiber.StatusBadRequest {
+		t.Errorf("unknown interval: status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+// fakeInventory serves a fixed product list; any other Inventory call panics.
+type fakeInventory struct {
+	services.Inventory
+	products []models.OtopProducts
+}
+
+func (f *fakeInventory) List(opts listing.Options) (listing.Page[models.OtopProducts], error) {
+	meta := listing.Meta{Total: int64(len(f.products)), Limit: opts.Limit, Sort: opts.Sort}
+	return listing.Page[models.OtopProducts]{Data: f.products, Meta: meta}, nil
+}
+
+func TestGetOtopProductsUsesInventoryService(t *testing.T) {
+	inventory := &fakeInventory{products: []models.OtopProducts{{ID: 10000, Name: "Puto"}}}
+	app := newTestApp(&Handler{Inventory: inventory}, 1, "admin")
+
+	status, body := doJSON(t, app, fiber.MethodGet, "/api/otop/products", nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+
+	var page listing.Page[models.OtopProducts]
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Name != "Puto" || page.Meta.Total != 1 {
+		t.Errorf("page = %+v", page)
+	}
+}
+
+func TestGetOtopProductsRejectsUnknownSort(t *testing.T) {
+	app := newTestApp(&Handler{Inventory: &fakeInventory{}}, 1, "admin")
+
+	status, body := doJSON(t, app, fiber.MethodGet, "/api/otop/products?sort=password", nil)
+	if status != fiber.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+	}
+	var got struct {
+		Code   apperr.Code         `json:"code"`
+		Fields []apperr.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != apperr.CodeValidation || len(got.Fields) != 1 || got.Fields[0].Field != "sort" {
+		t.Errorf("error = %+v", got)
+	}
+}
+
+func TestGetAllSoldItemsPagesButTotalsEverything(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, product := seedOtopProduct(t, db, 10)
+	for _, quantity := range []int64{1, 2, 3} {
+		sold := models.SoldItems{ProductID: product.ID, QuantitySold: quantity}
+		if err := db.Create(&sold).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestApp(newHandler(db), 1, "admin")
+
+	status, body := doJSON(t, app, fiber.MethodGet, "/api/otop/solds_products?limit=2&sort=id", nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	var resp struct {
+		Data              []models.SoldItems `json:"data"`
+		Meta              listing.Meta       `json:"meta"`
+		OverallAmountSold float64            `json:"overall_amount_sold"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Meta.Total != 3 || resp.Meta.NextCursor == "" {
+		t.Errorf("page = %d items, meta %+v", len(resp.Data), resp.Meta)
+	}
+	if resp.OverallAmountSold != 150 {
+		t.Errorf("overall amount sold = %v, want 150", resp.OverallAmountSold)
+	}
+}
+
+func TestOpenShiftRaceIsAConflict(t *testing.T) {
+	h := newHandler(testutil.NewFileDB(t))
+	app := newTestApp(h, testCashierID, "cashier")
+	app.Post("/api/shifts/open", h.OpenShift)
+
+	const tills = 8
+	var wg sync.WaitGroup
+	statuses := make([]int, tills)
+	for i := 0; i < tills; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], _ = doJSON(t, app, fiber.MethodPost, "/api/shifts/open", fiber.Map{"opening_cash": 500})
+		}(i)
+	}
+	wg.Wait()
+
+	opened := 0
+	for _, status := range statuses {
+		switch status {
+		case fiber.StatusCreated:
+			opened++
+		case fiber.StatusConflict:
+		default:
+			t.Errorf("status = %d, want %d or %d", status, fiber.StatusCreated, fiber.StatusConflict)
+		}
+	}
+	if opened != 1 {
+		t.Errorf("opened %d shifts, want 1", opened)
+	}
+}
+
+// Only PostgreSQL has the shift row locks this relies on: every sale either
+// lands in the closed shift's totals or finds no open shift.
+func TestCloseShiftCountsSalesInFlight(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	_, product := seedOtopProduct(t, db, 100)
+	shift := openTestShift(t, db)
+	app := newTestApp(newHandler(db), testCashierID, "cashier")
+
+	const tills = 10
+	var wg sync.WaitGroup
+	statuses := make([]int, tills)
+	for i := 0; i < tills; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i], _ = doJSON(t, app, fiber.MethodPost, "/api/otop/POS", checkoutBody(product.ID, 1))
+		}(i)
+	}
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/shifts/close", fiber.Map{"counted_cash": 0})
+	wg.Wait()
+	if status != fiber.StatusOK {
+		t.Fatalf("close status = %d: %s", status, body)
+	}
+
+	var closed models.Shift
+	if err := json.Unmarshal(body, &closed); err != nil {
+		t.Fatal(err)
+	}
+	var rung int64
+	db.Model(&models.Transaction{}).Where("shift_id = ?", shift.ID).Count(&rung)
+	if closed.TransactionCount != rung {
+		t.Errorf("closed shift counted %d sales, but %d are in it", closed.TransactionCount, rung)
+	}
+	for _, status := range statuses {
+		if status != fiber.StatusOK && status != fiber.StatusConflict {
+			t.Errorf("checkout status = %d, want %d or %d", status, fiber.StatusOK, fiber.StatusConflict)
+		}
+	}
+}
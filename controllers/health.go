@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/database"
+	"github.com/m/logging"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// draining is set when shutdown starts, so the load balancer stops sending
+// traffic while in-flight requests finish.
+var draining atomic.Bool
+
+// BeginShutdown makes Readiness fail from now on.
+func BeginShutdown() {
+	draining.Store(true)
+}
+
+// Liveness reports that the process is up. It does not touch the database, so
+// a database outage does not get the server restarted.
+func Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness reports whether this instance should receive traffic: it is not
+// shutting down, the database answers, and the schema is at the version the
+// code expects. The endpoint is public, so failures get a fixed reason and
+// the error itself is only logged.
+func (h *Handler) Readiness(c *fiber.Ctx) error {
+	checks := fiber.Map{}
+	ready := true
+	fail := func(check, reason string, err error) {
+		checks[check] = reason
+		ready = false
+		if err != nil {
+			logging.From(c.UserContext()).Error("readiness check failed", "check", check, "error", err)
+		}
+	}
+
+	if draining.Load() {
+		fail("shutdown", "in progress", nil)
+	}
+
+	sqlDB, err := h.DB.DB()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(c.Context(), readinessTimeout)
+		err = sqlDB.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		fail("database", "unreachable", err)
+	} else {
+		checks["database"] = "ok"
+
+		expected, err := database.LatestVersion()
+		if err != nil {
+			fail("migrations", "cannot read migrations", err)
+		} else if version, err := database.SchemaVersion(h.DB); err != nil {
+			fail("migrations", "cannot read schema version", err)
+		} else if version < expected {
+			fail("migrations", "schema is behind the code", nil)
+			checks["schema_version"], checks["expected_version"] = version, expected
+		} else {
+			checks["migrations"] = "ok"
+			checks["schema_version"] = version
+		}
+	}
+
+	status, state := fiber.StatusOK, "ready"
+	if !ready {
+		status, state = fiber.StatusServiceUnavailable, "unavailable"
+	}
+	return c.Status(status).JSON(fiber.Map{"status": state, "checks": checks})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/database"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+func newHealthApp(db *gorm.DB) *fiber.App {
+	h := newHandler(db)
+	app := fiber.New()
+	app.Get("/healthz", Liveness)
+	app.Get("/readyz", h.Readiness)
+	return app
+}
+
+func markMigrated(t *testing.T, db *gorm.DB, version int) {
+	t.Helper()
+
+	if err := db.AutoMigrate(&database.SchemaMigration{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&database.SchemaMigration{Version: version, Name: "test", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readiness(t *testing.T, app *fiber.App) (int, map[string]interface{}) {
+	t.Helper()
+
+	status, body := doJSON(t, app, fiber.MethodGet, "/readyz", nil)
+	var got struct {
+		Checks map[string]interface{} `json:"checks"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("readyz body: %s", body)
+	}
+	return status, got.Checks
+}
+
+func TestLivenessIgnoresTheDatabase(t *testing.T) {
+	status, _ := doJSON(t, newHealthApp(nil), fiber.MethodGet, "/healthz", nil)
+	if status != fiber.StatusOK {
+		t.Errorf("status = %d, want %d", status, fiber.StatusOK)
+	}
+}
+
+func TestReadinessChecksSchemaVersion(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newHealthApp(db)
+	latest, err := database.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	markMigrated(t, db, latest-1)
+	if status, checks := readiness(t, app); status != fiber.StatusServiceUnavailable || checks["database"] != "ok" {
+		t.Errorf("behind: status = %d, checks = %v", status, checks)
+	}
+
+	if err := db.Create(&database.SchemaMigration{Version: latest, Name: "test", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, checks := readiness(t, app); status != fiber.StatusOK {
+		t.Errorf("migrated: status = %d, checks = %v", status, checks)
+	}
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	db := testutil.NewDB(t)
+	latest, err := database.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	markMigrated(t, db, latest)
+
+	BeginShutdown()
+	t.Cleanup(func() { draining.Store(false) })
+
+	status, checks := readiness(t, newHealthApp(db))
+	if status != fiber.StatusServiceUnavailable || checks["shutdown"] == nil {
+		t.Errorf("status = %d, checks = %v", status, checks)
+	}
+}
+
+func TestReadinessHidesDatabaseErrors(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newHealthApp(db)
+
+	// No schema_migrations table yet
+	status, checks := readiness(t, app)
+	if status != fiber.StatusServiceUnavailable || checks["migrations"] != "cannot read schema version" {
+		t.Errorf("unmigrated: status = %d, checks = %v", status, checks)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+	status, checks = readiness(t, app)
+	if status != fiber.StatusServiceUnavailable || checks["database"] != "unreachable" {
+		t.Errorf("closed: status = %d, checks = %v", status, checks)
+	}
+}
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/logging"
+	"github.com/m/metrics"
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+// throttlePolicy decides how a key is slowed down and locked. Failures older
+// than window are forgotten.
+type throttlePolicy struct {
+	delayAfter int           // failures before progressive delays start
+	maxDelay   time.Duration // cap for the doubling delay
+	lockAfter  int           // failures that trigger a lockout
+	lockFor    time.Duration
+	window     time.Duration
+}
+
+var (
+	accountThrottle = throttlePolicy{delayAfter: 3, maxDelay: time.Minute, lockAfter: 10, lockFor: 15 * time.Minute, window: 15 * time.Minute}
+	ipThrottle      = throttlePolicy{delayAfter: 10, maxDelay: time.Minute, lockAfter: 30, lockFor: 15 * time.Minute, window: 15 * time.Minute}
+)
+
+const invalidCredentialsMessage = "Invalid email or password"
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type loginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *loginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry in %s", e.RetryAfter)
+}
+
+// dummyPasswordHash is compared against when an email is unknown so the
+// response takes as long as a real password check.
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+func emailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkCredentials verifies an email and password with per-account and per-IP
+// throttling. Unknown emails and wrong passwords both return
+// errInvalidCredentials; a correct password on an account that may not sign
+// in returns services.ErrAccountInactive.
+func (h *Handler) checkCredentials(ctx context.Context, ip, email, password string) (account, error) {
+	emailKey, ipKey := emailThrottleKey(email), ipThrottleKey(ip)
+	now := time.Now()
+
+	if wait := h.throttleWait(emailKey, accountThrottle, now); wait > 0 {
+		return account{}, &loginThrottledError{RetryAfter: wait}
+	}
+	if wait := h.throttleWait(ipKey, ipThrottle, now); wait > 0 {
+		return account{}, &loginThrottledError{RetryAfter: wait}
+	}
+
+	acct, err := h.findAccountByEmail(strings.TrimSpace(email))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account{}, err
+	}
+
+	if err != nil {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = utils.HashPassword("not-a-real-password")
+		})
+		utils.VerifyPassword(dummyPasswordHash, password)
+	} else if ok, needsRehash := utils.VerifyPassword(acct.Password, password); ok {
+		h.clearLoginFailures(ctx, emailKey)
+		if needsRehash {
+			h.rehashPassword(ctx, accountModel(acct.SubjectType), acct.ID, password)
+		}
+		if !acct.Active {
+			return account{}, services.ErrAccountInactive
+		}
+		return acct, nil
+	}
+
+	metrics.FailedLogins.Inc()
+	h.recordLoginFailure(ctx, emailKey, accountThrottle, email, ip, now)
+	h.recordLoginFailure(ctx, ipKey, ipThrottle, email, ip, now)
+	return account{}, errInvalidCredentials
+}
+
+// loginError maps checkCredentials errors to the uniform errors shared by
+// every login endpoint.
+func loginError(c *fiber.Ctx, err error) error {
+	var throttled *loginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		return apperr.New(apperr.CodeLoginThrottled, "Too many failed login attempts. Please try again later.")
+	case errors.Is(err, errInvalidCredentials):
+		return apperr.New(apperr.CodeInvalidCredentials, invalidCredentialsMessage)
+	case errors.Is(err, services.ErrAccountInactive):
+		return apperr.New(apperr.CodeAccountInactive, "Account is not active")
+	default:
+		return apperr.Internal("Error logging in", err)
+	}
+}
+
+// throttleWait returns how long the key must wait before its next attempt.
+func (h *Handler) throttleWait(key string, policy throttlePolicy, now time.Time) time.Duration {
+	var throttle models.LoginThrottle
+	if err := h.DB.First(&throttle, "key = ?", key).Error; err != nil {
+		return 0
+	}
+
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if now.Sub(throttle.LastFailureAt) > policy.window || throttle.Failures < policy.delayAfter {
+		return 0
+	}
+
+	if next := throttle.LastFailureAt.Add(policy.delay(throttle.Failures)); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// delay doubles with every failure past delayAfter: 1s, 2s, 4s, ... maxDelay.
+func (p throttlePolicy) delay(failures int) time.Duration {
+	steps := failures - p.delayAfter
+	if steps < 0 {
+		return 0
+	}
+	if steps > 16 {
+		return p.maxDelay
+	}
+	d := time.Second << steps
+	if d > p.maxDelay {
+		return p.maxDelay
+	}
+	return d
+}
+
+// recordLoginFailure counts a failure against key in one upsert, so parallel
+// wrong passwords cannot overwrite each other's counts. Whichever request
+// takes the count to lockAfter sets the lock and records the event.
+func (h *Handler) recordLoginFailure(ctx context.Context, key string, policy throttlePolicy, email, ip string, now time.Time) {
+	// Start counting again once an old lockout or failure streak has passed
+	expired := "login_throttles.last_failure_at < @since OR login_throttles.locked_until < @now"
+	var throttle models.LoginThrottle
+	err := h.DB.Raw(`INSERT INTO login_throttles (key, failures, last_failure_at, locked_until, updated_at)
+		VALUES (@key, 1, @now, NULL, @now)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN `+expired+` THEN 1 ELSE login_throttles.failures + 1 END,
+			locked_until = CASE WHEN `+expired+` THEN NULL ELSE login_throttles.locked_until END,
+			last_failure_at = excluded.last_failure_at,
+			updated_at = excluded.updated_at
+		RETURNING key, failures, last_failure_at, locked_until`,
+		sql.Named("key", key), sql.Named("now", now), sql.Named("since", now.Add(-policy.window)),
+	).Scan(&throttle).Error
+	if err != nil {
+		logging.From(ctx).Error("recording failed login", "key", key, "error", err)
+		return
+	}
+	if throttle.Failures < policy.lockAfter || throttle.LockedUntil != nil {
+		return
+	}
+
+	lockedUntil := now.Add(policy.lockFor)
+	locked := h.DB.Model(&models.LoginThrottle{}).
+		Where("key = ? AND locked_until IS NULL", key).
+		Update("locked_until", lockedUntil)
+	if locked.Error != nil {
+		logging.From(ctx).Error("locking login", "key", key, "error", locked.Error)
+		return
+	}
+	if locked.RowsAffected == 0 {
+		return // another request locked it first
+	}
+
+	event := models.LockoutEvent{
+		Key:         key,
+		Email:       email,
+		IP:          ip,
+		Failures:    throttle.Failures,
+		LockedUntil: lockedUntil,
+	}
+	if err := h.DB.Create(&event).Error; err != nil {
+		logging.From(ctx).Error("recording lockout event", "key", key, "error", err)
+	}
+	metrics.Lockouts.Inc()
+	logging.From(ctx).Warn("login locked", "key", key, "locked_until", lockedUntil, "failures", throttle.Failures)
+}
+
+func (h *Handler) clearLoginFailures(ctx context.Context, key string) {
+	if err := h.DB.Delete(&models.LoginThrottle{}, "key = ?", key).Error; err != nil {
+		logging.From(ctx).Error("clearing failed logins", "key", key, "error", err)
+	}
+}
+
+// LockoutListing is how lockout events can be filtered and sorted.
+var LockoutListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"email": {Column: "email"},
+		"ip":    {Column: "ip"},
+	},
+	Sorts: map[string]string{
+		"id":           "id",
+		"created_at":   "created_at",
+		"locked_until": "locked_until",
+	},
+	DefaultSort: "-created_at",
+	DateColumn:  "created_at",
+}
+
+// GetLockouts lists lockout events, newest first. ?active=true limits the
+// list to lockouts that are still in force.
+func (h *Handler) GetLockouts(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), LockoutListing)
+	if err != nil {
+		return err
+	}
+
+	query := h.DB
+	if c.QueryBool("active") {
+		query = query.Where("unlocked_at IS NULL AND locked_until > ?", time.Now())
+	}
+
+	events, err := listing.Find[models.LockoutEvent](query, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch lockouts", err)
+	}
+
+	return c.JSON(events)
+}
+
+// UnlockLogin lifts a lockout for an email or IP address.
+func (h *Handler) UnlockLogin(c *fiber.Ctx) error {
+	var req UnlockLoginRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	var keys []string
+	if req.Email != "" {
+		keys = append(keys, emailThrottleKey(req.Email))
+	}
+	if req.IP != "" {
+		keys = append(keys, ipThrottleKey(req.IP))
+	}
+
+	_, _, adminID, _ := tokenSubject(c)
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.LoginThrottle{}, "key IN ?", keys).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.LockoutEvent{}).
+			Where("key IN ? AND unlocked_at IS NULL", keys).
+			Updates(map[string]interface{}{"unlocked_at": time.Now(), "unlocked_by": adminID}).Error
+		if err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, audit.ActorOf(c), audit.ActionDelete, "login_lockout", strings.Join(keys, ","), req, nil)
+	})
+	if err != nil {
+		return apperr.Internal("Failed to unlock login", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Login unlocked"})
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+var testThrottle = throttlePolicy{delayAfter: 3, maxDelay: time.Minute, lockAfter: 5, lockFor: 15 * time.Minute, window: 15 * time.Minute}
+
+func throttleRow(t *testing.T, db *gorm.DB, key string) models.LoginThrottle {
+	t.Helper()
+
+	var throttle models.LoginThrottle
+	if err := db.First(&throttle, "key = ?", key).Error; err != nil {
+		t.Fatal(err)
+	}
+	return throttle
+}
+
+func TestThrottleWait(t *testing.T) {
+	now := time.Now()
+	later := now.Add(10 * time.Minute)
+	for name, tc := range map[string]struct {
+		row  *models.LoginThrottle
+		want time.Duration
+	}{
+		"no failures":        {nil, 0},
+		"below delayAfter":   {&models.LoginThrottle{Failures: 2, LastFailureAt: now}, 0},
+		"first delay":        {&models.LoginThrottle{Failures: 3, LastFailureAt: now}, time.Second},
+		"doubling delay":     {&models.LoginThrottle{Failures: 5, LastFailureAt: now}, 4 * time.Second},
+		"capped delay":       {&models.LoginThrottle{Failures: 40, LastFailureAt: now}, time.Minute},
+		"delay served":       {&models.LoginThrottle{Failures: 4, LastFailureAt: now.Add(-3 * time.Second)}, 0},
+		"streak out of date": {&models.LoginThrottle{Failures: 4, LastFailureAt: now.Add(-time.Hour)}, 0},
+		"locked":             {&models.LoginThrottle{Failures: 5, LastFailureAt: now, LockedUntil: &later}, 10 * time.Minute},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			h := newHandler(db)
+			if tc.row != nil {
+				tc.row.Key = "email:ana@example.com"
+				if err := db.Create(tc.row).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := h.throttleWait("email:ana@example.com", testThrottle, now); got != tc.want {
+				t.Errorf("wait = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoginFailuresLockAtTheThreshold(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	key := emailThrottleKey("Ana@Example.com")
+	now := time.Now()
+
+	for i := 0; i < testThrottle.lockAfter-1; i++ {
+		h.recordLoginFailure(context.Background(), key, testThrottle, "ana@example.com", "10.0.0.1", now)
+	}
+	if row := throttleRow(t, db, key); row.Failures != 4 || row.LockedUntil != nil {
+		t.Fatalf("after 4 failures = %+v", row)
+	}
+
+	h.recordLoginFailure(context.Background(), key, testThrottle, "ana@example.com", "10.0.0.1", now)
+	row := throttleRow(t, db, key)
+	if row.Failures != 5 || row.LockedUntil == nil {
+		t.Fatalf("after 5 failures = %+v", row)
+	}
+	if wait := h.throttleWait(key, testThrottle, now); wait != testThrottle.lockFor {
+		t.Errorf("wait = %s, want %s", wait, testThrottle.lockFor)
+	}
+	var events []models.LockoutEvent
+	db.Find(&events)
+	if len(events) != 1 || events[0].Key != key || events[0].IP != "10.0.0.1" || events[0].Failures != 5 {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestLoginFailuresResetAfterTheWindow(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	key := emailThrottleKey("ana@example.com")
+	past := time.Now().Add(-time.Hour)
+	locked := past.Add(testThrottle.lockFor)
+	if err := db.Create(&models.LoginThrottle{Key: key, Failures: 9, LastFailureAt: past, LockedUntil: &locked}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h.recordLoginFailure(context.Background(), key, testThrottle, "ana@example.com", "10.0.0.1", time.Now())
+	if row := throttleRow(t, db, key); row.Failures != 1 || row.LockedUntil != nil {
+		t.Errorf("after an expired streak = %+v", row)
+	}
+}
+
+func TestConcurrentLoginFailuresAreAllCounted(t *testing.T) {
+	db := testutil.NewFileDB(t)
+	h := newHandler(db)
+	key := ipThrottleKey("10.0.0.9")
+	now := time.Now()
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.recordLoginFailure(context.Background(), key, testThrottle, "", "10.0.0.9", now)
+		}()
+	}
+	wg.Wait()
+
+	if row := throttleRow(t, db, key); row.Failures != attempts || row.LockedUntil == nil {
+		t.Errorf("row = %+v, want %d failures and a lock", row, attempts)
+	}
+	var events int64
+	db.Model(&models.LockoutEvent{}).Count(&events)
+	if events != 1 {
+		t.Errorf("lockout events = %d, want 1", events)
+	}
+}
+
+func TestUnlockLoginLiftsTheLockout(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Use(testutil.AsUser(1, "admin"))
+	app.Get("/api/admin/lockouts", h.GetLockouts)
+	app.Post("/api/admin/lockouts/unlock", h.UnlockLogin)
+
+	now := time.Now()
+	for _, key := range []string{emailThrottleKey("ana@example.com"), emailThrottleKey("ben@example.com")} {
+		for i := 0; i < testThrottle.lockAfter; i++ {
+			h.recordLoginFailure(context.Background(), key, testThrottle, key[len("email:"):], "10.0.0.1", now)
+		}
+	}
+
+	active := func() []models.LockoutEvent {
+		t.Helper()
+		status, resp := doJSON(t, app, fiber.MethodGet, "/api/admin/lockouts?active=true", nil)
+		if status != fiber.StatusOK {
+			t.Fatalf("status = %d: %s", status, resp)
+		}
+		var page listing.Page[models.LockoutEvent]
+		if err := json.Unmarshal(resp, &page); err != nil {
+			t.Fatal(err)
+		}
+		return page.Data
+	}
+	if got := active(); len(got) != 2 {
+		t.Fatalf("active lockouts = %+v, want 2", got)
+	}
+
+	if status, resp := doJSON(t, app, fiber.MethodPost, "/api/admin/lockouts/unlock", fiber.Map{}); status != fiber.StatusBadRequest {
+		t.Errorf("empty unlock: status = %d: %s", status, resp)
+	}
+	status, resp := doJSON(t, app, fiber.MethodPost, "/api/admin/lockouts/unlock", fiber.Map{"email": "ANA@example.com"})
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+
+	got := active()
+	if len(got) != 1 || got[0].Email != "ben@example.com" {
+		t.Errorf("active lockouts = %+v, want only ben's", got)
+	}
+	if wait := h.throttleWait(emailThrottleKey("ana@example.com"), testThrottle, now); wait != 0 {
+		t.Errorf("ana still waits %s", wait)
+	}
+	var lifted models.LockoutEvent
+	db.First(&lifted, "key = ?", emailThrottleKey("ana@example.com"))
+	if lifted.UnlockedAt == nil || lifted.UnlockedBy != 1 {
+		t.Errorf("lifted event = %+v", lifted)
+	}
+}
@@ -2,233 +2,189 @@ package controllers
 
 import (
 	// "fmt"
+	"errors"
 	"strconv"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	// "github.com/golang-jwt/jwt/v4"
-	"github.com/m/database"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
 	"github.com/m/models"
+	"github.com/m/services"
 )
 
-func CreateOrder(c *fiber.Ctx) error {
-	var order models.Order
-
-	if err := c.BodyParser(&order); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order data"})
+func (h *Handler) CreateOrder(c *fiber.Ctx) error {
+	var req CreateOrderRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
-	order.Status = "pending"
-	order.OrderDate = time.Now()
 
-	var product models.Product
-	if err := database.DB.First(&product, order.ProductID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	_, _, adminID, _ := tokenSubject(c)
+	order, err := h.Orders.Create(audit.ActorOf(c), models.Order{
+		AdminID:     adminID,
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		Descriptiom: req.Description,
+	})
+	if errors.Is(err, services.ErrNotFound) {
+		return apperr.NotFound("Product not found")
 	}
-
-	if product.Quantity < order.Quantity {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient product stock"})
+	var insufficient *services.InsufficientStockError
+	if errors.As(err, &insufficient) {
+		return apperr.New(apperr.CodeInsufficientStock, "Insufficient product stock")
 	}
-
-	order.ProductName = product.Name
-	order.Price = product.Price
-
-	if err := database.DB.Create(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create order"})
+	if err != nil {
+		return apperr.Internal("Failed to create order", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(order)
 }
 
-func GetOrders(c *fiber.Ctx) error {
-	var orders []models.Order
+func (h *Handler) GetOrders(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.OrderListing)
+	if err != nil {
+		return err
+	}
 
-	database.DB.Find(&orders)
+	orders, err := h.Orders.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch orders", err)
+	}
 
 	return c.JSON(orders)
 }
 
-func GetOrder(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var order models.Order
-
-	database.DB.First(&order, id)
-
-	if order.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Order not found",
-		})
+func (h *Handler) GetOrder(c *fiber.Ctx) error {
+	order, err := h.Orders.Get(paramID(c.Params("id")))
+	if err != nil {
+		return apperr.NotFound("Order not found")
 	}
 
 	return c.JSON(order)
 }
 
-func UpdateOrder(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var order models.Order
-
+func (h *Handler) UpdateOrder(c *fiber.Ctx) error {
 	// Find the existing order
-	database.DB.First(&order, id)
-
-	if order.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Order not found",
-		})
+	order, err := h.Orders.Get(paramID(c.Params("id")))
+	if err != nil {
+		return apperr.NotFound("Order not found")
 	}
 
-	// Parse the new order data
-	if err := c.BodyParser(&order); err != nil {
+	// Apply the new order data
+	var req UpdateOrderRequest
+	if err := bind(c, &req); err != nil {
 		return err
 	}
+	req.apply(&order)
 
-	// Fetch the product details from the database
-	var product models.Product
-	if err := database.DB.First(&product, order.ProductID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
-	}
-
-	// Check if the order quantity exceeds available product stock
-	if order.Quantity > product.Quantity {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Insufficient product stock",
-		})
-	}
-
-	// Update the product stock by deducting the ordered quantity
-	product.Quantity -= order.Quantity
-	if err := database.DB.Save(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product stock"})
+	// Save the order and take its quantity from the product stock
+	change, err := h.Orders.Update(audit.ActorOf(c), order)
+	var insufficient *services.InsufficientStockError
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return apperr.NotFound("Product not found")
+	case errors.As(err, &insufficient):
+		return apperr.New(apperr.CodeInsufficientStock, "Insufficient product stock")
+	case err != nil:
+		return apperr.Internal("Failed to update order", err)
 	}
 
-	// Save the updated order
-	database.DB.Save(&order)
-
 	// Return the updated order
-	return c.JSON(order)
+	return c.JSON(change.After)
 }
 
-func DeleteOrder(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var order models.Order
-
-	database.DB.First(&order, id)
-
-	if order.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Order not found",
-		})
+func (h *Handler) DeleteOrder(c *fiber.Ctx) error {
+	_, err := h.Orders.Delete(audit.ActorOf(c), paramID(c.Params("id")))
+	if errors.Is(err, services.ErrNotFound) {
+		return apperr.NotFound("Order not found")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to delete order", err)
 	}
-
-	database.DB.Delete(&order)
 
 	return c.JSON(fiber.Map{
 		"message": "Order deleted",
 	})
 }
-func ConfirmOrder(c *fiber.Ctx) error {
-	var requestBody struct {
-		SupplierID uint `json:"supplier_id"` // supplier_id from the request body
-	}
 
-	// Parse the request body to get the supplier_id
-	if err := c.BodyParser(&requestBody); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+// ConfirmOrder marks a pending order "verified". Only the order's own
+// supplier, as named by the token, can confirm it.
+func (h *Handler) ConfirmOrder(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only the order's supplier can confirm it")
 	}
 
-	id := c.Params("id") // Get the order ID from the URL parameters
-	var order models.Order
-
-	// Find the order by ID
-	if err := database.DB.First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
-	}
-
-	// Ensure the order is in "pending" status before confirmation
-	if order.Status != "pending" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Order already confirmed or completed"})
-	}
-
-	// Check if the supplier is the one who created the order
-	if requestBody.SupplierID != order.SupplierID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to confirm this order"})
-	}
-
-	// Update the order status to "verified"
-	order.Status = "verified"
-	order.UpdatedAt = time.Now()
-
-	// Save the updated order
-	if err := database.DB.Save(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to confirm order"})
+	change, err := h.Orders.Confirm(audit.ActorOf(c), paramID(c.Params("id")), supplierID)
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return apperr.NotFound("Order not found")
+	case errors.Is(err, services.ErrOrderNotPending):
+		return apperr.New(apperr.CodeInvalidState, "Order already confirmed or completed")
+	case errors.Is(err, services.ErrNotOrderSupplier):
+		return apperr.Forbidden("You are not authorized to confirm this order")
+	case err != nil:
+		return apperr.Internal("Failed to confirm order", err)
 	}
 
 	// Return the updated order
-	return c.JSON(order)
+	return c.JSON(change.After)
 }
 
-func GetSupplierOrders(c *fiber.Ctx) error {
+// GetSupplierOrders lists the orders of the supplier in the URL. Suppliers
+// can only list their own.
+func (h *Handler) GetSupplierOrders(c *fiber.Ctx) error {
 	// Get the supplier_id from the URL parameters
-	supplierIDParam := c.Params("supplier_id")
+	supplierID, err := strconv.Atoi(c.Params("supplier_id"))
+	if err != nil || supplierID < 0 {
+		return apperr.BadRequest("Invalid supplier ID")
+	}
+	if own, ok := callerSupplierID(c); ok && own != uint(supplierID) {
+		return apperr.Forbidden("You can only list your own orders")
+	}
 
-	// Convert supplier_id to an integer (or uint)
-	supplierID, err := strconv.Atoi(supplierIDParam)
+	opts, err := listing.Parse(c.Queries(), services.OrderListing)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier ID"})
+		return err
 	}
 
-	// Fetch orders related to the supplier_id from the database
-	var orders []models.Order
-	if err := database.DB.Where("supplier_id = ?", supplierID).Find(&orders).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch orders"})
+	// Fetch orders related to the supplier_id
+	orders, err := h.Orders.ListBySupplier(uint(supplierID), opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch orders", err)
 	}
 
 	// Return the fetched orders
 	return c.JSON(orders)
 }
-func ConfirmOrders(c *fiber.Ctx) error {
-	supplierID := c.Locals("supplier_id").(uint)
-	id := c.Params("id")
-
-	var order models.Order
-	if err := database.DB.First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
-	}
-
-	if order.Status != "pending" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Order already confirmed or completed"})
-	}
-
-	if supplierID != order.SupplierID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Unauthorized to confirm this order"})
-	}
-
-	// ✅ Fetch the associated product
-	var product models.Product
-	if err := database.DB.First(&product, order.ProductID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Associated product not found"})
-	}
-
-	// ✅ Check stock before reducing
-	if product.Quantity < order.Quantity {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient stock to confirm the order"})
-	}
-
-	// ✅ Subtract order quantity from product stock
-	product.Quantity -= order.Quantity
-
-	// ✅ Save updated product
-	if err := database.DB.Save(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product quantity"})
-	}
-
-	// ✅ Update order status
-	order.Status = "verified"
-	order.UpdatedAt = time.Now()
-
-	if err := database.DB.Save(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to confirm order"})
-	}
-
-	return c.JSON(order)
+func (h *Handler) ConfirmOrders(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only the order's supplier can confirm it")
+	}
+
+	// Confirm the order and take its quantity from the product stock
+	change, err := h.Orders.ConfirmAndDeduct(audit.ActorOf(c), paramID(c.Params("id")), supplierID)
+
+	var notFound *services.NotFoundError
+	var insufficient *services.InsufficientStockError
+	switch {
+	case errors.As(err, &notFound) && notFound.Entity == "order":
+		return apperr.NotFound("Order not found")
+	case errors.Is(err, services.ErrOrderNotPending):
+		return apperr.New(apperr.CodeInvalidState, "Order already confirmed or completed")
+	case errors.Is(err, services.ErrNotOrderSupplier):
+		return apperr.Forbidden("Unauthorized to confirm this order")
+	case errors.As(err, &notFound):
+		return apperr.NotFound("Associated product not found")
+	case errors.As(err, &insufficient):
+		return apperr.New(apperr.CodeInsufficientStock, "Insufficient stock to confirm the order")
+	case err != nil:
+		return apperr.Internal("Failed to confirm order", err)
+	}
+
+	return c.JSON(change.After)
 }
 
 // func ConfirmOrders(c *fiber.Ctx) error {
@@ -258,13 +214,21 @@ func ConfirmOrders(c *fiber.Ctx) error {
 // 	return c.JSON(order)
 // }
 
-func GetSupplierOrder(c *fiber.Ctx) error {
-	supplierID := c.Locals("supplier_id").(uint)
+func (h *Handler) GetSupplierOrder(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only suppliers have their own orders")
+	}
+
+	opts, err := listing.Parse(c.Queries(), services.OrderListing)
+	if err != nil {
+		return err
+	}
 
 	// Fetch all orders for the given supplier
-	var orders []models.Order
-	if err := database.DB.Where("supplier_id = ?", supplierID).Find(&orders).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch orders"})
+	orders, err := h.Orders.ListBySupplier(supplierID, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch orders", err)
 	}
 
 	return c.JSON(orders)
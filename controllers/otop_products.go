@@ -3,41 +3,25 @@ package controllers
 import (
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 
 	// "github.com/golang-jwt/jwt/v4"
-	"github.com/m/database"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/metrics"
 	"github.com/m/models"
+	"github.com/m/services"
 	// "gorm.io/gorm"
 )
 
-func GetTopSoldProducts(c *fiber.Ctx) error {
-	type ProductSales struct {
-		ProductID    uint    `json:"product_id"`
-		Name         string  `json:"name"`
-		QuantitySold int     `json:"quantity_sold"`
-		Price        float64 `json:"price"`
-		TotalAmount  float64 `json:"total_amount"`
-	}
-
-	var topProducts []ProductSales
-
-	// Join SoldItems and Products, group by ProductID, and order by total quantity sold
-	if err := database.DB.Table("sold_items").
-		Select("sold_items.product_id, products.name, products.price, SUM(sold_items.quantity_sold) as quantity_sold, SUM(sold_items.quantity_sold * products.price) as total_amount").
-		Joins("JOIN products ON products.id = sold_items.product_id").
-		Group("sold_items.product_id, products.name, products.price").
-		Order("quantity_sold DESC").
-		Limit(3).
-		Scan(&topProducts).Error; err != nil {
-		log.Println("Error fetching top sold products:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch top sold products",
-		})
+func (h *Handler) GetTopSoldProducts(c *fiber.Ctx) error {
+	topProducts, err := h.Reporting.TopSoldProducts(3)
+	if err != nil {
+		return apperr.Internal("Failed to fetch top sold products", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -45,46 +29,15 @@ func GetTopSoldProducts(c *fiber.Ctx) error {
 	})
 }
 
-// This is the function that call the every handler it should be store on the controller folder
-func CallUpdateOtopProduct(p models.OtopProducts) error {
-	// Use GORM to update the product
-	result := database.DB.Model(&models.OtopProducts{}).Where("supplier_id = ? AND store_name = ?", p.SupplierID, p.StoreName).
-		Updates(models.OtopProducts{
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-			Category:    p.Category,
-			Quantity:    p.Quantity,
-		})
-
-	if result.Error != nil {
-		log.Println("Error executing update:", result.Error)
-		return result.Error
-	}
-
-	if result.RowsAffected == 0 {
-		return errors.New("no rows were updated, please check the supplier_id and store_name")
-	}
-
-	return nil
-}
-
 // Fiber handler that call all the fucntion from the contorller this will be put inside the handler folder
-func UpdateOtopProductHandler(c *fiber.Ctx) error {
-	var updateReq models.OtopProducts
-
-	// Parse the request body into the OtopProducts model
-	if err := c.BodyParser(&updateReq); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+func (h *Handler) UpdateOtopProductHandler(c *fiber.Ctx) error {
+	var req UpdateStoreProductsRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	// Call the update function
-	if err := CallUpdateOtopProduct(updateReq); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update product",
-		})
+	if _, err := h.Inventory.UpdateByStore(audit.ActorOf(c), req.model()); err != nil {
+		return apperr.Internal("Failed to update product", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -92,146 +45,81 @@ func UpdateOtopProductHandler(c *fiber.Ctx) error {
 	})
 }
 
-func CreateOtopProduct(c *fiber.Ctx) error {
-	var otopProduct models.OtopProducts
-
-	// Parse the request body
-	if err := c.BodyParser(&otopProduct); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product data"})
-	}
-
-	// Validate description
-	if otopProduct.Description == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Description is required"})
+func (h *Handler) CreateOtopProduct(c *fiber.Ctx) error {
+	var req CreateOtopProductRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	// Check for duplicate description
-	var existingProduct models.OtopProducts
-	if err := database.DB.Where("description = ?", otopProduct.Description).First(&existingProduct).Error; err == nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Description must be unique"})
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	product, _, err := h.Inventory.Create(audit.ActorOf(c), req.model())
+	switch {
+	case errors.Is(err, services.ErrDuplicate):
+		return apperr.Conflict("Description must be unique")
+	case errors.Is(err, services.ErrNotFound):
+		return apperr.NotFound("Store not found")
+	case err != nil:
+		return apperr.Internal("Failed to create product", err)
 	}
 
-	// Fetch the supplier by store_name
-	var supplier models.Supplier
-	if err := database.DB.Where("store_name = ?", otopProduct.StoreName).First(&supplier).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
-	}
-
-	// Increment the Purchased count
-	supplier.Purchased += 1
-	if err := database.DB.Save(&supplier).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update purchased count"})
-	}
+	return c.Status(fiber.StatusCreated).JSON(product)
+}
 
-	var lastProduct models.OtopProducts
-	err := database.DB.Raw("SELECT * FROM otop_products ORDER BY created_at DESC LIMIT 1").Scan(&lastProduct).Error
+func (h *Handler) GetOtopProducts(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.OtopProductListing)
 	if err != nil {
-		log.Println("Failed to fetch last product:", err)
-	}
-
-	// Generate new sequential number
-	seqNumber := 1
-	if lastProduct.ID != 0 {
-		// Extract the sequential number from the last product's sequential_number
-		// Assuming format is like 'SEQ-0001'
-		fmt.Sscanf(lastProduct.SequentialNumber, "SP-%04d", &seqNumber)
-		seqNumber++
-	}
-	otopProduct.SequentialNumber = fmt.Sprintf("SP-%04d", seqNumber)
-
-	// Assign the SupplierID from the fetched supplier
-	otopProduct.SupplierID = supplier.ID
-
-	// Save the product
-	otopProduct.CreatedAt = time.Now()
-	if err := database.DB.Create(&otopProduct).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create product"})
-	}
-
-	// Preload the related supplier data and return the product
-	if err := database.DB.Preload("Supplier").First(&otopProduct, otopProduct.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch related supplier"})
+		return err
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(otopProduct)
-}
-
-func GetOtopProducts(c *fiber.Ctx) error {
-	var otopProducts []models.OtopProducts
-
-	// Use Preload to fetch related Supplier data
-	if err := database.DB.Preload("Supplier").Find(&otopProducts).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+	// Products come with their supplier
+	otopProducts, err := h.Inventory.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
 	}
 
-	// Return the products with related supplier data as JSON
 	return c.JSON(otopProducts)
 }
 
-func GetProduct(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var otopProduct models.OtopProducts
-
-	// Fetch the product by ID
-	database.DB.First(&otopProduct, id)
-
-	if otopProduct.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+func (h *Handler) GetProduct(c *fiber.Ctx) error {
+	otopProduct, err := h.Inventory.Get(paramID(c.Params("id")))
+	if err != nil {
+		return apperr.NotFound("Product not found")
 	}
 
 	// Return the product as JSON
 	return c.JSON(otopProduct)
 }
 
-func UpdateOtopProduct(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var otopProduct models.OtopProducts
-
+func (h *Handler) UpdateOtopProduct(c *fiber.Ctx) error {
 	// Find the existing product
-	database.DB.First(&otopProduct, id)
-
-	if otopProduct.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+	otopProduct, err := h.Inventory.Get(paramID(c.Params("id")))
+	if err != nil {
+		return apperr.NotFound("Product not found")
 	}
 
-	// Parse the new product data
-	if err := c.BodyParser(&otopProduct); err != nil {
+	// Apply the new product data
+	var req UpdateOtopProductRequest
+	if err := bind(c, &req); err != nil {
 		return err
 	}
+	req.apply(&otopProduct)
 
 	// Save the updated product
-	if err := database.DB.Save(&otopProduct).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
+	if err := h.Inventory.Save(audit.ActorOf(c), &otopProduct); err != nil {
+		return apperr.Internal("Failed to update product", err)
 	}
 
 	// Return the updated product
 	return c.JSON(otopProduct)
 }
 
-func DeleteOtopProduct(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var otopProduct models.OtopProducts
-
-	// Find the product by ID
-	database.DB.First(&otopProduct, id)
-
-	if otopProduct.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Product not found",
-		})
+func (h *Handler) DeleteOtopProduct(c *fiber.Ctx) error {
+	_, err := h.Inventory.Delete(audit.ActorOf(c), paramID(c.Params("id")))
+	if errors.Is(err, services.ErrNotFound) {
+		return apperr.NotFound("Product not found")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to delete product", err)
 	}
-
-	// Delete the product
-	database.DB.Delete(&otopProduct)
 
 	// Return a success message
 	return c.JSON(fiber.Map{
@@ -239,115 +127,56 @@ func DeleteOtopProduct(c *fiber.Ctx) error {
 	})
 }
 
-func GetOtopTotalQuantity(c *fiber.Ctx) error {
-	log.Println("Received request to calculate total quantity of products")
-
-	var totalQuantity int64
-	err := database.DB.Model(&models.OtopProducts{}).Select("SUM(quantity)").Scan(&totalQuantity).Error
-
+func (h *Handler) GetOtopTotalQuantity(c *fiber.Ctx) error {
+	totalQuantity, err := h.Inventory.TotalQuantity()
 	if err != nil {
-		log.Println("Error calculating total quantity:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate total quantity"})
+		return apperr.Internal("Failed to calculate total quantity", err)
 	}
 
-	log.Println("Total quantity calculated:", totalQuantity)
 	return c.JSON(fiber.Map{"total_quantity": totalQuantity})
 }
 
-func GetOtopProductByID(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var product models.OtopProducts
-
-	// Fetch the product by ID
-	if err := database.DB.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Product not found",
-		})
-	}
-
-	// Return the product as JSON
-	return c.JSON(product)
+func (h *Handler) GetOtopProductByID(c *fiber.Ctx) error {
+	return h.GetProduct(c)
 }
 
-func GetOtopTotalQuantityName(c *fiber.Ctx) error {
-	log.Println("Received request to calculate total quantity of products by name")
-
-	var result []struct {
-		ProductName   string `json:"product_name"`
-		TotalQuantity int64  `json:"total_quantity"`
-	}
-
-	err := database.DB.Model(&models.OtopProducts{}).
-		Select("name as product_name, SUM(quantity) as total_quantity").
-		Group("name").
-		Scan(&result).Error
-
+func (h *Handler) GetOtopTotalQuantityName(c *fiber.Ctx) error {
+	result, err := h.Inventory.QuantityByName()
 	if err != nil {
-		log.Println("Error calculating total quantity by product name:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate total quantity"})
+		return apperr.Internal("Failed to calculate total quantity", err)
 	}
 
-	log.Println("Total quantity calculated by product name:", result)
 	return c.JSON(result)
 }
 
-func GetOtopTotalProducts(c *fiber.Ctx) error {
-	log.Println("Received request to calculate total number of unique products")
-
-	var result struct {
-		TotalProducts int64 `json:"total_products"`
-	}
-
-	err := database.DB.Model(&models.OtopProducts{}).
-		Select("COUNT(DISTINCT name) as total_products").
-		Scan(&result).Error
-
+func (h *Handler) GetOtopTotalProducts(c *fiber.Ctx) error {
+	total, err := h.Inventory.DistinctProductCount()
 	if err != nil {
-		log.Println("Error calculating total number of products:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate total products"})
+		return apperr.Internal("Failed to calculate total products", err)
 	}
 
-	log.Println("Total number of products calculated:", result.TotalProducts)
-	return c.JSON(result)
+	return c.JSON(fiber.Map{"total_products": total})
 }
 
-func GetTotalProductsByCategory(c *fiber.Ctx) error {
-	var foodCount, nonFoodCount int64
-
-	if err := database.DB.Model(&models.OtopProducts{}).Where("category = ?", "Food").Count(&foodCount).Error; err != nil {
-		log.Println("Error counting food products:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count food products"})
-	}
-
-	if err := database.DB.Model(&models.OtopProducts{}).Where("category = ?", "Non-Food").Count(&nonFoodCount).Error; err != nil {
-		log.Println("Error counting non-food products:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count non-food products"})
+func (h *Handler) GetTotalProductsByCategory(c *fiber.Ctx) error {
+	counts, err := h.Inventory.CountByCategory()
+	if err != nil {
+		return apperr.Internal("Failed to count products by category", err)
 	}
 
-	return c.JSON(fiber.Map{
-		"Food":     foodCount,
-		"Non-Food": nonFoodCount,
-	})
-
+	return c.JSON(counts)
 }
 
-func GetTotalPurchasedBySupplierID(c *fiber.Ctx) error {
+func (h *Handler) GetTotalPurchasedBySupplierID(c *fiber.Ctx) error {
 	// Get Supplier ID from the request params
 	supplierID := c.Params("id")
 	if supplierID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier ID is required"})
+		return apperr.BadRequest("Supplier ID is required")
 	}
 
-	var totalPurchased float64
-
-	// Query to calculate total purchased for the given supplier ID
-	err := database.DB.Model(&models.OtopProducts{}).
-		Where("supplier_id = ?", supplierID).
-		Select("SUM(price * quantity * purchased)"). // Include the purchased field in the calculation
-		Scan(&totalPurchased).Error
-
+	totalPurchased, err := h.Reporting.TotalPurchasedBySupplier(supplierID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database query error"})
+		return apperr.Internal("Database query error", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -357,71 +186,39 @@ func GetTotalPurchasedBySupplierID(c *fiber.Ctx) error {
 }
 
 // the correct one to handle POS
-func RecordSoldItem(c *fiber.Ctx) error {
-	var soldItems []models.SoldItems
+func (h *Handler) RecordSoldItem(c *fiber.Ctx) error {
 	var responses []map[string]interface{}
 
-	// Parse the request body (expecting a JSON array)
-	if err := c.BodyParser(&soldItems); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid sold items data",
-		})
+	// The request body is a JSON array of sold items
+	var req []SoldItemRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	for _, item := range soldItems {
-		var otopProduct models.OtopProducts
-
-		// Fetch product using ProductID
-		if err := database.DB.First(&otopProduct, item.ProductID).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": fmt.Sprintf("Product with ID %d not found", item.ProductID),
-			})
-		}
-
-		// Check stock
-		if otopProduct.Quantity < item.QuantitySold {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("Insufficient quantity for product ID %d", item.ProductID),
-			})
-		}
-
-		// Set total amount and sold date
-		item.TotalAmount = float64(item.QuantitySold) * otopProduct.Price
-		item.SoldDate = time.Now()
+	soldItems := make([]models.SoldItems, 0, len(req))
+	for _, item := range req {
+		soldItems = append(soldItems, models.SoldItems{ProductID: item.ProductID, QuantitySold: item.Quantity})
+	}
 
-		// Set SupplierID from product
-		item.SupplierID = otopProduct.SupplierID
+	// All items are recorded or none are
+	results, err := h.Sales.RecordSoldItems(audit.ActorOf(c), soldItems)
 
-		// Reduce product stock
-		otopProduct.Quantity -= item.QuantitySold
-		if err := database.DB.Save(&otopProduct).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update product quantity",
-			})
-		}
-
-		// Save the sold item
-		if err := database.DB.Create(&item).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to record sold item",
-			})
-		}
-
-		// Reload the sold item with preloaded product and supplier
-		var fullSoldItem models.SoldItems
-		if err := database.DB.
-			Preload("Product").
-			Preload("Product.Supplier").
-			First(&fullSoldItem, item.ID).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to fetch full sold item",
-			})
-		}
+	var notFound *services.NotFoundError
+	var insufficient *services.InsufficientStockError
+	switch {
+	case errors.As(err, &notFound):
+		return apperr.NotFound(fmt.Sprintf("Product with ID %v not found", notFound.ID))
+	case errors.As(err, &insufficient):
+		return apperr.New(apperr.CodeInsufficientStock, fmt.Sprintf("Insufficient quantity for product ID %d", insufficient.ProductID))
+	case err != nil:
+		return apperr.Internal("Failed to record sold item", err)
+	}
 
-		// Append the full response
+	for _, result := range results {
+		metrics.ItemsSold.WithLabelValues("sold_items").Add(float64(result.Item.QuantitySold))
 		responses = append(responses, map[string]interface{}{
-			"soldItem": fullSoldItem,
-			"supplier": fullSoldItem.Product.Supplier,
+			"soldItem": result.Item,
+			"supplier": result.Item.Product.Supplier,
 		})
 	}
 
@@ -499,81 +296,68 @@ func RecordSoldItem(c *fiber.Ctx) error {
 // 	return c.JSON(soldItems)
 // }
 
-func GetAllSoldItems(c *fiber.Ctx) error {
-	var soldItems []models.SoldItems
+func (h *Handler) GetAllSoldItems(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.SoldItemListing)
+	if err != nil {
+		return err
+	}
+	return h.soldItemsPage(c, opts)
+}
 
-	// Fetch all sold items and preload product data
-	if err := database.DB.Preload("Product").Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items",
-		})
+// soldItemsPage responds with a page of sold items and the value of every
+// sold item the filters select, not only those on the page.
+func (h *Handler) soldItemsPage(c *fiber.Ctx, opts listing.Options) error {
+	// Fetch the sold items with their product
+	soldItems, err := h.Sales.ListSoldItems(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch sold items", err)
 	}
 
-	// Calculate overall amount sold
-	var overallAmountSold float64
-	for _, item := range soldItems {
-		overallAmountSold += float64(item.QuantitySold) * item.Product.Price
+	overallAmountSold, err := h.Sales.SoldAmount(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch sold items", err)
 	}
 
 	return c.JSON(fiber.Map{
-		"sold_items":          soldItems,
+		"data":                soldItems.Data,
+		"meta":                soldItems.Meta,
 		"overall_amount_sold": overallAmountSold,
 	})
 }
 
 // GetSoldItemsBySupplierID retrieves sold items filtered by SupplierID
-func GetSoldItemsBySupplierID(c *fiber.Ctx) error {
-	supplierID := c.Params("supplier_id") // Get SupplierID from URL params
+func (h *Handler) GetSoldItemsBySupplierID(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.SoldItemListing)
+	if err != nil {
+		return err
+	}
 
-	var soldItems []models.SoldItems
-	// Fetch sold items by SupplierID
-	if err := database.DB.Where("supplier_id = ?", supplierID).Preload("Product").Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Unable to fetch sold items for the supplier"})
+	supplierID := paramID(c.Params("supplier_id")) // Get SupplierID from URL params
+	soldItems, err := h.Sales.ListSoldItems(opts.Scope("supplier_id", supplierID))
+	if err != nil {
+		return apperr.Internal("Unable to fetch sold items for the supplier", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(soldItems)
 }
 
-func AddToCartHandler(c *fiber.Ctx) error {
-	// Define the request body structure
-	type AddToCartRequest struct {
-		ProductID  int `json:"productID"`
-		SupplierID int `json:"supplierID"`
-		Quantity   int `json:"quantity"`
-	}
-
-	// Parse the request body into AddToCartRequest struct
+func (h *Handler) AddToCartHandler(c *fiber.Ctx) error {
 	var req AddToCartRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request data"})
-	}
-
-	// Check if product ID and supplier ID are valid
-	if req.ProductID == 0 || req.SupplierID == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": fmt.Sprintf("Invalid product or supplier ID: Product ID: %d, Supplier ID: %d", req.ProductID, req.SupplierID),
-		})
-	}
-
-	// Retrieve product by ID
-	var otopProduct models.OtopProducts
-	productResult := database.DB.First(&otopProduct, req.ProductID)
-	if productResult.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
-	}
-
-	// Retrieve supplier by ID
-	var supplier models.Supplier
-	supplierResult := database.DB.First(&supplier, req.SupplierID)
-	if supplierResult.Error != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	// Further validation (optional)
-	if otopProduct.SupplierID != supplier.ID {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": fmt.Sprintf("Product and supplier mismatch: Product SupplierID: %d, Supplier ID: %d", otopProduct.SupplierID, supplier.ID),
-		})
+	err := h.Inventory.CheckCartItem(req.ProductID, req.SupplierID)
+	var notFound *services.NotFoundError
+	switch {
+	case errors.As(err, &notFound) && notFound.Entity == "supplier":
+		return apperr.NotFound("Supplier not found")
+	case errors.As(err, &notFound):
+		return apperr.NotFound("Product not found")
+	case errors.Is(err, services.ErrSupplierMismatch):
+		return apperr.BadRequest("Product and supplier mismatch: " + err.Error())
+	case err != nil:
+		return apperr.Internal("Failed to check product", err)
 	}
 
 	// Logic to add to cart (this part can be customized as needed)
@@ -584,556 +368,248 @@ func AddToCartHandler(c *fiber.Ctx) error {
 	})
 }
 
-func POSController(c *fiber.Ctx) error {
-	type CartItem struct {
-		ProductID uint    `json:"product_id"`
-		Name      string  `json:"name"`
-		Quantity  int64   `json:"quantity"`
-		Price     float64 `json:"price"`
-		Total     float64 `json:"total"`
-	}
-
-	type CheckoutRequest struct {
-		Items    []CartItem `json:"items"`
-		Received float64    `json:"received"`
-		Total    float64    `json:"total"`
-		Change   float64    `json:"change"`
-	}
-
-	var products []models.OtopProducts
-	search := c.Query("search", "")
-
-	// Fetch products along with supplier information
-	if search != "" {
-		database.DB.Preload("Supplier").Where("name LIKE ?", "%"+search+"%").Find(&products)
-	} else {
-		database.DB.Preload("Supplier").Find(&products)
-	}
-
+func (h *Handler) POSController(c *fiber.Ctx) error {
 	// Return available products (GET request)
 	if c.Method() == fiber.MethodGet {
+		opts, err := listing.Parse(c.Queries(), services.OtopProductListing)
+		if err != nil {
+			return err
+		}
+		products, err := h.Inventory.List(opts)
+		if err != nil {
+			return apperr.Internal("Failed to fetch products", err)
+		}
 		return c.JSON(products)
 	}
 
 	// Handle checkout request
 	var request CheckoutRequest
-	if err := c.BodyParser(&request); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid checkout data"})
-	}
-
-	// Validate received amount
-	if request.Received < request.Total {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Received amount is less than the total"})
+	if err := bind(c, &request); err != nil {
+		return err
 	}
 
-	// Process the transaction
-	var supplierIDs = make(map[uint]bool) // To store unique supplier IDs for the items
-	var transactionItems []models.TransactionItem
-
-	// Create the transaction first (without any items yet)
-	transaction := models.Transaction{
-		Total:     request.Total,
+	_, _, cashierID, _ := tokenSubject(c)
+	input := services.CheckoutInput{
+		CashierID: cashierID,
 		Received:  request.Received,
+		Total:     request.Total,
 		Change:    request.Change,
-		CreatedAt: time.Now(),
-	}
-
-	// Iterate over items to validate suppliers
-	for _, item := range request.Items {
-		var product models.OtopProducts
-		if err := database.DB.First(&product, item.ProductID).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Product ID %d not found", item.ProductID)})
-		}
-
-		// Check stock
-		if product.Quantity < item.Quantity {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Insufficient stock for product %s", product.Name)})
-		}
-
-		// Deduct stock
-		product.Quantity -= item.Quantity
-		if err := database.DB.Save(&product).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product stock"})
-		}
-
-		// Add supplierID to the map if it exists (to ensure uniqueness)
-		if product.SupplierID > 0 {
-			supplierIDs[product.SupplierID] = true
-		}
-
-		// Create the transaction item
-		transactionItem := models.TransactionItem{
-			ProductID:     item.ProductID,
-			TransactionID: transaction.ID, // Will be updated later after the transaction is saved
-			Quantity:      item.Quantity,
-			Price:         item.Price,
-			Total:         item.Total,
-		}
-		transactionItems = append(transactionItems, transactionItem)
 	}
-
-	// Check if there are no suppliers for the transaction (if supplierIDs is empty)
-	if len(supplierIDs) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No valid suppliers found for the transaction"})
-	}
-
-	// Use the first supplier (or you can add your own logic to handle multiple suppliers)
-	var supplierID uint
-	for supplierID = range supplierIDs {
-		break
+	if d := request.Discount; d != nil {
+		input.Discount = &services.DiscountClaim{Type: d.Type, IDNumber: strings.TrimSpace(d.IDNumber), Name: strings.TrimSpace(d.Name)}
 	}
-
-	// Assign a valid supplierID to the transaction
-	transaction.SupplierID = supplierID
-
-	// Insert the transaction into the database
-	if err := database.DB.Create(&transaction).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create transaction"})
+	for _, p := range request.Payments {
+		input.Payments = append(input.Payments, services.Tender{Method: p.Method, Amount: p.Amount, Reference: p.Reference})
 	}
-
-	// Now that the transaction is created, we can assign the transaction ID to the items
-	for _, item := range transactionItems {
-		item.TransactionID = transaction.ID
-		if err := database.DB.Create(&item).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save transaction items"})
-		}
+	for _, item := range request.Items {
+		input.Items = append(input.Items, services.CheckoutItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+			Total:     item.Total,
+		})
 	}
 
-	// Insert the unique supplier IDs linked to the transaction (using TransactionSupplier)
-	for supplierID := range supplierIDs {
-		transactionSupplier := models.TransactionSupplier{
-			TransactionID: transaction.ID,
-			SupplierID:    supplierID,
-		}
-		if err := database.DB.Create(&transactionSupplier).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to link suppliers to transaction"})
+	// The sale and its stock changes commit together or not at all
+	result, err := h.Sales.Checkout(audit.ActorOf(c), input)
+	if err != nil {
+		metrics.Checkouts.WithLabelValues("rejected").Inc()
+	}
+
+	var notFound *services.NotFoundError
+	var insufficient *services.InsufficientStockError
+	var mismatch *services.PriceMismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		fields := make([]apperr.FieldError, 0, len(mismatch.Mismatches))
+		for _, m := range mismatch.Mismatches {
+			fields = append(fields, apperr.FieldError{
+				Field:   m.Field,
+				Message: fmt.Sprintf("sent %.2f but the server computes %.2f", m.Sent, m.Expected),
+			})
 		}
-	}
-
-	// Prepare the receipt response with transaction details
+		return apperr.New(apperr.CodePriceMismatch, "Cart prices differ from the current prices; refresh the cart").WithFields(fields...)
+	case errors.Is(err, services.ErrInsufficientPayment):
+		return apperr.New(apperr.CodeInsufficientPayment, "Received amount is less than the total")
+	case errors.Is(err, services.ErrNothingDiscountable):
+		return apperr.Validation(apperr.FieldError{Field: "discount", Message: "no item in the cart qualifies for the senior citizen or PWD discount"})
+	case errors.Is(err, services.ErrNonCashOverpayment):
+		return apperr.Validation(apperr.FieldError{Field: "payments", Message: "card and e-wallet payments cannot exceed the total; only cash is given change"})
+	case errors.Is(err, services.ErrNoOpenShift):
+		return apperr.New(apperr.CodeNoOpenShift, "Open a shift before checking out")
+	case errors.As(err, &notFound):
+		return apperr.NotFound(fmt.Sprintf("Product ID %v not found", notFound.ID))
+	case errors.As(err, &insufficient):
+		return apperr.New(apperr.CodeInsufficientStock, fmt.Sprintf("Insufficient stock for product %s", insufficient.Name))
+	case errors.Is(err, services.ErrNoSuppliers):
+		return apperr.BadRequest("No valid suppliers found for the transaction")
+	case err != nil:
+		return apperr.Internal("Failed to complete checkout", err)
+	}
+
+	transaction := result.Transaction
+
+	metrics.Checkouts.WithLabelValues("completed").Inc()
+	for _, item := range input.Items {
+		metrics.ItemsSold.WithLabelValues("pos").Add(float64(item.Quantity))
+	}
+
+	// The receipt shows the server's figures, never the till's
 	receipt := fiber.Map{
-		"transaction_id": transaction.ID,
-		"date":           transaction.CreatedAt.Format("2006-01-02 15:04:05"),
-		"items":          transactionItems,
-		"total":          request.Total,
-		"received":       request.Received,
-		"change":         request.Change,
+		"transaction_id":     transaction.ID,
+		"shift_id":           transaction.ShiftID,
+		"cashier_id":         transaction.CashierID,
+		"date":               transaction.CreatedAt.Format("2006-01-02 15:04:05"),
+		"items":              result.Items,
+		"subtotal":           transaction.Subtotal,
+		"statutory_discount": transaction.Statutory,
+		"discount":           transaction.Discount,
+		"vatable_sales":      transaction.VatableSales,
+		"vat":                transaction.VAT,
+		"vat_exempt_sales":   transaction.VATExemptSales,
+		"zero_rated_sales":   transaction.ZeroRatedSales,
+		"total":              transaction.Total,
+		"received":           transaction.Received,
+		"change":             transaction.Change,
+		"payments":           transaction.Payments,
 	}
 
 	// Return the receipt response as JSON
 	return c.JSON(receipt)
 }
 
-type SummaryRequest struct {
-	IntervalType string `json:"interval" validate:"required,oneof=daily weekly monthly yearly"`
-}
-
-func GetSalesSummary(c *fiber.Ctx) error {
+// GetSalesSummary reports the sold item amounts for each period. POS takings
+// by tender are served by GetTenderSalesSummary.
+func (h *Handler) GetSalesSummary(c *fiber.Ctx) error {
 	var req SummaryRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	switch req.IntervalType {
-	case "daily":
-		return getDailySummary(c)
-	case "weekly":
-		return getWeeklySummary(c)
-	case "monthly":
-		return getMonthlySummary(c)
-	case "yearly":
-		return getYearlySummary(c)
-	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Interval must be daily, weekly, monthly, or yearly",
-		})
-	}
-}
-
-// get data for daily
-func getDailySummary(c *fiber.Ctx) error {
-	now := time.Now()
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		// Sunday, move to last Monday
-		now = now.AddDate(0, 0, -6)
-	}
-
-	// Start on Monday
-	startOfWeek := now.AddDate(0, 0, -(int(now.Weekday()) - 1))
-	startOfWeek = time.Date(startOfWeek.Year(), startOfWeek.Month(), startOfWeek.Day(), 0, 0, 0, 0, startOfWeek.Location())
-
-	endOfWeek := startOfWeek.AddDate(0, 0, 5).Add(24*time.Hour - time.Nanosecond) // Saturday night
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("created_at BETWEEN ? AND ?", startOfWeek, endOfWeek).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items",
-		})
-	}
-
-	// Group by Day
-	dailySales := map[string]float64{
-		"Monday":    0,
-		"Tuesday":   0,
-		"Wednesday": 0,
-		"Thursday":  0,
-		"Friday":    0,
-		"Saturday":  0,
-	}
-
-	for _, item := range soldItems {
-		day := item.CreatedAt.Weekday().String()
-		if day != "Sunday" {
-			dayName := string(day)
-			dailySales[dayName] += float64(item.QuantitySold) * item.Product.Price
-		}
-	}
-
-	return c.JSON(dailySales)
-}
-
-// get data for the week
-
-func getWeeklySummary(c *fiber.Ctx) error {
-	now := time.Now()
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("created_at >= ?", startOfMonth).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items",
-		})
-	}
-
-	weeklySales := map[string]float64{
-		"Week 1": 0,
-		"Week 2": 0,
-		"Week 3": 0,
-		"Week 4": 0,
-		"Week 5": 0,
-	}
-
-	for _, item := range soldItems {
-		day := item.CreatedAt.Day()
-		var week string
-		switch {
-		case day >= 1 && day <= 7:
-			week = "Week 1"
-		case day >= 8 && day <= 14:
-			week = "Week 2"
-		case day >= 15 && day <= 21:
-			week = "Week 3"
-		case day >= 22 && day <= 28:
-			week = "Week 4"
-		default:
-			week = "Week 5"
-		}
-		weeklySales[week] += float64(item.QuantitySold) * item.Product.Price
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	return c.JSON(weeklySales)
+	summary, err := h.Reporting.SalesSummary(req.IntervalType, time.Now())
+	return summaryResponse(c, summary, err)
 }
 
-// get data for the month
-func getMonthlySummary(c *fiber.Ctx) error {
-	now := time.Now()
-	currentYear := now.Year()
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("EXTRACT(YEAR FROM created_at) = ?", currentYear).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items",
-		})
+// GetTenderSalesSummary reports, for each period, what cash, GCash, Maya and
+// cards took net of change.
+func (h *Handler) GetTenderSalesSummary(c *fiber.Ctx) error {
+	var req SummaryRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	monthlySales := map[string]float64{
-		"January":   0,
-		"February":  0,
-		"March":     0,
-		"April":     0,
-		"May":       0,
-		"June":      0,
-		"July":      0,
-		"August":    0,
-		"September": 0,
-		"October":   0,
-		"November":  0,
-		"December":  0,
+	summary, err := h.Reporting.TenderSummary(req.IntervalType, time.Now())
+	if errors.Is(err, services.ErrInvalidInterval) {
+		return apperr.BadRequest("Interval must be daily, weekly, monthly, or yearly")
 	}
-
-	for _, item := range soldItems {
-		month := item.CreatedAt.Month().String()
-		monthlySales[month] += float64(item.QuantitySold) * item.Product.Price
+	if err != nil {
+		return apperr.Internal("Failed to fetch payments", err)
 	}
-
-	return c.JSON(monthlySales)
+	return c.JSON(summary)
 }
 
-// yearly function for sold items chat
-func getYearlySummary(c *fiber.Ctx) error {
-	now := time.Now()
-	startYear := now.Year() - 4 // last 4 years + current
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("EXTRACT(YEAR FROM created_at) >= ?", startYear).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items",
-		})
+// GetVATSalesSummary is GetSalesSummary as a VAT analysis: for each period,
+// vatable sales, VAT, exempt and zero-rated sales, and what was paid.
+func (h *Handler) GetVATSalesSummary(c *fiber.Ctx) error {
+	var req SummaryRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	yearlySales := make(map[int]float64)
-
-	for i := startYear; i <= now.Year(); i++ {
-		yearlySales[i] = 0
+	summary, err := h.Reporting.VATSummary(req.IntervalType, time.Now())
+	if errors.Is(err, services.ErrInvalidInterval) {
+		return apperr.BadRequest("Interval must be daily, weekly, monthly, or yearly")
 	}
-
-	for _, item := range soldItems {
-		year := item.CreatedAt.Year()
-		if _, ok := yearlySales[year]; ok {
-			yearlySales[year] += float64(item.QuantitySold) * item.Product.Price
-		}
+	if err != nil {
+		return apperr.Internal("Failed to fetch transactions", err)
 	}
-
-	return c.JSON(yearlySales)
+	return c.JSON(summary)
 }
 
-// supplier get summary
-
-type SupplierSalesRequest struct {
-	IntervalType string `json:"interval" validate:"required,oneof=daily weekly monthly yearly"`
-	SupplierID   uint   `json:"supplier_id" validate:"required"`
-}
-
-func GetSupplierSalesSummary(c *fiber.Ctx) error {
+// GetSupplierVATSalesSummary is GetVATSalesSummary for one supplier's lines
+// in POS sales.
+func (h *Handler) GetSupplierVATSalesSummary(c *fiber.Ctx) error {
 	var req SupplierSalesRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	switch req.IntervalType {
-	case "daily":
-		return getSupplierDailySummary(c, req.SupplierID)
-	case "weekly":
-		return getSupplierWeeklySummary(c, req.SupplierID)
-	case "monthly":
-		return getSupplierMonthlySummary(c, req.SupplierID)
-	case "yearly":
-		return getSupplierYearlySummary(c, req.SupplierID)
-	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Interval must be daily, weekly, monthly, or yearly",
-		})
-	}
-}
-
-// Get daily sales for a specific supplier
-func getSupplierDailySummary(c *fiber.Ctx, supplierID uint) error {
-	now := time.Now()
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		// Sunday, move to last Monday
-		now = now.AddDate(0, 0, -6)
-	}
-
-	// Start on Monday
-	startOfWeek := now.AddDate(0, 0, -(int(now.Weekday()) - 1))
-	startOfWeek = time.Date(startOfWeek.Year(), startOfWeek.Month(), startOfWeek.Day(), 0, 0, 0, 0, startOfWeek.Location())
-
-	endOfWeek := startOfWeek.AddDate(0, 0, 5).Add(24*time.Hour - time.Nanosecond) // Saturday night
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("sold_date BETWEEN ? AND ? AND supplier_id = ?", startOfWeek, endOfWeek, supplierID).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items for the supplier",
-		})
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	// Group by Day
-	dailySales := map[string]float64{
-		"Monday":    0,
-		"Tuesday":   0,
-		"Wednesday": 0,
-		"Thursday":  0,
-		"Friday":    0,
-		"Saturday":  0,
+	summary, err := h.Reporting.SupplierVATSummary(req.IntervalType, req.SupplierID, time.Now())
+	if errors.Is(err, services.ErrInvalidInterval) {
+		return apperr.BadRequest("Interval must be daily, weekly, monthly, or yearly")
 	}
-
-	for _, item := range soldItems {
-		day := item.SoldDate.Weekday().String()
-		if day != "Sunday" {
-			dayName := string(day)
-			dailySales[dayName] += float64(item.QuantitySold) * item.TotalAmount
-		}
+	if err != nil {
+		return apperr.Internal("Failed to fetch transactions", err)
 	}
-
-	return c.JSON(dailySales)
+	return c.JSON(summary)
 }
 
-// Get weekly sales for a specific supplier
-func getSupplierWeeklySummary(c *fiber.Ctx, supplierID uint) error {
-	now := time.Now()
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("sold_date >= ? AND supplier_id = ?", startOfMonth, supplierID).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items for the supplier",
-		})
+// GetDiscountRegister is the monthly senior citizen and PWD discount
+// register. Query: month (YYYY-MM, default this month) and type (senior or
+// pwd, default both).
+func (h *Handler) GetDiscountRegister(c *fiber.Ctx) error {
+	month, err := time.ParseInLocation("2006-01", c.Query("month", time.Now().Format("2006-01")), time.Local)
+	if err != nil {
+		return apperr.Validation(apperr.FieldError{Field: "month", Message: "must be a month in the format YYYY-MM"})
 	}
-
-	weeklySales := map[string]float64{
-		"Week 1": 0,
-		"Week 2": 0,
-		"Week 3": 0,
-		"Week 4": 0,
-		"Week 5": 0,
+	discountType := c.Query("type")
+	if discountType != "" && discountType != models.DiscountSenior && discountType != models.DiscountPWD {
+		return apperr.Validation(apperr.FieldError{Field: "type", Message: "must be one of: senior, pwd"})
 	}
 
-	for _, item := range soldItems {
-		day := item.SoldDate.Day()
-		var week string
-		switch {
-		case day >= 1 && day <= 7:
-			week = "Week 1"
-		case day >= 8 && day <= 14:
-			week = "Week 2"
-		case day >= 15 && day <= 21:
-			week = "Week 3"
-		case day >= 22 && day <= 28:
-			week = "Week 4"
-		default:
-			week = "Week 5"
-		}
-		weeklySales[week] += float64(item.QuantitySold) * item.TotalAmount
+	register, err := h.Reporting.DiscountRegister(month, discountType)
+	if err != nil {
+		return apperr.Internal("Failed to fetch the discount register", err)
 	}
-
-	return c.JSON(weeklySales)
+	return c.JSON(register)
 }
 
-// Get monthly sales for a specific supplier
-func getSupplierMonthlySummary(c *fiber.Ctx, supplierID uint) error {
-	now := time.Now()
-	currentYear := now.Year()
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("EXTRACT(YEAR FROM sold_date) = ? AND supplier_id = ?", currentYear, supplierID).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items for the supplier",
-		})
+// summaryResponse writes a sales summary or the error that prevented it.
+func summaryResponse(c *fiber.Ctx, summary map[string]float64, err error) error {
+	if errors.Is(err, services.ErrInvalidInterval) {
+		return apperr.BadRequest("Interval must be daily, weekly, monthly, or yearly")
 	}
-
-	monthlySales := map[string]float64{
-		"January":   0,
-		"February":  0,
-		"March":     0,
-		"April":     0,
-		"May":       0,
-		"June":      0,
-		"July":      0,
-		"August":    0,
-		"September": 0,
-		"October":   0,
-		"November":  0,
-		"December":  0,
-	}
-
-	for _, item := range soldItems {
-		month := item.SoldDate.Month().String()
-		monthlySales[month] += float64(item.QuantitySold) * item.TotalAmount
+	if err != nil {
+		return apperr.Internal("Failed to fetch sold items", err)
 	}
-
-	return c.JSON(monthlySales)
+	return c.JSON(summary)
 }
 
-// Get yearly sales for a specific supplier
-func getSupplierYearlySummary(c *fiber.Ctx, supplierID uint) error {
-	now := time.Now()
-	startYear := now.Year() - 4 // last 4 years + current
-
-	var soldItems []models.SoldItems
-	if err := database.DB.Preload("Product").Where("EXTRACT(YEAR FROM sold_date) >= ? AND supplier_id = ?", startYear, supplierID).Find(&soldItems).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sold items for the supplier",
-		})
-	}
-
-	yearlySales := make(map[int]float64)
-
-	for i := startYear; i <= now.Year(); i++ {
-		yearlySales[i] = 0
-	}
+// supplier get summary
 
-	for _, item := range soldItems {
-		year := item.SoldDate.Year()
-		if _, ok := yearlySales[year]; ok {
-			yearlySales[year] += float64(item.QuantitySold) * item.TotalAmount
-		}
+func (h *Handler) GetSupplierSalesSummary(c *fiber.Ctx) error {
+	var req SupplierSalesRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
 
-	return c.JSON(yearlySales)
+	summary, err := h.Reporting.SupplierSalesSummary(req.IntervalType, req.SupplierID, time.Now())
+	return summaryResponse(c, summary, err)
 }
 
 // fetch data using date
 
-type DateRange struct {
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
-}
-
-func GetSoldItemsByDateRangePost(c *fiber.Ctx) error {
+func (h *Handler) GetSoldItemsByDateRangePost(c *fiber.Ctx) error {
 	var dateRange DateRange
-	var soldItems []models.SoldItems
 
-	// Parse JSON body
-	if err := c.BodyParser(&dateRange); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := bind(c, &dateRange); err != nil {
+		return err
 	}
 
-	db := database.DB
+	opts, err := listing.Parse(c.Queries(), services.SoldItemListing)
+	if err != nil {
+		return err
+	}
 
-	// If both start and end date are provided
+	// Without both dates every sold item is returned
 	if dateRange.StartDate != "" && dateRange.EndDate != "" {
 		startDate, err1 := time.Parse("2006-01-02", dateRange.StartDate)
 		endDate, err2 := time.Parse("2006-01-02", dateRange.EndDate)
 		if err1 != nil || err2 != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid date format. Please use YYYY-MM-DD.",
-			})
-		}
-
-		endDate = endDate.Add(24 * time.Hour)
-
-		if err := db.Preload("Product").
-			Where("created_at BETWEEN ? AND ?", startDate, endDate).
-			Find(&soldItems).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to fetch sold items",
-			})
-		}
-	} else {
-		// No date provided, fetch all
-		if err := db.Preload("Product").Find(&soldItems).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to fetch sold items",
-			})
+			return apperr.BadRequest("Invalid date format. Please use YYYY-MM-DD.")
 		}
+		opts = opts.Between(startDate, endDate.Add(24*time.Hour))
 	}
 
-	// Calculate overall amount sold
-	var overallAmountSold float64
-	for _, item := range soldItems {
-		overallAmountSold += float64(item.QuantitySold) * item.Product.Price
-	}
-
-	return c.JSON(fiber.Map{
-		"sold_items":          soldItems,
-		"overall_amount_sold": overallAmountSold,
-	})
+	return h.soldItemsPage(c, opts)
 }
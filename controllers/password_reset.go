@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/logging"
+	"github.com/m/models"
+	"github.com/m/services"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+// RequestPasswordReset emails a reset link when the address belongs to a user
+// or supplier. The response is the same either way so it cannot be used to
+// find out which emails are registered.
+func (h *Handler) RequestPasswordReset(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	response := fiber.Map{"message": "If the email is registered, a password reset link has been sent"}
+
+	acct, err := h.findAccountByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logging.From(c.UserContext()).Error("looking up account for password reset", "error", err)
+		}
+		return c.JSON(response)
+	}
+
+	token, err := h.issueAccountToken(models.TokenPurposePasswordReset, acct.SubjectType, acct.ID, passwordResetTTL)
+	if err != nil {
+		logging.From(c.UserContext()).Error("issuing password reset token", "error", err)
+		return c.JSON(response)
+	}
+
+	// Send in the background so the response time does not reveal a match.
+	// Nobody waits for the result, so failures can only be logged.
+	logger := logging.From(c.UserContext())
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("sending password reset email", "panic", r)
+			}
+		}()
+		link := h.FrontendURL + "/reset-password?token=" + token
+		body := "Hello " + acct.Name + ",\n\nWe received a request to reset your password. Use the link below within 30 minutes:\n\n" +
+			link + "\n\nIf you did not ask for this, you can ignore this email."
+		if err := h.Mailer.Send(acct.Email, "Password Reset", body); err != nil {
+			logger.Error("sending password reset email", "subject_type", acct.SubjectType, "subject_id", acct.ID, "error", err)
+		}
+	}()
+
+	return c.JSON(response)
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs the
+// account out everywhere.
+func (h *Handler) ResetPassword(c *fiber.Ctx) error {
+	var req SetPasswordRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		accountToken, err := consumeAccountToken(tx, models.TokenPurposePasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
+		if err := setAccountPassword(tx, accountToken.SubjectType, accountToken.SubjectID, req.Password); err != nil {
+			return err
+		}
+		if err := services.RevokeSubjectSessions(tx, accountToken.SubjectType, accountToken.SubjectID); err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, audit.ActorOf(c), audit.ActionUpdate, accountToken.SubjectType, accountToken.SubjectID, nil, fiber.Map{"password": "reset"})
+	})
+	if errors.Is(err, errAccountTokenInvalid) {
+		return apperr.New(apperr.CodeInvalidToken, "Invalid or expired reset token")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to reset password", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Password has been reset"})
+}
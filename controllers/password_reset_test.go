@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"github.com/m/utils"
+)
+
+type sentMail struct{ to, subject, body string }
+
+// fakeMailer records sent mail on a channel, since some mail is sent in the
+// background.
+type fakeMailer struct {
+	sent chan sentMail
+	err  error
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	m.sent <- sentMail{to, subject, body}
+	return m.err
+}
+
+// mailerOf is the fake mailer newHandler gave h.
+func mailerOf(h *Handler) *fakeMailer {
+	return h.Mailer.(*fakeMailer)
+}
+
+func (m *fakeMailer) next(t *testing.T) sentMail {
+	t.Helper()
+
+	select {
+	case mail := <-m.sent:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email sent")
+		return sentMail{}
+	}
+}
+
+var resetLinkToken = regexp.MustCompile(`token=(\S+)`)
+
+func newPasswordResetApp(h *Handler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Post("/api/password/forgot", h.RequestPasswordReset)
+	app.Post("/api/password/reset", h.ResetPassword)
+	return app
+}
+
+func TestPasswordResetIsSingleUse(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	mail := mailerOf(h)
+	app := newPasswordResetApp(h)
+	user := seedCashier(t, db)
+	if err := db.Create(&models.Session{ID: "old", SubjectType: models.SubjectUser, SubjectID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if status, body := doJSON(t, app, fiber.MethodPost, "/api/password/forgot", fiber.Map{"email": user.Email}); status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	sent := mail.next(t)
+	match := resetLinkToken.FindStringSubmatch(sent.body)
+	if sent.to != user.Email || match == nil {
+		t.Fatalf("mail = %+v", sent)
+	}
+	token := match[1]
+
+	if status, body := doJSON(t, app, fiber.MethodPost, "/api/password/reset", fiber.Map{"token": token, "password": "new-password"}); status != fiber.StatusOK {
+		t.Fatalf("reset: status = %d: %s", status, body)
+	}
+	db.First(&user, user.ID)
+	if ok, _ := utils.VerifyPassword(user.Password, "new-password"); !ok {
+		t.Error("password not changed")
+	}
+	if n := activeSessions(t, db, user.ID); n != 0 {
+		t.Errorf("active sessions = %d after a reset, want 0", n)
+	}
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/password/reset", fiber.Map{"token": token, "password": "another-password"})
+	if status != fiber.StatusBadRequest {
+		t.Errorf("reused token: status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+	}
+	db.First(&user, user.ID)
+	if ok, _ := utils.VerifyPassword(user.Password, "new-password"); !ok {
+		t.Error("reused token changed the password")
+	}
+}
+
+func TestExpiredPasswordResetTokenIsRefused(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	app := newPasswordResetApp(h)
+	user := seedCashier(t, db)
+
+	token, err := h.issueAccountToken(models.TokenPurposePasswordReset, models.SubjectUser, user.ID, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := doJSON(t, app, fiber.MethodPost, "/api/password/reset", fiber.Map{"token": token, "password": "new-password"}); status != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+	}
+	// A token for another purpose does not reset passwords either
+	activation, err := h.issueAccountToken(models.TokenPurposeSupplierActivation, models.SubjectUser, user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := doJSON(t, app, fiber.MethodPost, "/api/password/reset", fiber.Map{"token": activation, "password": "new-password"}); status != fiber.StatusBadRequest {
+		t.Errorf("activation token: status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+func TestPasswordResetDoesNotRevealRegisteredEmails(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	mail := mailerOf(h)
+	mail.err = errors.New("smtp down")
+	app := newPasswordResetApp(h)
+	user := seedCashier(t, db)
+
+	knownStatus, known := doJSON(t, app, fiber.MethodPost, "/api/password/forgot", fiber.Map{"email": user.Email})
+	mail.next(t)
+	unknownStatus, unknown := doJSON(t, app, fiber.MethodPost, "/api/password/forgot", fiber.Map{"email": "nobody@example.com"})
+	if knownStatus != fiber.StatusOK || knownStatus != unknownStatus || string(known) != string(unknown) {
+		t.Errorf("known = %d %s, unknown = %d %s", knownStatus, known, unknownStatus, unknown)
+	}
+	if len(mail.sent) != 0 {
+		t.Errorf("mailed an unknown address: %+v", <-mail.sent)
+	}
+}
@@ -1,254 +1,190 @@
 package controllers
 
 import (
-	// "errors"
-	"fmt"
-	"log"
-
-	// "net/url"
-	"strconv"
-	// "strings"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/m/database"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
 	"github.com/m/models"
-	"gorm.io/gorm"
+	"github.com/m/services"
 )
 
-func AddProduct(c *fiber.Ctx) error {
-	var product models.Product
-	userToken := c.Locals("supplier").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	supplierID := uint(claims["id"].(float64))
-
-	// Parse the product data from the request body
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product data"})
+// productError maps catalog service errors for a product the caller named.
+func productError(err error, action string) error {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return apperr.NotFound("Product not found")
+	case errors.Is(err, services.ErrNotProductOwner):
+		return apperr.Forbidden("Not authorized to " + action + " this product")
+	default:
+		return apperr.Internal("Failed to "+action+" product", err)
 	}
+}
 
-	// Validate product data
-	if product.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Product name is required"})
-	}
-	if product.Price <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Product price must be greater than zero"})
-	}
-	if product.Quantity <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Product quantity must be greater than zero"})
+func (h *Handler) AddProduct(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only suppliers can add catalog products")
 	}
 
-	var lastProduct models.Product
-	err := database.DB.Raw("SELECT * FROM otop_products ORDER BY created_at DESC LIMIT 1").Scan(&lastProduct).Error
+	// Parse the product data from the request body
+	var req ProductRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	product, err := h.Catalog.Create(audit.ActorOf(c), supplierID, models.Product{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		Quantity:    req.Quantity,
+		Category:    req.Category,
+	})
 	if err != nil {
-		log.Println("Failed to fetch last product:", err)
-	}
-
-	// Generate new sequential number
-	seqNumber := 1
-	if lastProduct.ID != 0 {
-		// Extract the sequential number from the last product's sequential_number
-		// Assuming format is like 'SEQ-0001'
-		fmt.Sscanf(lastProduct.SequentialNumber, "SEQ-%04d", &seqNumber)
-		seqNumber++
-	}
-	product.SequentialNumber = fmt.Sprintf("SEQ-%04d", seqNumber)
-
-	// Set the supplier ID and save the product
-	product.SupplierID = supplierID
-	if err := database.DB.Create(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error saving product"})
+		return apperr.Internal("Error saving product", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(product)
 }
 
-func GetProducts(c *fiber.Ctx) error {
-	var products []models.Product
-	if err := database.DB.Find(&products).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+func (h *Handler) GetProducts(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.ProductListing)
+	if err != nil {
+		return err
 	}
-	return c.JSON(products)
-}
-
-func GetMyProducts(c *fiber.Ctx) error {
-	userToken := c.Locals("supplier").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	supplierID := uint(claims["id"].(float64))
 
-	var products []models.Product
-	if err := database.DB.Where("supplier_id = ?", supplierID).Find(&products).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+	products, err := h.Catalog.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
 	}
-
 	return c.JSON(products)
 }
 
-func UpdateProduct(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var product models.Product
-
-	if err := database.DB.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+func (h *Handler) GetMyProducts(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only suppliers have their own catalog")
 	}
 
-	userToken := c.Locals("supplier").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	supplierID := uint(claims["id"].(float64))
-
-	if product.SupplierID != supplierID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to update this product"})
+	opts, err := listing.Parse(c.Queries(), services.ProductListing)
+	if err != nil {
+		return err
 	}
 
-	var updatedProduct models.Product
-	if err := c.BodyParser(&updatedProduct); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
-	}
-	if updatedProduct.Price <= 0 || updatedProduct.Quantity < 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Price must be greater than 0 and Quantity cannot be negative"})
+	products, err := h.Catalog.ListBySupplier(supplierID, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
 	}
 
-	product.Name = updatedProduct.Name
-	product.Price = updatedProduct.Price
-	product.Quantity = updatedProduct.Quantity
+	return c.JSON(products)
+}
 
-	if err := database.DB.Save(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
+func (h *Handler) UpdateProduct(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Not authorized to update this product")
 	}
 
-	return c.JSON(product)
-}
-
-func DeleteProduct(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var product models.Product
+	var req UpdateProductRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
 
-	if err := database.DB.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	change, err := h.Catalog.Update(audit.ActorOf(c), paramID(c.Params("id")), supplierID, services.ProductUpdate{
+		Name:     req.Name,
+		Price:    req.Price,
+		Quantity: req.Quantity,
+	})
+	if err != nil {
+		return productError(err, "update")
 	}
 
-	userToken := c.Locals("supplier").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	supplierID := uint(claims["id"].(float64))
+	return c.JSON(change.After)
+}
 
-	if product.SupplierID != supplierID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to delete this product"})
+func (h *Handler) DeleteProduct(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Not authorized to delete this product")
 	}
 
-	if err := database.DB.Delete(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete product"})
+	if _, err := h.Catalog.Delete(audit.ActorOf(c), paramID(c.Params("id")), supplierID); err != nil {
+		return productError(err, "delete")
 	}
 
 	return c.JSON(fiber.Map{"message": "Product deleted successfully"})
 }
 
-func GetProductByName(c *fiber.Ctx) error {
-	supplierIDParam := c.Params("supplier_id")
-	supplierID, err := strconv.ParseUint(supplierIDParam, 10, 32)
-	if err != nil {
-		log.Printf("Invalid supplier ID: %s", supplierIDParam)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier ID"})
+// GetProductByName lists the caller's own catalog; the supplier ID in the
+// path must be theirs.
+func (h *Handler) GetProductByName(c *fiber.Ctx) error {
+	supplierID := paramID(c.Params("supplier_id"))
+	if supplierID == 0 {
+		return apperr.BadRequest("Invalid supplier ID")
 	}
 
-	log.Printf("Fetching products for Supplier ID: %d", supplierID)
-
-	// Verify token and claims
-	token := c.Locals("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-
-	tokenSupplierID, ok := claims["supplier_id"].(float64)
-	if !ok || uint(supplierID) != uint(tokenSupplierID) {
-		log.Printf("Token supplier ID mismatch: %v, param supplier ID: %d", tokenSupplierID, supplierID)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized access to supplier data"})
+	tokenSupplierID, ok := callerSupplierID(c)
+	if !ok || supplierID != tokenSupplierID {
+		return apperr.Unauthorized("Unauthorized access to supplier data")
 	}
 
-	// Fetch products for the supplier
-	var products []models.Product
-	if err := database.DB.Where("supplier_id = ?", uint(supplierID)).Find(&products).Error; err != nil {
-		log.Printf("Error fetching products for supplier %d: %v", supplierID, err)
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No products found for the supplier"})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+	opts, err := listing.Parse(c.Queries(), services.ProductListing)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Products fetched for Supplier ID %d: %+v", supplierID, products)
+	products, err := h.Catalog.ListBySupplier(supplierID, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
+	}
 
 	return c.JSON(products)
 }
 
-// func GetProductByName(c *fiber.Ctx) error {
-// 	Name := c.Params("id")
-
-// 	log.Println("Looking for product with name:", Name)
-
-// 	decodedName, err := url.QueryUnescape(Name)
-// 	if err != nil {
-// 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product name"})
-// 	}
-// 	Name = strings.TrimSpace(decodedName)
-
-// 	var product models.Product
-
-// 	if err := database.DB.Where("LOWER(name) = LOWER(?)", Name).First(&product).Error; err != nil {
-// 		log.Println("Error fetching product:", err)
-// 		if err == gorm.ErrRecordNotFound {
-// 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
-// 		}
-// 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product"})
-// 	}
-
-// 	return c.JSON(product)
-// }
-
-func GetTotalQuantity(c *fiber.Ctx) error {
-	log.Println("Received request to calculate total quantity of products")
-
-	var totalQuantity int64
-	err := database.DB.Model(&models.Product{}).Select("SUM(quantity)").Scan(&totalQuantity).Error
-
+func (h *Handler) GetTotalQuantity(c *fiber.Ctx) error {
+	totalQuantity, err := h.Catalog.TotalQuantity()
 	if err != nil {
-		log.Println("Error calculating total quantity:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate total quantity"})
+		return apperr.Internal("Failed to calculate total quantity", err)
 	}
 
-	log.Println("Total quantity calculated:", totalQuantity)
 	return c.JSON(fiber.Map{"total_quantity": totalQuantity})
 }
 
-func GetProductsByStore(c *fiber.Ctx) error {
-	supplierID := c.Params("supplier_id")
+func (h *Handler) GetProductsByStore(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.ProductListing)
+	if err != nil {
+		return err
+	}
 
-	var products []models.Product
-	if err := database.DB.Where("supplier_id = ?", supplierID).Find(&products).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+	products, err := h.Catalog.ListBySupplier(paramID(c.Params("supplier_id")), opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
 	}
 	return c.JSON(products)
 }
 
-func GetSupplierProducts(c *fiber.Ctx) error {
-	// Extract the token information from the context
-	userToken := c.Locals("supplier").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
+func (h *Handler) GetSupplierProducts(c *fiber.Ctx) error {
+	supplierID, ok := callerSupplierID(c)
+	if !ok {
+		return apperr.Forbidden("Only suppliers have their own catalog")
+	}
 
-	// Extract supplier ID from the token claims
-	supplierID := uint(claims["id"].(float64))
-	log.Printf("Fetching products for Supplier ID (from token): %d", supplierID)
+	opts, err := listing.Parse(c.Queries(), services.ProductListing)
+	if err != nil {
+		return err
+	}
 
-	// Query the database for products belonging to this supplier
-	var products []models.Product
-	if err := database.DB.Where("supplier_id = ?", supplierID).Find(&products).Error; err != nil {
-		log.Printf("Query error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
+	products, err := h.Catalog.ListBySupplier(supplierID, opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch products", err)
 	}
 
 	// If no products are found, return a 404 response
-	if len(products) == 0 {
-		log.Printf("No products found for Supplier ID: %d", supplierID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No products found for this supplier"})
+	if products.Meta.Total == 0 {
+		return apperr.NotFound("No products found for this supplier")
 	}
 
-	// Return the list of products as JSON
-	log.Printf("Found %d products for Supplier ID: %d", len(products), supplierID)
 	return c.JSON(products)
 }
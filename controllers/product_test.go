@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/testutil"
+)
+
+func TestSuppliersOnlyChangeTheirOwnProducts(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	owner := models.Supplier{StoreName: "Habi", Email: "habi@example.com", Status: models.SupplierActive}
+	other := models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com", Status: models.SupplierActive}
+	for _, s := range []*models.Supplier{&owner, &other} {
+		if err := db.Create(s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	product := models.Product{Name: "Abaca Bag", Price: 350, Quantity: 4, Category: "Non-Food", SupplierID: owner.ID}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/products/%d", product.ID)
+	update := fiber.Map{"name": "Abaca Tote", "price": 400, "quantity": 2}
+
+	serve := func(id uint, role string) *fiber.App {
+		app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+		app.Use(testutil.AsUser(id, role))
+		app.Put("/products/:id", h.UpdateProduct)
+		app.Delete("/products/:id", h.DeleteProduct)
+		return app
+	}
+
+	for _, caller := range []struct {
+		name string
+		app  *fiber.App
+	}{
+		{"another supplier", serve(other.ID, "supplier")},
+		{"an admin", serve(1, "admin")},
+	} {
+		if status, body := doJSON(t, caller.app, fiber.MethodPut, path, update); status != fiber.StatusForbidden {
+			t.Errorf("%s updating: status = %d, want %d: %s", caller.name, status, fiber.StatusForbidden, body)
+		}
+		if status, body := doJSON(t, caller.app, fiber.MethodDelete, path, nil); status != fiber.StatusForbidden {
+			t.Errorf("%s deleting: status = %d, want %d: %s", caller.name, status, fiber.StatusForbidden, body)
+		}
+	}
+
+	app := serve(owner.ID, "supplier")
+	if status, body := doJSON(t, app, fiber.MethodPut, path, update); status != fiber.StatusOK {
+		t.Fatalf("owner updating: status = %d: %s", status, body)
+	}
+	if err := db.First(&product, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "Abaca Tote" || product.Price != 400 || product.Quantity != 2 || product.Category != "Non-Food" {
+		t.Errorf("product = %+v", product)
+	}
+	if status, body := doJSON(t, app, fiber.MethodDelete, path, nil); status != fiber.StatusOK {
+		t.Errorf("owner deleting: status = %d: %s", status, body)
+	}
+}
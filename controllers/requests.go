@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/validation"
+)
+
+// Request bodies are parsed into the DTOs below rather than into the GORM
+// models, so a client can only set the fields a DTO declares. IDs, sequential
+// numbers, statuses, owners and totals are always filled in by the server.
+
+// bind parses the request body into req and validates it.
+func bind(c *fiber.Ctx, req interface{}) error {
+	if err := c.BodyParser(req); err != nil {
+		return apperr.BadRequest("Invalid request body")
+	}
+	return validation.Validate(req)
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// SetPasswordRequest redeems an emailed password reset or supplier
+// activation token.
+type SetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"min=8,maxbytes=72"`
+}
+
+type CreateUserRequest struct {
+	UserName string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"min=8,maxbytes=72"`
+	Role     string `json:"role" validate:"required,oneof=admin cashier"`
+}
+
+// UpdateUserRequest changes only the fields that are present.
+type UpdateUserRequest struct {
+	UserName *string `json:"username"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+	Role     *string `json:"role" validate:"omitempty,oneof=admin cashier"`
+}
+
+type RevokeSessionsRequest struct {
+	SubjectType string `json:"subject_type" validate:"required,oneof=user supplier"`
+	SubjectID   uint   `json:"subject_id" validate:"required"`
+}
+
+type UnlockLoginRequest struct {
+	Email string `json:"email" validate:"required_without=IP"`
+	IP    string `json:"ip" validate:"omitempty,ip"`
+}
+
+type OpenShiftRequest struct {
+	OpeningCash float64 `json:"opening_cash" validate:"gte=0"`
+}
+
+type CloseShiftRequest struct {
+	CountedCash *float64 `json:"counted_cash" validate:"required,gte=0"`
+	Notes       string   `json:"notes"`
+}
+
+type CreateOtopProductRequest struct {
+	Name        string  `json:"name" validate:"required"`
+	Description string  `json:"description" validate:"required"`
+	Price       float64 `json:"price" validate:"gt=0"`
+	Quantity    int64   `json:"quantity" validate:"gte=0"`
+	Category    string  `json:"category" validate:"required,oneof=Food Non-Food"`
+	StoreName   string  `json:"store_name" validate:"required"`
+	VATClass    string  `json:"vat_class" validate:"omitempty,oneof=category vatable exempt zero_rated"`
+}
+
+func (r CreateOtopProductRequest) model() models.OtopProducts {
+	return models.OtopProducts{
+		Name:        r.Name,
+		Description: r.Description,
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Category:    r.Category,
+		StoreName:   r.StoreName,
+		VATClass:    r.VATClass,
+	}
+}
+
+// UpdateOtopProductRequest changes only the fields that are present.
+type UpdateOtopProductRequest struct {
+	Name        *string  `json:"name" validate:"omitempty,min=1"`
+	Description *string  `json:"description" validate:"omitempty,min=1"`
+	Price       *float64 `json:"price" validate:"omitempty,gt=0"`
+	Quantity    *int64   `json:"quantity" validate:"omitempty,gte=0"`
+	Category    *string  `json:"category" validate:"omitempty,oneof=Food Non-Food"`
+	VATClass    *string  `json:"vat_class" validate:"omitempty,oneof=category vatable exempt zero_rated"`
+}
+
+func (r UpdateOtopProductRequest) apply(p *models.OtopProducts) {
+	if r.Name != nil {
+		p.Name = *r.Name
+	}
+	if r.Description != nil {
+		p.Description = *r.Description
+	}
+	if r.Price != nil {
+		p.Price = *r.Price
+	}
+	if r.Quantity != nil {
+		p.Quantity = *r.Quantity
+	}
+	if r.Category != nil {
+		p.Category = *r.Category
+	}
+	if r.VATClass != nil {
+		p.VATClass = *r.VATClass
+	}
+}
+
+// UpdateStoreProductsRequest selects a supplier's store products by
+// supplier_id and store_name and sets the non-zero fields on all of them.
+type UpdateStoreProductsRequest struct {
+	SupplierID  uint    `json:"supplier_id" validate:"required"`
+	StoreName   string  `json:"store_name" validate:"required"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" validate:"gte=0"`
+	Quantity    int64   `json:"quantity" validate:"gte=0"`
+	Category    string  `json:"category" validate:"omitempty,oneof=Food Non-Food"`
+}
+
+func (r UpdateStoreProductsRequest) model() models.OtopProducts {
+	return models.OtopProducts{
+		SupplierID:  r.SupplierID,
+		StoreName:   r.StoreName,
+		Name:        r.Name,
+		Description: r.Description,
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Category:    r.Category,
+	}
+}
+
+type SoldItemRequest struct {
+	ProductID uint  `json:"id" validate:"required"`
+	Quantity  int64 `json:"quantity" validate:"gt=0"`
+}
+
+// CartItemRequest is one line of the till's cart. The server prices it
+// from the product; Price and Total are optional and, when sent, must match.
+type CartItemRequest struct {
+	ProductID uint     `json:"product_id" validate:"required"`
+	Name      string   `json:"name"`
+	Quantity  int64    `json:"quantity" validate:"gt=0"`
+	Price     *float64 `json:"price" validate:"omitempty,gte=0"`
+	Total     *float64 `json:"total" validate:"omitempty,gte=0"`
+}
+
+// PaymentRequest is one tender. Wallet and card payments carry the
+// approval reference from the terminal or app.
+type PaymentRequest struct {
+	Method    string  `json:"method" validate:"required,oneof=cash gcash maya card"`
+	Amount    float64 `json:"amount" validate:"gt=0"`
+	Reference string  `json:"reference" validate:"required_unless=Method cash,max=64"`
+}
+
+// DiscountRequest claims the senior citizen or PWD discount for a sale. The
+// cashier checks the ID and keys in its number and the holder's name.
+type DiscountRequest struct {
+	Type     string `json:"type" validate:"required,oneof=senior pwd"`
+	IDNumber string `json:"id_number" validate:"required,max=32"`
+	Name     string `json:"name" validate:"required,max=100"`
+}
+
+// CheckoutRequest is a sale from the till. Total and Change are optional
+// and, when sent, must match what the server computes. Payments lists the
+// tenders; older tills send only Received, which counts as cash.
+type CheckoutRequest struct {
+	Items    []CartItemRequest `json:"items" validate:"required,min=1,dive"`
+	Payments []PaymentRequest  `json:"payments" validate:"omitempty,max=10,dive"`
+	Discount *DiscountRequest  `json:"discount"`
+	Received float64           `json:"received" validate:"gte=0"`
+	Total    *float64          `json:"total" validate:"omitempty,gte=0"`
+	Change   *float64          `json:"change" validate:"omitempty,gte=0"`
+}
+
+type AddToCartRequest struct {
+	ProductID  uint  `json:"productID" validate:"required"`
+	SupplierID uint  `json:"supplierID" validate:"required"`
+	Quantity   int64 `json:"quantity" validate:"gt=0"`
+}
+
+type SummaryRequest struct {
+	IntervalType string `json:"interval" validate:"required,oneof=daily weekly monthly yearly"`
+}
+
+type SupplierSalesRequest struct {
+	IntervalType string `json:"interval" validate:"required,oneof=daily weekly monthly yearly"`
+	SupplierID   uint   `json:"supplier_id" validate:"required"`
+}
+
+// DateRange filters by sold date; leaving both dates out returns everything.
+type DateRange struct {
+	StartDate string `json:"start_date" validate:"required_with=EndDate,omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"required_with=StartDate,omitempty,datetime=2006-01-02"`
+}
+
+// CreateOrderRequest orders stock of a product from its supplier. The
+// supplier, name, price and status come from the product and the server.
+type CreateOrderRequest struct {
+	ProductID   uint   `json:"product_id" validate:"required"`
+	Quantity    int64  `json:"quantity" validate:"gt=0"`
+	Description string `json:"description"`
+}
+
+type UpdateOrderRequest struct {
+	Quantity    *int64  `json:"quantity" validate:"omitempty,gt=0"`
+	Description *string `json:"description"`
+}
+
+func (r UpdateOrderRequest) apply(o *models.Order) {
+	if r.Quantity != nil {
+		o.Quantity = *r.Quantity
+	}
+	if r.Description != nil {
+		o.Descriptiom = *r.Description
+	}
+}
+
+type ProductRequest struct {
+	Name        string  `json:"name" validate:"required"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" validate:"gt=0"`
+	Quantity    int64   `json:"quantity" validate:"gt=0"`
+	Category    string  `json:"category" validate:"required,oneof=Food Non-Food"`
+}
+
+type UpdateProductRequest struct {
+	Name     string  `json:"name" validate:"required"`
+	Price    float64 `json:"price" validate:"gt=0"`
+	Quantity int64   `json:"quantity" validate:"gte=0"`
+}
+
+type CreateSupplierRequest struct {
+	StoreName   string `json:"store_name" validate:"required"`
+	Email       string `json:"email" validate:"required,email"`
+	PhoneNumber string `json:"phone_number"`
+	Address     string `json:"address"`
+}
+
+// UpdateSupplierRequest changes only the fields that are present. Status and
+// password go through the invitation and password flows instead.
+type UpdateSupplierRequest struct {
+	StoreName   *string `json:"store_name" validate:"omitempty,min=1"`
+	Email       *string `json:"email" validate:"omitempty,email"`
+	PhoneNumber *string `json:"phone_number"`
+	Address     *string `json:"address"`
+}
+
+func (r UpdateSupplierRequest) apply(s *models.Supplier) {
+	if r.StoreName != nil {
+		s.StoreName = *r.StoreName
+	}
+	if r.Email != nil {
+		s.Email = *r.Email
+	}
+	if r.PhoneNumber != nil {
+		s.PhoneNumber = *r.PhoneNumber
+	}
+	if r.Address != nil {
+		s.Address = *r.Address
+	}
+}
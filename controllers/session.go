@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+// sessionResponse is what a client gets when a session starts or refreshes.
+func sessionResponse(tokens services.SessionTokens) fiber.Map {
+	return fiber.Map{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+	}
+}
+
+// tokenSubject reads the session and subject identity from the verified token
+// stored by middleware.Authorize.
+func tokenSubject(c *fiber.Ctx) (sessionID string, subjectType string, subjectID uint, ok bool) {
+	token, isToken := c.Locals("user").(*jwt.Token)
+	if !isToken {
+		return "", "", 0, false
+	}
+	claims, isMap := token.Claims.(jwt.MapClaims)
+	if !isMap {
+		return "", "", 0, false
+	}
+
+	sessionID, _ = claims["sid"].(string)
+	id, _ := claims["id"].(float64)
+	subjectType = models.SubjectUser
+	if role, _ := claims["role"].(string); role == "supplier" {
+		subjectType = models.SubjectSupplier
+	}
+
+	return sessionID, subjectType, uint(id), sessionID != ""
+}
+
+// callerSupplierID returns the signed-in supplier's ID stored by
+// middleware.Authorize; ok is false when the caller is not a supplier.
+func callerSupplierID(c *fiber.Ctx) (id uint, ok bool) {
+	id, ok = c.Locals("supplier_id").(uint)
+	return id, ok
+}
+
+func (h *Handler) RefreshToken(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	tokens, err := h.Sessions.Refresh(req.RefreshToken)
+	switch {
+	case errors.Is(err, services.ErrSessionInvalid):
+		return apperr.New(apperr.CodeSessionExpired, "Invalid or expired refresh token")
+	case errors.Is(err, services.ErrAccountInactive):
+		return apperr.New(apperr.CodeAccountInactive, "Account is not active")
+	case err != nil:
+		return apperr.Internal("Error refreshing token", err)
+	}
+
+	return c.JSON(sessionResponse(tokens))
+}
+
+// LogoutAll signs the caller out on every device.
+func (h *Handler) LogoutAll(c *fiber.Ctx) error {
+	_, subjectType, subjectID, ok := tokenSubject(c)
+	if !ok {
+		return apperr.Unauthorized("Unauthorized")
+	}
+
+	if err := h.Sessions.RevokeAll(subjectType, subjectID); err != nil {
+		return apperr.Internal("Failed to revoke sessions", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out from all devices"})
+}
+
+// RevokeSubjectSessions lets an admin sign a user or supplier out everywhere.
+func (h *Handler) RevokeSubjectSessions(c *fiber.Ctx) error {
+	var req RevokeSessionsRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.RevokeSubjectSessions(tx, req.SubjectType, req.SubjectID); err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, audit.ActorOf(c), audit.ActionUpdate, req.SubjectType, req.SubjectID, nil, fiber.Map{"sessions": "revoked"})
+	})
+	if err != nil {
+		return apperr.Internal("Failed to revoke sessions", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Sessions revoked"})
+}
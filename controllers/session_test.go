@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/testutil"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+const testSigningSecret = "test-secret-0123456789-abcdefghijklmn"
+
+func newSessionApp(t *testing.T, db *gorm.DB) *fiber.App {
+	t.Helper()
+
+	key := utils.SigningKey{ID: "test", Method: jwt.SigningMethodHS256, Sign: []byte(testSigningSecret), Verify: []byte(testSigningSecret)}
+	if err := utils.ConfigureSigningKeys("test", []utils.SigningKey{key}); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	h := newHandler(db)
+	app.Post("/api/refresh", h.RefreshToken)
+	return app
+}
+
+// signIn starts a session for the user as a successful login would and
+// returns its refresh token.
+func signIn(t *testing.T, db *gorm.DB, user models.User) string {
+	t.Helper()
+
+	tokens, err := services.NewSessions(db).Start(models.SubjectUser, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens.RefreshToken
+}
+
+// refresh redeems a refresh token and returns the status, error code and new
+// refresh token.
+func refresh(t *testing.T, app *fiber.App, token string) (int, apperr.Code, string) {
+	t.Helper()
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/refresh", fiber.Map{"refresh_token": token})
+	var resp struct {
+		Code         apperr.Code `json:"code"`
+		Token        string      `json:"token"`
+		RefreshToken string      `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	if status == fiber.StatusOK && resp.Token == "" {
+		t.Errorf("no access token: %s", body)
+	}
+	return status, resp.Code, resp.RefreshToken
+}
+
+func activeSessions(t *testing.T, db *gorm.DB, userID uint) int64 {
+	t.Helper()
+
+	var n int64
+	if err := db.Model(&models.Session{}).Where("subject_id = ? AND revoked_at IS NULL", userID).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func seedCashier(t *testing.T, db *gorm.DB) models.User {
+	t.Helper()
+
+	user := models.User{UserName: "till", Email: "till@example.com", Role: "cashier"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newSessionApp(t, db)
+	first := signIn(t, db, seedCashier(t, db))
+
+	status, _, second := refresh(t, app, first)
+	if status != fiber.StatusOK || second == "" || second == first {
+		t.Fatalf("status = %d, refresh token %q", status, second)
+	}
+	if status, _, third := refresh(t, app, second); status != fiber.StatusOK || third == second {
+		t.Errorf("second refresh: status = %d, refresh token %q", status, third)
+	}
+	if status, code, _ := refresh(t, app, "never-issued"); status != fiber.StatusUnauthorized || code != apperr.CodeSessionExpired {
+		t.Errorf("unknown token: status = %d, code %q", status, code)
+	}
+}
+
+func TestReusedRefreshTokenRevokesEverySession(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newSessionApp(t, db)
+	user := seedCashier(t, db)
+	stolen := signIn(t, db, user)
+	other := signIn(t, db, user)
+
+	if status, _, _ := refresh(t, app, stolen); status != fiber.StatusOK {
+		t.Fatalf("first use: status = %d", status)
+	}
+	// The rotated-out token comes back: it leaked
+	if status, code, _ := refresh(t, app, stolen); status != fiber.StatusUnauthorized || code != apperr.CodeSessionExpired {
+		t.Errorf("reuse: status = %d, code %q", status, code)
+	}
+	if n := activeSessions(t, db, user.ID); n != 0 {
+		t.Errorf("active sessions = %d after reuse, want 0", n)
+	}
+	if status, _, _ := refresh(t, app, other); status != fiber.StatusUnauthorized {
+		t.Errorf("other device: status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestDisabledUserCannotRefresh(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newSessionApp(t, db)
+	user := seedCashier(t, db)
+	token := signIn(t, db, user)
+
+	if err := db.Model(&user).Update("disabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, code, _ := refresh(t, app, token); status != fiber.StatusForbidden || code != apperr.CodeAccountInactive {
+		t.Errorf("status = %d, code %q; want %d %q", status, code, fiber.StatusForbidden, apperr.CodeAccountInactive)
+	}
+	if n := activeSessions(t, db, user.ID); n != 0 {
+		t.Errorf("active sessions = %d, want the session revoked", n)
+	}
+}
+
+func TestInactiveSupplierCannotRefresh(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newSessionApp(t, db)
+	supplier := models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com", Status: models.SupplierActive}
+	if err := db.Create(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := services.NewSessions(db).Start(models.SubjectSupplier, supplier.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Model(&supplier).Update("status", models.SupplierPending).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, code, _ := refresh(t, app, tokens.RefreshToken); status != fiber.StatusForbidden || code != apperr.CodeAccountInactive {
+		t.Errorf("status = %d, code %q", status, code)
+	}
+}
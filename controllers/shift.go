@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/services"
+)
+
+func (h *Handler) OpenShift(c *fiber.Ctx) error {
+	var req OpenShiftRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	_, _, cashierID, ok := tokenSubject(c)
+	if !ok {
+		return apperr.Unauthorized("Unauthorized")
+	}
+
+	shift, err := h.Shifts.Open(audit.ActorOf(c), cashierID, req.OpeningCash)
+	if errors.Is(err, services.ErrShiftAlreadyOpen) {
+		return apperr.Conflict("You already have an open shift")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to open shift", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(shift)
+}
+
+// GetCurrentShift returns the caller's open shift with its running totals.
+func (h *Handler) GetCurrentShift(c *fiber.Ctx) error {
+	_, _, cashierID, ok := tokenSubject(c)
+	if !ok {
+		return apperr.Unauthorized("Unauthorized")
+	}
+
+	shift, err := h.Shifts.Current(cashierID)
+	if errors.Is(err, services.ErrNoOpenShift) {
+		return apperr.NotFound("No open shift")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to fetch shift", err)
+	}
+
+	return c.JSON(shift)
+}
+
+// CloseShift records the counted cash and computes the expected cash and
+// variance for the caller's open shift.
+func (h *Handler) CloseShift(c *fiber.Ctx) error {
+	var req CloseShiftRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	_, _, cashierID, ok := tokenSubject(c)
+	if !ok {
+		return apperr.Unauthorized("Unauthorized")
+	}
+
+	change, err := h.Shifts.Close(audit.ActorOf(c), cashierID, *req.CountedCash, req.Notes)
+	if errors.Is(err, services.ErrNoOpenShift) {
+		return apperr.NotFound("No open shift")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to close shift", err)
+	}
+
+	return c.JSON(change.After)
+}
+
+// GetShifts is the admin shift history. Filters: cashier_id, status, and
+// from/to (YYYY-MM-DD, inclusive) on the opening time. The totals, cash and
+// per tender, cover the closed shifts of every page, not only this one.
+func (h *Handler) GetShifts(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.ShiftListing)
+	if err != nil {
+		return err
+	}
+
+	shifts, err := h.Shifts.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch shifts", err)
+	}
+	return c.JSON(shifts)
+}
@@ -2,52 +2,52 @@ package controllers
 
 import (
 	// "fmt"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/m/database"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/logging"
 	"github.com/m/models"
-
+	"github.com/m/services"
 	// "gopkg.in/gomail.v2"
-
-	"gopkg.in/gomail.v2"
-	"gorm.io/gorm"
 )
 
-func CreateSupplier(c *fiber.Ctx) error {
-	var supplier models.Supplier
-
+// CreateSupplier adds a supplier in the pending state and emails them an
+// activation link to choose their own password.
+func (h *Handler) CreateSupplier(c *fiber.Ctx) error {
 	// Parse the supplier data from the request body
-	if err := c.BodyParser(&supplier); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	var input CreateSupplierRequest
+	if err := bind(c, &input); err != nil {
+		return err
 	}
 
-	// Save supplier to the database
-	if err := database.DB.Create(&supplier).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create supplier"})
+	supplier := models.Supplier{
+		StoreName:   input.StoreName,
+		Email:       input.Email,
+		PhoneNumber: input.PhoneNumber,
+		Address:     input.Address,
+		Status:      models.SupplierPending,
 	}
 
-	// Send email notification
-	m := gomail.NewMessage()
-	m.SetHeader("From", "giemacazar@gmail.com") // Your email
-	m.SetHeader("To", supplier.Email)           // Supplier's email from the request
-	m.SetHeader("Subject", "Supplier Account Created")
-	m.SetBody("text/plain", "Hello "+supplier.StoreName+",\n\nYour supplier account has been successfully created. Welcome to the OTOP.PH platform!")
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, "giemacazar@gmail.com", "wtga mbuz ooxc ymzs") // Replace with env vars in production
+	// Save supplier to the database
+	if err := h.Suppliers.Create(audit.ActorOf(c), &supplier); err != nil {
+		return apperr.Internal("Failed to create supplier", err)
+	}
 
-	// Attempt to send the email
-	if err := d.DialAndSend(m); err != nil {
-		// You can log the error but still return a success response if supplier creation succeeded
-		// Optionally, return a warning to the frontend
+	if err := h.sendSupplierInvitation(audit.ActorOf(c), supplier); err != nil {
+		// The supplier exists; the admin can resend the invitation later
+		logging.From(c.UserContext()).Error("sending supplier invitation", "supplier_id", supplier.ID, "error", err)
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"message": "Supplier created, but email notification failed",
-			"error":   err.Error(),
+			"message":  "Supplier created, but the invitation email failed",
+			"supplier": supplier,
 		})
 	}
 
 	// Return success response
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":  "Supplier created and email notification sent",
+		"message":  "Supplier created and invitation sent",
 		"supplier": supplier,
 	})
 }
@@ -70,190 +70,128 @@ func CreateSupplier(c *fiber.Ctx) error {
 
 // Register handles user registration and sends a notification email
 
-func GetSuppliers(c *fiber.Ctx) error {
-	var suppliers []models.Supplier
-	database.DB.Find(&suppliers)
+func (h *Handler) GetSuppliers(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.SupplierListing)
+	if err != nil {
+		return err
+	}
+
+	suppliers, err := h.Suppliers.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch suppliers", err)
+	}
 	return c.JSON(suppliers)
 }
 
-func GetSupplierByStoreName(c *fiber.Ctx) error {
-	storeName := c.Params("storeName")
-	var supplier models.Supplier
-	database.DB.Where("store_name = ?", storeName).First(&supplier)
-	if supplier.ID == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+func (h *Handler) GetSupplierByStoreName(c *fiber.Ctx) error {
+	supplier, err := h.Suppliers.GetByStoreName(c.Params("storeName"))
+	if err != nil {
+		return apperr.NotFound("Supplier not found")
 	}
 	return c.JSON(supplier)
 }
 
-func UpdateSupplier(c *fiber.Ctx) error {
-	// <<<<<<< HEAD/
-	storeName := c.Params("storeName")
-	var supplier models.Supplier
-
-	if err := database.DB.First(&supplier, storeName).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+func (h *Handler) UpdateSupplier(c *fiber.Ctx) error {
+	// The route parameter is named storeName but holds the supplier ID
+	supplier, err := h.Suppliers.Get(paramID(c.Params("storeName")))
+	if err != nil {
+		return apperr.NotFound("Supplier not found")
 	}
 
-	if err := c.BodyParser(&supplier); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	var req UpdateSupplierRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
+	req.apply(&supplier)
 
-	database.DB.Save(&supplier)
+	if err := h.Suppliers.Save(audit.ActorOf(c), &supplier); err != nil {
+		return apperr.Internal("Failed to update supplier", err)
+	}
 	return c.JSON(supplier)
 }
 
-func DeleteSupplier(c *fiber.Ctx) error {
-	// <<<<<<< HEAD
-	storeName := c.Params("storeName")
-	var supplier models.Supplier
-	if err := database.DB.First(&supplier, storeName).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+func (h *Handler) DeleteSupplier(c *fiber.Ctx) error {
+	_, err := h.Suppliers.Delete(audit.ActorOf(c), paramID(c.Params("storeName")))
+	if errors.Is(err, services.ErrNotFound) {
+		return apperr.NotFound("Supplier not found")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to delete supplier", err)
 	}
-
-	database.DB.Delete(&supplier)
 	return c.JSON(fiber.Map{"message": "Supplier deleted successfully"})
 }
 
-func CountSuppliersByStoreName(c *fiber.Ctx) error {
-	type StoreNameCount struct {
-		StoreName string `json:"store_name"`
-		Count     int64  `json:"count"`
-	}
-
-	var results []StoreNameCount
-	err := database.DB.Model(&models.Supplier{}).
-		Select("store_name, COUNT(*) as count").
-		Group("store_name").
-		Scan(&results).Error
-
+func (h *Handler) CountSuppliersByStoreName(c *fiber.Ctx) error {
+	results, err := h.Reporting.SupplierCountsByStoreName()
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count suppliers"})
+		return apperr.Internal("Failed to count suppliers", err)
 	}
 
 	return c.JSON(results)
 }
 
-func GetTotalSuppliers(c *fiber.Ctx) error {
-	var count int64
-	if err := database.DB.Model(&models.Supplier{}).Count(&count).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count suppliers"})
+func (h *Handler) GetTotalSuppliers(c *fiber.Ctx) error {
+	count, err := h.Suppliers.Count()
+	if err != nil {
+		return apperr.Internal("Failed to count suppliers", err)
 	}
 
 	return c.JSON(fiber.Map{"total_suppliers": count})
 }
 
-func GetSupplierProductCounts(c *fiber.Ctx) error {
-	type SupplierProductCount struct {
-		StoreName    string `json:"store_name"`
-		ProductCount int64  `json:"product_count"`
-	}
-
-	var results []SupplierProductCount
-	err := database.DB.Model(&models.Product{}).
-		Select("suppliers.store_name, COUNT(products.id) as product_count").
-		Joins("JOIN suppliers ON suppliers.id = products.supplier_id").
-		Group("suppliers.store_name").
-		Scan(&results).Error
-
+func (h *Handler) GetSupplierProductCounts(c *fiber.Ctx) error {
+	results, err := h.Reporting.SupplierProductCounts()
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve supplier product counts"})
+		return apperr.Internal("Failed to retrieve supplier product counts", err)
 	}
 
 	return c.JSON(results)
 }
 
-// with limit of suppliers
-func GetSupplierPurchaseCounts(c *fiber.Ctx) error {
-	type SupplierPurchaseCount struct {
-		StoreName     string `json:"store_name"`
-		PurchaseCount int64  `json:"purchase_count"`
-	}
-
-	var results []SupplierPurchaseCount
-	err := database.DB.Model(&models.Supplier{}).
-		Select("suppliers.store_name, COUNT(orders.id) as purchase_count").
-		Joins("LEFT JOIN orders ON orders.supplier_id = suppliers.id").
-		Group("suppliers.id").
-		Order("purchase_count DESC"). // Order by purchase count in descending order
-		Limit(6).                     // Limit to the top 6 results
-		Scan(&results).Error
-
+func (h *Handler) GetSupplierPurchaseCounts(c *fiber.Ctx) error {
+	// Top 6 suppliers by purchase count
+	results, err := h.Reporting.SupplierPurchaseCounts(6)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve supplier purchase counts"})
+		return apperr.Internal("Failed to retrieve supplier purchase counts", err)
 	}
 
 	return c.JSON(results)
 }
 
-func GetSupplierPurchaseCount(c *fiber.Ctx) error {
-	type SupplierPurchaseCount struct {
-		StoreName     string `json:"store_name"`
-		PurchaseCount int64  `json:"purchase_count"`
-	}
-
-	var results []SupplierPurchaseCount
-	err := database.DB.Model(&models.Supplier{}).
-		Select("suppliers.store_name, COUNT(orders.id) as purchase_count").
-		Joins("LEFT JOIN orders ON orders.supplier_id = suppliers.id").
-		Group("suppliers.id").
-		Scan(&results).Error
-
+func (h *Handler) GetSupplierPurchaseCount(c *fiber.Ctx) error {
+	results, err := h.Reporting.SupplierPurchaseCounts(0)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve supplier purchase counts"})
+		return apperr.Internal("Failed to retrieve supplier purchase counts", err)
 	}
 
 	return c.JSON(results)
 }
 
-func GetSupplierPurchasesByID(c *fiber.Ctx) error {
-	type SupplierPurchaseCount struct {
-		StoreName     string `json:"store_name"`
-		Email         string `json:"email"`
-		PurchaseCount int64  `json:"purchase_count"`
+func (h *Handler) GetSupplierPurchasesByID(c *fiber.Ctx) error {
+	result, err := h.Reporting.SupplierPurchasesByID(paramID(c.Params("id")))
+	if errors.Is(err, services.ErrNotFound) {
+		return apperr.NotFound("Supplier not found")
 	}
-
-	supplierID := c.Params("id") // Get supplier ID from the URL parameter
-	var result SupplierPurchaseCount
-
-	err := database.DB.Model(&models.Supplier{}).
-		Select("suppliers.store_name, suppliers.email, COUNT(orders.id) as purchase_count").
-		Joins("LEFT JOIN orders ON orders.supplier_id = suppliers.id").
-		Where("suppliers.id = ?", supplierID).
-		Group("suppliers.id").
-		Scan(&result).Error
-
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve purchase count for supplier"})
-	}
-
-	// Check if the supplier exists
-	if result.StoreName == "" {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+		return apperr.Internal("Failed to retrieve purchase count for supplier", err)
 	}
 
 	return c.JSON(result)
 }
 
-func GetMyTotalPurchases(c *fiber.Ctx) error {
+func (h *Handler) GetMyTotalPurchases(c *fiber.Ctx) error {
 	// Retrieve supplier_id from the middleware
 	supplierID, ok := c.Locals("supplier_id").(uint)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		return apperr.Unauthorized("Unauthorized")
 	}
 
-	var supplier models.Supplier
-
-	// Query the database for the supplier's purchase count
-	err := database.DB.Model(&models.Supplier{}).
-		Where("id = ?", supplierID).
-		Select("purchased").
-		First(&supplier).Error
+	supplier, err := h.Suppliers.Get(supplierID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+		if errors.Is(err, services.ErrNotFound) {
+			return apperr.NotFound("Supplier not found")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve purchase data"})
+		return apperr.Internal("Failed to retrieve purchase data", err)
 	}
 
 	// Return the result
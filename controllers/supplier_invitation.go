@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+const supplierInvitationTTL = 72 * time.Hour
+
+// sendSupplierInvitation issues a fresh activation token for a pending
+// supplier, replacing any earlier one, and emails the activation link. The
+// new token is audited as actor's; it stays issued when the email fails.
+func (h *Handler) sendSupplierInvitation(actor audit.Actor, supplier models.Supplier) error {
+	var token string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = issueAccountTokenTx(tx, models.TokenPurposeSupplierActivation, models.SubjectSupplier, supplier.ID, supplierInvitationTTL)
+		if err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "supplier", supplier.ID, nil, fiber.Map{"invitation": "issued"})
+	})
+	if err != nil {
+		return err
+	}
+
+	link := h.FrontendURL + "/activate?token=" + token
+	body := "Hello " + supplier.StoreName + ",\n\nYou have been invited to sell on the OTOP.PH platform. " +
+		"Use the link below within 72 hours to set your password and activate your account:\n\n" + link
+	return h.Mailer.Send(supplier.Email, "Activate your supplier account", body)
+}
+
+var (
+	errSupplierActive = errors.New("supplier is already active")
+	errNoInvitation   = errors.New("no outstanding invitation")
+)
+
+// findPendingSupplier loads a supplier that has not been activated yet.
+func (h *Handler) findPendingSupplier(id string) (models.Supplier, error) {
+	var supplier models.Supplier
+	if err := h.DB.First(&supplier, id).Error; err != nil {
+		return supplier, err
+	}
+	if supplier.Status != models.SupplierPending {
+		return supplier, errSupplierActive
+	}
+	return supplier, nil
+}
+
+func pendingSupplierError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperr.NotFound("Supplier not found")
+	case errors.Is(err, errSupplierActive):
+		return apperr.Conflict("Supplier is already active")
+	default:
+		return apperr.Internal("Failed to fetch supplier", err)
+	}
+}
+
+// ResendSupplierInvitation emails a new activation link. The previous link
+// stops working.
+func (h *Handler) ResendSupplierInvitation(c *fiber.Ctx) error {
+	supplier, err := h.findPendingSupplier(c.Params("id"))
+	if err != nil {
+		return pendingSupplierError(err)
+	}
+
+	if err := h.sendSupplierInvitation(audit.ActorOf(c), supplier); err != nil {
+		return apperr.Wrap(err, apperr.CodeMailFailed, "Failed to send invitation email")
+	}
+
+	return c.JSON(fiber.Map{"message": "Invitation sent"})
+}
+
+// RevokeSupplierInvitation invalidates the outstanding activation link. The
+// supplier stays pending and can be invited again later.
+func (h *Handler) RevokeSupplierInvitation(c *fiber.Ctx) error {
+	supplier, err := h.findPendingSupplier(c.Params("id"))
+	if err != nil {
+		return pendingSupplierError(err)
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Where("purpose = ? AND subject_type = ? AND subject_id = ? AND used_at IS NULL",
+				models.TokenPurposeSupplierActivation, models.SubjectSupplier, supplier.ID).
+			Delete(&models.AccountToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNoInvitation
+		}
+		return audit.RecordTx(tx, audit.ActorOf(c), audit.ActionUpdate, "supplier", supplier.ID, nil, fiber.Map{"invitation": "revoked"})
+	})
+	if errors.Is(err, errNoInvitation) {
+		return apperr.NotFound("No outstanding invitation")
+	}
+	if err != nil {
+		return apperr.Internal("Failed to revoke invitation", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Invitation revoked"})
+}
+
+// ActivateSupplier lets an invited supplier set their password with the
+// emailed token. The account can sign in afterwards. Only a supplier that is
+// still pending is activated; otherwise nothing changes and the token stays
+// unused.
+func (h *Handler) ActivateSupplier(c *fiber.Ctx) error {
+	var req SetPasswordRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	var supplierID uint
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		accountToken, err := consumeAccountToken(tx, models.TokenPurposeSupplierActivation, req.Token)
+		if err != nil {
+			return err
+		}
+		supplierID = accountToken.SubjectID
+
+		result := tx.Model(&models.Supplier{}).
+			Where("id = ? AND status = ?", supplierID, models.SupplierPending).
+			Update("status", models.SupplierActive)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Deleted since the invitation, or activated some other way
+			if err := tx.Select("id").First(&models.Supplier{}, supplierID).Error; err != nil {
+				return err
+			}
+			return errSupplierActive
+		}
+		if err := setAccountPassword(tx, models.SubjectSupplier, supplierID, req.Password); err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, audit.ActorOf(c), audit.ActionUpdate, "supplier", supplierID,
+			fiber.Map{"status": models.SupplierPending}, fiber.Map{"status": models.SupplierActive})
+	})
+	if errors.Is(err, errAccountTokenInvalid) {
+		return apperr.New(apperr.CodeInvalidToken, "Invalid or expired activation link")
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, errSupplierActive) {
+		return pendingSupplierError(err)
+	}
+	if err != nil {
+		return apperr.Internal("Failed to activate account", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Account activated. You can now log in."})
+}
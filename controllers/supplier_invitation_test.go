@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+func newInvitationApp(h *Handler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Post("/api/suppliers/activate", h.ActivateSupplier)
+	app.Use(testutil.AsUser(1, "admin"))
+	app.Post("/supplier", h.CreateSupplier)
+	app.Post("/supplier/:id/invitation", h.ResendSupplierInvitation)
+	app.Delete("/supplier/:id/invitation", h.RevokeSupplierInvitation)
+	return app
+}
+
+// invite creates a pending supplier through the admin route and returns it
+// with the token from the invitation email.
+func invite(t *testing.T, db *gorm.DB, app *fiber.App, mail *fakeMailer) (models.Supplier, string) {
+	t.Helper()
+
+	status, body := doJSON(t, app, fiber.MethodPost, "/supplier", fiber.Map{"store_name": "Habi", "email": "habi@example.com"})
+	if status != fiber.StatusCreated {
+		t.Fatalf("invite: status = %d: %s", status, body)
+	}
+	var supplier models.Supplier
+	if err := db.Where("email = ?", "habi@example.com").First(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	if supplier.Status != models.SupplierPending {
+		t.Errorf("status = %q, want pending", supplier.Status)
+	}
+	sent := mail.next(t)
+	match := resetLinkToken.FindStringSubmatch(sent.body)
+	if sent.to != supplier.Email || match == nil {
+		t.Fatalf("mail = %+v", sent)
+	}
+	return supplier, match[1]
+}
+
+func activate(t *testing.T, app *fiber.App, token string) (int, []byte) {
+	t.Helper()
+	return doJSON(t, app, fiber.MethodPost, "/api/suppliers/activate", fiber.Map{"token": token, "password": "habi-password"})
+}
+
+func TestInvitedSupplierActivatesOnce(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	mail := mailerOf(h)
+	app := newInvitationApp(h)
+	supplier, token := invite(t, db, app, mail)
+
+	if status, body := activate(t, app, token); status != fiber.StatusOK {
+		t.Fatalf("activate: status = %d: %s", status, body)
+	}
+	db.First(&supplier, supplier.ID)
+	if ok, _ := utils.VerifyPassword(supplier.Password, "habi-password"); supplier.Status != models.SupplierActive || !ok {
+		t.Errorf("supplier = %q, password set %v", supplier.Status, ok)
+	}
+	if status, _ := activate(t, app, token); status != fiber.StatusBadRequest {
+		t.Errorf("second activation: status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+	if status, _ := doJSON(t, app, fiber.MethodPost, fmt.Sprintf("/supplier/%d/invitation", supplier.ID), nil); status != fiber.StatusConflict {
+		t.Errorf("resend to an active supplier: status = %d, want %d", status, fiber.StatusConflict)
+	}
+}
+
+func TestRevokedInvitationCannotActivate(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	mail := mailerOf(h)
+	app := newInvitationApp(h)
+	supplier, token := invite(t, db, app, mail)
+	path := fmt.Sprintf("/supplier/%d/invitation", supplier.ID)
+
+	if status, body := doJSON(t, app, fiber.MethodDelete, path, nil); status != fiber.StatusOK {
+		t.Fatalf("revoke: status = %d: %s", status, body)
+	}
+	if status, _ := doJSON(t, app, fiber.MethodDelete, path, nil); status != fiber.StatusNotFound {
+		t.Errorf("second revoke: status = %d, want %d", status, fiber.StatusNotFound)
+	}
+	if status, _ := activate(t, app, token); status != fiber.StatusBadRequest {
+		t.Errorf("revoked token: status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+
+	// A resent invitation works, and the old link still does not
+	if status, body := doJSON(t, app, fiber.MethodPost, path, nil); status != fiber.StatusOK {
+		t.Fatalf("resend: status = %d: %s", status, body)
+	}
+	fresh := resetLinkToken.FindStringSubmatch(mail.next(t).body)[1]
+	if status, body := activate(t, app, fresh); status != fiber.StatusOK {
+		t.Errorf("resent token: status = %d: %s", status, body)
+	}
+}
+
+func TestActivationOnlyChangesPendingSuppliers(t *testing.T) {
+	db := testutil.NewDB(t)
+	h := newHandler(db)
+	mail := mailerOf(h)
+	app := newInvitationApp(h)
+	supplier, token := invite(t, db, app, mail)
+
+	// Activated some other way in the meantime
+	if err := db.Model(&supplier).Update("status", models.SupplierActive).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, body := activate(t, app, token); status != fiber.StatusConflict {
+		t.Errorf("active supplier: status = %d, want %d: %s", status, fiber.StatusConflict, body)
+	}
+	db.First(&supplier, supplier.ID)
+	if supplier.Password != "" {
+		t.Error("password set on a supplier that was not pending")
+	}
+
+	// Deleted in the meantime: the token was left unused, so it is tried again
+	if err := db.Delete(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status, body := activate(t, app, token); status != fiber.StatusNotFound {
+		t.Errorf("deleted supplier: status = %d, want %d: %s", status, fiber.StatusNotFound, body)
+	}
+	var audits int64
+	if err := db.Model(&models.AuditLog{}).Where("entity = ? AND entity_id = ? AND action = ? AND after LIKE ?", "supplier", fmt.Sprint(supplier.ID), audit.ActionUpdate, "%"+models.SupplierActive+"%").Count(&audits).Error; err != nil {
+		t.Fatal(err)
+	}
+	if audits != 0 {
+		t.Errorf("audit entries = %d for activations that did not happen", audits)
+	}
+}
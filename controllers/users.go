@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/logging"
+	"github.com/m/models"
+	"github.com/m/services"
+	"github.com/m/utils"
+)
+
+func userError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return apperr.NotFound("User not found")
+	case errors.Is(err, services.ErrEmailTaken):
+		return apperr.New(apperr.CodeEmailTaken, "Email is already registered")
+	case errors.Is(err, services.ErrLastAdmin):
+		return apperr.Conflict("At least one active admin must remain")
+	default:
+		return apperr.Internal(fallback, err)
+	}
+}
+
+// GetUsers lists staff accounts. Filters: role, disabled.
+func (h *Handler) GetUsers(c *fiber.Ctx) error {
+	opts, err := listing.Parse(c.Queries(), services.UserListing)
+	if err != nil {
+		return err
+	}
+
+	users, err := h.Users.List(opts)
+	if err != nil {
+		return apperr.Internal("Failed to fetch users", err)
+	}
+	return c.JSON(users)
+}
+
+func (h *Handler) GetUser(c *fiber.Ctx) error {
+	user, err := h.Users.Get(paramID(c.Params("id")))
+	if err != nil {
+		return userError(err, "Failed to fetch user")
+	}
+	return c.JSON(user)
+}
+
+// CreateUser adds an admin or cashier account and emails the new user.
+func (h *Handler) CreateUser(c *fiber.Ctx) error {
+	var input CreateUserRequest
+	if err := bind(c, &input); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return apperr.Internal("Error saving user", err)
+	}
+
+	user, err := h.Users.Create(audit.ActorOf(c), models.User{
+		UserName: input.UserName,
+		Email:    strings.TrimSpace(input.Email),
+		Password: hash,
+		Role:     input.Role,
+	})
+	if err != nil {
+		return userError(err, "Error saving user")
+	}
+
+	if err := h.Mailer.Send(user.Email, "Account Created",
+		"Hello "+user.UserName+",\n\nAn OTOP.PH "+user.Role+" account has been created for you."); err != nil {
+		logging.From(c.UserContext()).Error("sending account created email", "user_id", user.ID, "error", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+// UpdateUser changes a user's username, email or role. Role changes sign the
+// user out so the new permissions apply at once.
+func (h *Handler) UpdateUser(c *fiber.Ctx) error {
+	var input UpdateUserRequest
+	if err := bind(c, &input); err != nil {
+		return err
+	}
+	if input.Email != nil {
+		email := strings.TrimSpace(*input.Email)
+		input.Email = &email
+	}
+
+	change, err := h.Users.Update(audit.ActorOf(c), paramID(c.Params("id")), services.UserUpdate{
+		UserName: input.UserName,
+		Email:    input.Email,
+		Role:     input.Role,
+	})
+	if err != nil {
+		return userError(err, "Failed to update user")
+	}
+
+	return c.JSON(change.After)
+}
+
+// setUserDisabled disables or re-enables a user. Disabling also ends every
+// session the user has.
+func (h *Handler) setUserDisabled(c *fiber.Ctx, disabled bool) error {
+	change, err := h.Users.SetDisabled(audit.ActorOf(c), paramID(c.Params("id")), disabled)
+	if err != nil {
+		return userError(err, "Failed to update user")
+	}
+
+	return c.JSON(change.After)
+}
+
+func (h *Handler) DisableUser(c *fiber.Ctx) error {
+	return h.setUserDisabled(c, true)
+}
+
+func (h *Handler) EnableUser(c *fiber.Ctx) error {
+	return h.setUserDisabled(c, false)
+}
+
+// DeleteUser removes a staff account and ends its sessions.
+func (h *Handler) DeleteUser(c *fiber.Ctx) error {
+	if _, err := h.Users.Delete(audit.ActorOf(c), paramID(c.Params("id"))); err != nil {
+		return userError(err, "Failed to delete user")
+	}
+
+	return c.JSON(fiber.Map{"message": "User deleted successfully"})
+}
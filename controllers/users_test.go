@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"github.com/m/validation"
+	"gorm.io/gorm"
+)
+
+func newUsersApp(db *gorm.DB) *fiber.App {
+	h := newHandler(db)
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Use(testutil.AsUser(1, "admin"))
+	app.Post("/api/users", h.CreateUser)
+	app.Post("/api/users/:id/disable", h.DisableUser)
+	return app
+}
+
+func seedAdmins(t *testing.T, db *gorm.DB, n int) []models.User {
+	t.Helper()
+
+	admins := make([]models.User, n)
+	for i := range admins {
+		admins[i] = models.User{UserName: fmt.Sprintf("admin%d", i), Email: fmt.Sprintf("admin%d@example.com", i), Role: "admin"}
+	}
+	if err := db.Create(&admins).Error; err != nil {
+		t.Fatal(err)
+	}
+	return admins
+}
+
+func activeAdmins(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+
+	var n int64
+	if err := db.Model(&models.User{}).Where("role = ? AND disabled = ?", "admin", false).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLastActiveAdminCannotBeDisabled(t *testing.T) {
+	db := testutil.NewDB(t)
+	admins := seedAdmins(t, db, 2)
+	app := newUsersApp(db)
+
+	if status, resp := doJSON(t, app, fiber.MethodPost, fmt.Sprintf("/api/users/%d/disable", admins[1].ID), nil); status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, resp)
+	}
+	if status, resp := doJSON(t, app, fiber.MethodPost, fmt.Sprintf("/api/users/%d/disable", admins[0].ID), nil); status != fiber.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", status, fiber.StatusConflict, resp)
+	}
+	if n := activeAdmins(t, db); n != 1 {
+		t.Errorf("active admins = %d, want 1", n)
+	}
+}
+
+// Only PostgreSQL has the row locks this relies on.
+func TestAdminsDisablingEachOtherLeaveOneActive(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	admins := seedAdmins(t, db, 2)
+	app := newUsersApp(db)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, len(admins))
+	for i, admin := range admins {
+		wg.Add(1)
+		go func(i int, id uint) {
+			defer wg.Done()
+			statuses[i], _ = doJSON(t, app, fiber.MethodPost, fmt.Sprintf("/api/users/%d/disable", id), nil)
+		}(i, admin.ID)
+	}
+	wg.Wait()
+
+	if n := activeAdmins(t, db); n != 1 {
+		t.Errorf("active admins = %d, want 1 (statuses %v)", n, statuses)
+	}
+}
+
+func TestCreateUserRejectsPasswordsBcryptCannotHash(t *testing.T) {
+	app := newUsersApp(testutil.NewDB(t))
+
+	// 25 three-byte characters: few enough characters, too many bytes
+	status, body := doJSON(t, app, fiber.MethodPost, "/api/users", fiber.Map{
+		"username": "till", "email": "till@example.com", "role": "cashier", "password": strings.Repeat("€", 25),
+	})
+	if status != fiber.StatusBadRequest || !strings.Contains(string(body), "72 bytes") {
+		t.Errorf("status = %d, want %d: %s", status, fiber.StatusBadRequest, body)
+	}
+
+	req := CreateUserRequest{UserName: "till", Email: "till@example.com", Role: "cashier", Password: strings.Repeat("€", 24)}
+	if err := validation.Validate(&req); err != nil {
+		t.Errorf("72-byte password: %v", err)
+	}
+}
@@ -2,36 +2,38 @@ package database
 
 import (
 	"log"
+	"log/slog"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
-	"github.com/joho/godotenv"
-
-	"github.com/m/models"
+	"github.com/m/config"
 )
 
 var DB *gorm.DB
 
-func LoadEnv() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-}
-
-func SetupDatabase() {
-	LoadEnv()
-
-	dsn := "host=localhost port=5432 user=postgres dbname=postgres password=postgres"
+// SetupDatabase connects and sizes the pool. It does not touch the schema; see
+// MigrateUp.
+func SetupDatabase(cfg config.DatabaseConfig) {
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Queries are timed and slow ones logged by metrics.QueryTimer; GORM's own
+	// logger would write plain text between the JSON lines
+	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL using GORM")
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Fatalf("Failed to configure the database pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
-	DB.AutoMigrate(&models.Product{}, &models.User{}, &models.Supplier{}, &models.OtopProducts{},
-		&models.SoldItems{}, &models.Transaction{}, &models.TransactionItem{}, &models.TransactionSupplier{})
+	slog.Info("connected to the database", "host", cfg.Host, "name", cfg.Name)
 }
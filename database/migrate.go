@@ -0,0 +1,266 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema changes live in migrations/ as NNNN_description.up.sql and a
+// matching .down.sql that reverses it. Changing a model means adding a new
+// pair; applied files must never be edited.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration is one applied migration in the schema_migrations table.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// MigrationStatus pairs a known migration with when it was applied, if ever.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns every embedded migration in version order.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", file)
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_description.%s.sql", file, direction)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" bigint PRIMARY KEY,
+		"name" text NOT NULL,
+		"applied_at" timestamptz NOT NULL
+	)`).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Status lists every migration with the time it was applied.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// SchemaVersion is the highest applied migration, or 0 for an empty database.
+func SchemaVersion(db *gorm.DB) (int, error) {
+	var version int
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// LatestVersion is the newest embedded migration, i.e. the schema version this
+// build of the code expects.
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating, so
+// two instances starting at once apply each migration only once.
+const migrationLockID = 0x6f746f70 // "otop"
+
+// withMigrationLock runs fn on a single connection holding the advisory lock.
+// SQLite has a single writer already and runs fn as is.
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if db.Dialector.Name() != "postgres" {
+		return fn(db)
+	}
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+			return fmt.Errorf("taking the migration lock: %w", err)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+		return fn(conn)
+	})
+}
+
+// adoptBaseline runs before 0001. AutoMigrate databases from before shifts
+// have a transactions table without the two columns 0001 indexes; adding them
+// lets 0001 adopt such a database, and 0007 backfills them. On an empty
+// database there is no transactions table yet and this does nothing.
+const adoptBaseline = `ALTER TABLE IF EXISTS "transactions" ADD COLUMN IF NOT EXISTS "shift_id" bigint;
+ALTER TABLE IF EXISTS "transactions" ADD COLUMN IF NOT EXISTS "cashier_id" bigint;`
+
+// MigrateUp applies every pending migration, each in its own transaction.
+func MigrateUp(db *gorm.DB) error {
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		statuses, err := Status(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			if s.AppliedAt != nil {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if s.Version == 1 {
+					if err := tx.Exec(adoptBaseline).Error; err != nil {
+						return err
+					}
+				}
+				if err := tx.Exec(s.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: s.Version, Name: s.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", s.Version, s.Name, err)
+			}
+			slog.Info("applied migration", "version", s.Version, "name", s.Name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the latest steps applied migrations.
+func MigrateDown(db *gorm.DB, steps int) error {
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		statuses, err := Status(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && steps > 0; i-- {
+			s := statuses[i]
+			if s.AppliedAt == nil {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(s.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, s.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("reverting migration %04d_%s: %w", s.Version, s.Name, err)
+			}
+			slog.Info("reverted migration", "version", s.Version, "name", s.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// RequireMigrated fails when any migration has not been applied, so the
+// server never runs against a schema older than its code.
+func RequireMigrated(db *gorm.DB) error {
+	statuses, err := Status(db)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database has %d pending migration(s): %s; run `migrate up` first", len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package database_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m/database"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The models as AutoMigrate last saw them before versioned migrations,
+// trimmed to what shapes the schema.
+type (
+	baselineUser struct {
+		gorm.Model
+		UserName string
+		Email    string
+		Password string
+		Role     string
+	}
+	baselineSupplier struct {
+		gorm.Model
+		StoreName    string `gorm:"unique"`
+		Email        string
+		PhoneNumber  string
+		Address      string
+		Password     string
+		Role         string                 `gorm:"default:'supplier'"`
+		Products     []baselineProduct      `gorm:"foreignKey:SupplierID"`
+		Purchased    int                    `gorm:"default:0"`
+		OtopProducts []baselineOtopProducts `gorm:"foreignKey:SupplierID"`
+	}
+	baselineProduct struct {
+		gorm.Model
+		SequentialNumber string
+		Name             string
+		Description      string
+		Price            float64
+		Quantity         int64
+		SupplierID       uint
+		Category         string
+	}
+	baselineOtopProducts struct {
+		gorm.Model
+		Name             string
+		Description      string `gorm:"not null"`
+		Price            float64
+		Quantity         int64
+		Category         string
+		SupplierID       uint
+		Supplier         baselineSupplier `gorm:"foreignKey:SupplierID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+		StoreName        string
+		SequentialNumber string
+	}
+	baselineSoldItems struct {
+		gorm.Model
+		ProductID    uint
+		Product      baselineOtopProducts `gorm:"foreignKey:ProductID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+		QuantitySold int64
+		TotalAmount  float64
+		SoldDate     time.Time
+		SupplierID   uint
+	}
+	baselineTransaction struct {
+		gorm.Model
+		Total            float64
+		Received         float64
+		Change           float64
+		SupplierID       uint
+		Supplier         baselineSupplier
+		TransactionItems []baselineTransactionItem `gorm:"foreignKey:TransactionID"`
+	}
+	baselineTransactionItem struct {
+		ID            uint `gorm:"primaryKey"`
+		TransactionID uint
+		ProductID     uint
+		Quantity      int64
+		Price         float64
+		Total         float64
+		SupplierID    uint
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+	}
+	baselineTransactionSupplier struct {
+		ID            uint `gorm:"primaryKey"`
+		TransactionID uint
+		SupplierID    uint
+		Transaction   baselineTransaction `gorm:"foreignKey:TransactionID"`
+		Supplier      baselineSupplier    `gorm:"foreignKey:SupplierID"`
+	}
+)
+
+func (baselineUser) TableName() string                { return "users" }
+func (baselineSupplier) TableName() string            { return "suppliers" }
+func (baselineProduct) TableName() string             { return "products" }
+func (baselineOtopProducts) TableName() string        { return "otop_products" }
+func (baselineSoldItems) TableName() string           { return "sold_items" }
+func (baselineTransaction) TableName() string         { return "transactions" }
+func (baselineTransactionItem) TableName() string     { return "transaction_items" }
+func (baselineTransactionSupplier) TableName() string { return "transaction_suppliers" }
+
+func openPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.Open(testutil.PostgresDSN(t)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestMigrateUpAdoptsABaselineDatabase(t *testing.T) {
+	db := openPostgres(t)
+	err := db.AutoMigrate(&baselineProduct{}, &baselineUser{}, &baselineSupplier{}, &baselineOtopProducts{},
+		&baselineSoldItems{}, &baselineTransaction{}, &baselineTransactionItem{}, &baselineTransactionSupplier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	supplier := baselineSupplier{StoreName: "Albay Delicacies", Email: "albay@example.com", Password: "secret"}
+	for _, row := range []interface{}{
+		&baselineUser{UserName: "ana", Email: "ana@example.com", Role: "admin"},
+		&supplier,
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&baselineTransaction{Total: 112, Received: 200, Change: 88, SupplierID: supplier.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RequireMigrated(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The current models load the old rows, with the new columns backfilled
+	var user models.User
+	if err := db.First(&user).Error; err != nil || user.Disabled {
+		t.Errorf("user = %+v, err = %v", user, err)
+	}
+	var adopted models.Supplier
+	if err := db.First(&adopted).Error; err != nil || adopted.Status != models.SupplierActive {
+		t.Errorf("supplier = %+v, err = %v", adopted, err)
+	}
+	var sale models.Transaction
+	if err := db.Preload("Payments").First(&sale).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sale.CashierID != 0 || sale.ShiftID != nil || sale.Subtotal != 112 || sale.VAT != 12 || sale.VatableSales != 100 {
+		t.Errorf("sale = %+v", sale)
+	}
+	if len(sale.Payments) != 1 || sale.Payments[0].Method != models.TenderCash || sale.Payments[0].Change != 88 {
+		t.Errorf("payments = %+v", sale.Payments)
+	}
+
+	// Every table the application uses is there
+	for _, table := range []string{"orders", "sessions", "account_tokens", "login_throttles", "lockout_events", "audit_logs", "shifts", "transaction_payments"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table %s is missing", table)
+		}
+	}
+}
+
+func TestMigrationsRoundTripOnAnEmptyDatabase(t *testing.T) {
+	db := openPostgres(t)
+	latest, err := database.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.MigrateDown(db, latest); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := database.SchemaVersion(db); err != nil || version != 0 {
+		t.Fatalf("version after down = %d, err = %v", version, err)
+	}
+	if err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RequireMigrated(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentMigrateUpAppliesEachMigrationOnce(t *testing.T) {
+	db := openPostgres(t)
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- database.MigrateUp(db)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var applied int64
+	if err := db.Model(&database.SchemaMigration{}).Count(&applied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if applied != int64(len(migrations)) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+}
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS "shifts";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "lockout_events";
+DROP TABLE IF EXISTS "login_throttles";
+DROP TABLE IF EXISTS "account_tokens";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "transaction_suppliers";
+DROP TABLE IF EXISTS "transaction_items";
+DROP TABLE IF EXISTS "transactions";
+DROP TABLE IF EXISTS "sold_items";
+DROP TABLE IF EXISTS "otop_products";
+DROP TABLE IF EXISTS "products";
+DROP TABLE IF EXISTS "suppliers";
+DROP TABLE IF EXISTS "users";
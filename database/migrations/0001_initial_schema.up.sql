@@ -0,0 +1,222 @@
+-- Initial schema, matching what AutoMigrate created before versioned
+-- migrations. IF NOT EXISTS lets this adopt a database that AutoMigrate
+-- already set up.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_name" text,
+    "email" text,
+    "password" text,
+    "role" text,
+    "disabled" boolean DEFAULT false,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "suppliers" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "store_name" text,
+    "email" text,
+    "phone_number" text,
+    "address" text,
+    "password" text,
+    "role" text DEFAULT 'supplier',
+    "status" text DEFAULT 'active',
+    "purchased" bigint DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_suppliers_store_name" UNIQUE ("store_name")
+);
+CREATE INDEX IF NOT EXISTS "idx_suppliers_deleted_at" ON "suppliers" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "products" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "sequential_number" text,
+    "name" text,
+    "description" text,
+    "price" decimal,
+    "quantity" bigint,
+    "supplier_id" bigint,
+    "category" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_suppliers_products" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "otop_products" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text,
+    "description" text NOT NULL,
+    "price" decimal,
+    "quantity" bigint,
+    "category" text,
+    "supplier_id" bigint,
+    "store_name" text,
+    "sequential_number" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_suppliers_otop_products" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_otop_products_deleted_at" ON "otop_products" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "sold_items" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "product_id" bigint,
+    "quantity_sold" bigint,
+    "total_amount" decimal,
+    "sold_date" timestamptz,
+    "supplier_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sold_items_product" FOREIGN KEY ("product_id") REFERENCES "otop_products"("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_sold_items_deleted_at" ON "sold_items" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "transactions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "total" decimal,
+    "received" decimal,
+    "change" decimal,
+    "supplier_id" bigint,
+    "shift_id" bigint,
+    "cashier_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transactions_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transactions_cashier_id" ON "transactions" ("cashier_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_shift_id" ON "transactions" ("shift_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_deleted_at" ON "transactions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "transaction_items" (
+    "id" bigserial,
+    "transaction_id" bigint,
+    "product_id" bigint,
+    "quantity" bigint,
+    "price" decimal,
+    "total" decimal,
+    "supplier_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transactions_transaction_items" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "transaction_suppliers" (
+    "id" bigserial,
+    "transaction_id" bigint,
+    "supplier_id" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transaction_suppliers_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id"),
+    CONSTRAINT "fk_transaction_suppliers_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" varchar(64),
+    "subject_type" text,
+    "subject_id" bigint,
+    "refresh_token_hash" text,
+    "previous_token_hash" text,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_previous_token_hash" ON "sessions" ("previous_token_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token_hash");
+CREATE INDEX IF NOT EXISTS "idx_sessions_subject" ON "sessions" ("subject_type","subject_id");
+
+CREATE TABLE IF NOT EXISTS "account_tokens" (
+    "id" bigserial,
+    "purpose" text,
+    "subject_type" text,
+    "subject_id" bigint,
+    "token_hash" text,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_account_tokens_purpose" ON "account_tokens" ("purpose");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_account_tokens_token_hash" ON "account_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "login_throttles" (
+    "key" varchar(320),
+    "failures" bigint,
+    "last_failure_at" timestamptz,
+    "locked_until" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "lockout_events" (
+    "id" bigserial,
+    "key" text,
+    "email" text,
+    "ip" text,
+    "failures" bigint,
+    "locked_until" timestamptz,
+    "unlocked_at" timestamptz,
+    "unlocked_by" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_lockout_events_key" ON "lockout_events" ("key");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bigserial,
+    "actor_type" text,
+    "actor_id" bigint,
+    "actor_name" text,
+    "actor_role" text,
+    "action" text,
+    "entity" text,
+    "entity_id" text,
+    "before" jsonb,
+    "after" jsonb,
+    "changes" jsonb,
+    "ip" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor" ON "audit_logs" ("actor_type","actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_entity" ON "audit_logs" ("entity","entity_id");
+
+CREATE TABLE IF NOT EXISTS "shifts" (
+    "id" bigserial,
+    "cashier_id" bigint,
+    "status" text,
+    "opened_at" timestamptz,
+    "closed_at" timestamptz,
+    "opening_cash" decimal,
+    "cash_sales" decimal,
+    "expected_cash" decimal,
+    "counted_cash" decimal,
+    "variance" decimal,
+    "transaction_count" bigint,
+    "notes" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shifts_cashier" FOREIGN KEY ("cashier_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shifts_status" ON "shifts" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_shifts_open_cashier" ON "shifts" ("cashier_id") WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS "idx_shifts_cashier_id" ON "shifts" ("cashier_id");
@@ -0,0 +1,20 @@
+-- models.Order was never auto-migrated, but orders are created and joined
+-- in supplier purchase reports.
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "admin_id" bigint,
+    "supplier_id" bigint,
+    "product_id" bigint,
+    "product_name" text,
+    "quantity" bigint,
+    "price" decimal,
+    "order_date" timestamptz,
+    "status" text,
+    "descriptiom" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
@@ -0,0 +1,3 @@
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "vat";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "discount";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "subtotal";
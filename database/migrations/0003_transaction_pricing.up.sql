@@ -0,0 +1,7 @@
+-- Checkout now prices sales on the server and keeps the breakdown.
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "subtotal" decimal;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "discount" decimal NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "vat" decimal;
+
+-- Earlier sales were never discounted; their VAT is the 12% included in the total
+UPDATE "transactions" SET "subtotal" = "total", "vat" = ROUND("total" * 12 / 112, 2) WHERE "subtotal" IS NULL;
@@ -0,0 +1,18 @@
+-- Transactions can be paid with several tenders; each is a payment line.
+CREATE TABLE IF NOT EXISTS "transaction_payments" (
+    "id" bigserial,
+    "transaction_id" bigint,
+    "method" text,
+    "amount" decimal,
+    "change" decimal NOT NULL DEFAULT 0,
+    "reference" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transactions_payments" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transaction_payments_transaction_id" ON "transaction_payments" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_transaction_payments_method" ON "transaction_payments" ("method");
+
+-- Every earlier sale was paid in cash
+INSERT INTO "transaction_payments" ("transaction_id", "method", "amount", "change", "reference", "created_at")
+SELECT "id", 'cash', "received", "change", '', "created_at" FROM "transactions";
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "idx_transactions_statutory_type";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "statutory_vat_exemption";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "statutory_eligible_sales";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "statutory_name";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "statutory_id_number";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "statutory_type";
@@ -0,0 +1,8 @@
+-- Senior citizen and PWD discounts are recorded on the transaction for the
+-- monthly discount register.
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "statutory_type" text NOT NULL DEFAULT '';
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "statutory_id_number" text NOT NULL DEFAULT '';
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "statutory_name" text NOT NULL DEFAULT '';
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "statutory_eligible_sales" decimal NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "statutory_vat_exemption" decimal NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS "idx_transactions_statutory_type" ON "transactions" ("statutory_type", "created_at") WHERE "statutory_type" <> '';
@@ -0,0 +1,6 @@
+ALTER TABLE "transaction_items" DROP COLUMN IF EXISTS "vat";
+ALTER TABLE "transaction_items" DROP COLUMN IF EXISTS "vat_class";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "zero_rated_sales";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "vat_exempt_sales";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "vatable_sales";
+ALTER TABLE "otop_products" DROP COLUMN IF EXISTS "vat_class";
@@ -0,0 +1,20 @@
+-- Products can be VAT exempt or zero-rated, and every sale keeps its VAT
+-- breakdown.
+ALTER TABLE "otop_products" ADD COLUMN IF NOT EXISTS "vat_class" text NOT NULL DEFAULT 'category';
+
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "vatable_sales" decimal NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "vat_exempt_sales" decimal NOT NULL DEFAULT 0;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "zero_rated_sales" decimal NOT NULL DEFAULT 0;
+
+ALTER TABLE "transaction_items" ADD COLUMN IF NOT EXISTS "vat_class" text NOT NULL DEFAULT 'vatable';
+ALTER TABLE "transaction_items" ADD COLUMN IF NOT EXISTS "vat" decimal NOT NULL DEFAULT 0;
+
+-- Everything sold so far was vatable, apart from senior and PWD lines, which
+-- were sold exempt once their VAT was taken off
+UPDATE "transactions" SET
+    "vat_exempt_sales" = "statutory_eligible_sales" - "statutory_vat_exemption",
+    "vatable_sales" = "subtotal" - "statutory_eligible_sales" - "vat";
+
+-- Line VAT is only recovered for undiscounted sales, where every line kept it
+UPDATE "transaction_items" SET "vat" = ROUND("total" * 12 / 112, 2)
+WHERE "transaction_id" IN (SELECT "id" FROM "transactions" WHERE "statutory_type" = '');
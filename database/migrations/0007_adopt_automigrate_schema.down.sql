@@ -0,0 +1,3 @@
+-- Nothing to revert: 0001 owns these columns and tables, and reverting it
+-- drops them.
+SELECT 1;
@@ -0,0 +1,117 @@
+-- 0001 creates all of this on an empty database. On a database AutoMigrate
+-- set up earlier its CREATE TABLE IF NOT EXISTS leaves the existing tables
+-- as they were, so the columns added to them since are filled in here.
+-- Everything is IF NOT EXISTS, leaving a database 0001 created untouched.
+
+-- Staff accounts can be disabled; every existing one stays enabled
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "disabled" boolean DEFAULT false;
+UPDATE "users" SET "disabled" = false WHERE "disabled" IS NULL;
+
+-- Invited suppliers are pending until they set a password. Existing
+-- suppliers already sign in with theirs.
+ALTER TABLE "suppliers" ADD COLUMN IF NOT EXISTS "status" text DEFAULT 'active';
+UPDATE "suppliers" SET "status" = 'active' WHERE "status" IS NULL;
+
+-- Sales are rung up by a cashier in a shift. Earlier sales had neither;
+-- cashier 0 marks them as unknown.
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "shift_id" bigint;
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "cashier_id" bigint;
+UPDATE "transactions" SET "cashier_id" = 0 WHERE "cashier_id" IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_transactions_cashier_id" ON "transactions" ("cashier_id");
+CREATE INDEX IF NOT EXISTS "idx_transactions_shift_id" ON "transactions" ("shift_id");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" varchar(64),
+    "subject_type" text,
+    "subject_id" bigint,
+    "refresh_token_hash" text,
+    "previous_token_hash" text,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_previous_token_hash" ON "sessions" ("previous_token_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token_hash");
+CREATE INDEX IF NOT EXISTS "idx_sessions_subject" ON "sessions" ("subject_type","subject_id");
+
+CREATE TABLE IF NOT EXISTS "account_tokens" (
+    "id" bigserial,
+    "purpose" text,
+    "subject_type" text,
+    "subject_id" bigint,
+    "token_hash" text,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_account_tokens_purpose" ON "account_tokens" ("purpose");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_account_tokens_token_hash" ON "account_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "login_throttles" (
+    "key" varchar(320),
+    "failures" bigint,
+    "last_failure_at" timestamptz,
+    "locked_until" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "lockout_events" (
+    "id" bigserial,
+    "key" text,
+    "email" text,
+    "ip" text,
+    "failures" bigint,
+    "locked_until" timestamptz,
+    "unlocked_at" timestamptz,
+    "unlocked_by" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_lockout_events_key" ON "lockout_events" ("key");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bigserial,
+    "actor_type" text,
+    "actor_id" bigint,
+    "actor_name" text,
+    "actor_role" text,
+    "action" text,
+    "entity" text,
+    "entity_id" text,
+    "before" jsonb,
+    "after" jsonb,
+    "changes" jsonb,
+    "ip" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor" ON "audit_logs" ("actor_type","actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_entity" ON "audit_logs" ("entity","entity_id");
+
+CREATE TABLE IF NOT EXISTS "shifts" (
+    "id" bigserial,
+    "cashier_id" bigint,
+    "status" text,
+    "opened_at" timestamptz,
+    "closed_at" timestamptz,
+    "opening_cash" decimal,
+    "cash_sales" decimal,
+    "expected_cash" decimal,
+    "counted_cash" decimal,
+    "variance" decimal,
+    "transaction_count" bigint,
+    "notes" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_shifts_cashier" FOREIGN KEY ("cashier_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_shifts_status" ON "shifts" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_shifts_open_cashier" ON "shifts" ("cashier_id") WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS "idx_shifts_cashier_id" ON "shifts" ("cashier_id");
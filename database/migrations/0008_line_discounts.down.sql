@@ -0,0 +1,2 @@
+ALTER TABLE "transaction_items" DROP COLUMN IF EXISTS "discount";
+ALTER TABLE "transaction_items" DROP COLUMN IF EXISTS "vat_exemption";
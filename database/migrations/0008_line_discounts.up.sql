@@ -0,0 +1,4 @@
+-- Sale lines keep the senior citizen and PWD discount they were given, so a
+-- supplier's share of a sale can be split by VAT like the sale itself.
+ALTER TABLE "transaction_items" ADD COLUMN IF NOT EXISTS "vat_exemption" decimal NOT NULL DEFAULT 0;
+ALTER TABLE "transaction_items" ADD COLUMN IF NOT EXISTS "discount" decimal NOT NULL DEFAULT 0;
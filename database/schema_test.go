@@ -0,0 +1,104 @@
+package database_test
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/m/database"
+	"github.com/m/models"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	sqlComment  = regexp.MustCompile(`--[^\n]*`)
+	createTable = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?"(\w+)" \((.*)\)$`)
+	tableColumn = regexp.MustCompile(`^"(\w+)" `)
+	addColumn   = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?"(\w+)" ADD COLUMN (?:IF NOT EXISTS )?"(\w+)"`)
+	dropColumn  = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?"(\w+)" DROP COLUMN (?:IF EXISTS )?"(\w+)"`)
+	dropTable   = regexp.MustCompile(`(?i)^DROP TABLE (?:IF EXISTS )?"(\w+)"`)
+)
+
+// migratedColumns replays the up migrations' CREATE TABLE and ADD/DROP
+// COLUMN statements, giving the columns of each table they leave behind.
+func migratedColumns(t *testing.T) map[string]map[string]bool {
+	t.Helper()
+
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]map[string]bool{}
+	for _, m := range migrations {
+		for _, stmt := range strings.Split(sqlComment.ReplaceAllString(m.Up, ""), ";") {
+			stmt = strings.TrimSpace(stmt)
+			if match := createTable.FindStringSubmatch(stmt); match != nil {
+				if tables[match[1]] == nil {
+					tables[match[1]] = map[string]bool{}
+				}
+				for _, line := range strings.Split(match[2], "\n") {
+					if col := tableColumn.FindStringSubmatch(strings.TrimSpace(line)); col != nil {
+						tables[match[1]][col[1]] = true
+					}
+				}
+			} else if match := addColumn.FindStringSubmatch(stmt); match != nil {
+				if tables[match[1]] == nil {
+					t.Fatalf("%04d_%s adds %s to unknown table %s", m.Version, m.Name, match[2], match[1])
+				}
+				tables[match[1]][match[2]] = true
+			} else if match := dropColumn.FindStringSubmatch(stmt); match != nil {
+				delete(tables[match[1]], match[2])
+			} else if match := dropTable.FindStringSubmatch(stmt); match != nil {
+				delete(tables, match[1])
+			}
+		}
+	}
+	return tables
+}
+
+// SQLite tests build their schema with AutoMigrate, so this keeps the
+// migrations and the models from drifting apart.
+func TestMigrationsMatchTheModels(t *testing.T) {
+	migrated := migratedColumns(t)
+
+	cache := &sync.Map{}
+	for _, model := range models.All() {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns, ok := migrated[s.Table]
+		if !ok {
+			t.Errorf("no migration creates table %s for %s", s.Table, s.Name)
+			continue
+		}
+
+		want := map[string]bool{}
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				want[field.DBName] = true
+			}
+		}
+		var missing, extra []string
+		for col := range want {
+			if !columns[col] {
+				missing = append(missing, col)
+			}
+		}
+		for col := range columns {
+			if !want[col] {
+				extra = append(extra, col)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			t.Errorf("%s: migrations lack columns %v", s.Table, missing)
+		}
+		if len(extra) > 0 {
+			t.Errorf("%s: migrations have columns %v the model does not", s.Table, extra)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package listing pages, filters and sorts collection queries the same way
+// for every list endpoint.
+//
+// Clients pass query parameters:
+//
+//	limit      page size, default 50, at most 200
+//	offset     rows to skip (offset paging)
+//	cursor     next_cursor from the previous page (cursor paging); cannot be
+//	           combined with offset
+//	sort       a sort key from the resource's Spec, "-" prefix for descending
+//	from, to   YYYY-MM-DD date range (to is inclusive) on Spec.DateColumn
+//	search     substring match on Spec.Search
+//	<filter>   equality filters named in Spec.Filters
+//
+// Parameters a Spec does not name are ignored, so only whitelisted columns
+// ever reach SQL. Responses carry the rows and a Meta with the total and the
+// cursor of the next page.
+package listing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m/apperr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Kind is how a filter value is parsed before it is compared.
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Bool
+)
+
+type Filter struct {
+	Column string
+	Kind   Kind
+}
+
+// Spec is what a resource lets clients filter and sort on.
+type Spec struct {
+	Filters     map[string]Filter // query parameter -> column
+	Sorts       map[string]string // sort key -> column
+	DefaultSort string            // sort key, "-" prefix for descending
+	DateColumn  string            // column from/to apply to; empty disables them
+	Search      string            // column search matches; empty disables it
+}
+
+// Options is a parsed, validated list request.
+type Options struct {
+	Limit  int
+	Offset int
+	Sort   string // sort key as given, e.g. "-created_at"
+	From   time.Time
+	To     time.Time // exclusive
+
+	column  string
+	desc    bool
+	cursor  *cursor
+	filters []condition
+	dateCol string
+	search  condition
+}
+
+type condition struct {
+	column string
+	value  interface{}
+}
+
+// Meta describes the page that was returned.
+type Meta struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page is one page of a collection.
+type Page[T any] struct {
+	Data []T  `json:"data"`
+	Meta Meta `json:"meta"`
+}
+
+// Parse reads list options from query parameters. Bad values come back as an
+// apperr validation error naming each parameter.
+func Parse(query map[string]string, spec Spec) (Options, error) {
+	var fields []apperr.FieldError
+	invalid := func(field, message string) {
+		fields = append(fields, apperr.FieldError{Field: field, Message: message})
+	}
+
+	opts := Options{Limit: DefaultLimit, dateCol: spec.DateColumn}
+
+	if v := query["limit"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			invalid("limit", fmt.Sprintf("must be a number from 1 to %d", MaxLimit))
+		} else {
+			opts.Limit = n
+		}
+	}
+	if v := query["offset"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			invalid("offset", "must be a non-negative number")
+		} else {
+			opts.Offset = n
+		}
+	}
+
+	opts.Sort = spec.DefaultSort
+	if v := query["sort"]; v != "" {
+		opts.Sort = v
+	}
+	key := strings.TrimPrefix(opts.Sort, "-")
+	column, ok := spec.Sorts[key]
+	if !ok {
+		invalid("sort", "must be one of: "+strings.Join(sortKeys(spec), ", "))
+	}
+	opts.column, opts.desc = column, strings.HasPrefix(opts.Sort, "-")
+
+	if v := query["cursor"]; v != "" {
+		cur, err := decodeCursor(v)
+		switch {
+		case err != nil:
+			invalid("cursor", "is not a cursor from this endpoint")
+		case opts.Offset > 0:
+			invalid("cursor", "cannot be combined with offset")
+		case cur.Sort != opts.Sort:
+			invalid("cursor", "was issued for sort "+cur.Sort)
+		default:
+			opts.cursor = &cur
+		}
+	}
+
+	if spec.DateColumn != "" {
+		for _, name := range []string{"from", "to"} {
+			v := query[name]
+			if v == "" {
+				continue
+			}
+			day, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				invalid(name, "must be a date in the format YYYY-MM-DD")
+				continue
+			}
+			if name == "from" {
+				opts.From = day
+			} else {
+				opts.To = day.Add(24 * time.Hour)
+			}
+		}
+	}
+
+	if v := query["search"]; v != "" && spec.Search != "" {
+		opts.search = condition{column: spec.Search, value: "%" + v + "%"}
+	}
+
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := query[name]
+		if v == "" {
+			continue
+		}
+		filter := spec.Filters[name]
+		var value interface{} = v
+		switch filter.Kind {
+		case Int:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				invalid(name, "must be a whole number")
+				continue
+			}
+			value = n
+		case Bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				invalid(name, "must be true or false")
+				continue
+			}
+			value = b
+		}
+		opts.filters = append(opts.filters, condition{column: filter.Column, value: value})
+	}
+
+	if len(fields) > 0 {
+		return opts, apperr.Validation(fields...)
+	}
+	return opts, nil
+}
+
+func sortKeys(spec Spec) []string {
+	keys := make([]string, 0, len(spec.Sorts))
+	for key := range spec.Sorts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Scope adds an equality condition the client cannot override, such as the
+// supplier from the route or the token.
+func (o Options) Scope(column string, value interface{}) Options {
+	o.filters = append(append([]condition(nil), o.filters...), condition{column: column, value: value})
+	return o
+}
+
+// Between sets the date range from values the handler parsed itself. to is
+// exclusive.
+func (o Options) Between(from, to time.Time) Options {
+	o.From, o.To = from, to
+	return o
+}
+
+// Where adds the filters, search and date range, but not paging or sorting, to db.
+// Use it for totals that must cover the whole filtered collection. Columns
+// are qualified with the model's table so joins stay unambiguous.
+func (o Options) Where(db *gorm.DB) *gorm.DB {
+	for _, f := range o.filters {
+		db = db.Where(clause.Eq{Column: column(f.column), Value: f.value})
+	}
+	if o.search.column != "" {
+		db = db.Where(clause.Like{Column: column(o.search.column), Value: o.search.value})
+	}
+	if !o.From.IsZero() {
+		db = db.Where(clause.Gte{Column: column(o.dateCol), Value: o.From})
+	}
+	if !o.To.IsZero() {
+		db = db.Where(clause.Lt{Column: column(o.dateCol), Value: o.To})
+	}
+	return db
+}
+
+func column(name string) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: name}
+}
+
+// Find loads one page of T. db is the base query, already scoped to what the
+// caller may see; preloads are applied to the page only, not the count.
+func Find[T any](db *gorm.DB, opts Options, preloads ...string) (Page[T], error) {
+	page := Page[T]{Data: []T{}, Meta: Meta{Limit: opts.Limit, Offset: opts.Offset, Sort: opts.Sort}}
+
+	filtered := opts.Where(db.Model(new(T)))
+	if err := filtered.Session(&gorm.Session{}).Count(&page.Meta.Total).Error; err != nil {
+		return page, err
+	}
+
+	query := filtered.Session(&gorm.Session{})
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+
+	// The primary key breaks ties so every row has one place in the order
+	query = query.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: column(opts.column), Desc: opts.desc},
+		{Column: column("id"), Desc: opts.desc},
+	}})
+
+	if opts.cursor != nil {
+		value, err := opts.cursor.value()
+		if err != nil {
+			return page, apperr.Validation(apperr.FieldError{Field: "cursor", Message: "is not a cursor from this endpoint"})
+		}
+		col, id := column(opts.column), column("id")
+		var after clause.Expression = clause.Or(
+			clause.Gt{Column: col, Value: value},
+			clause.And(clause.Eq{Column: col, Value: value}, clause.Gt{Column: id, Value: opts.cursor.ID}),
+		)
+		if opts.desc {
+			after = clause.Or(
+				clause.Lt{Column: col, Value: value},
+				clause.And(clause.Eq{Column: col, Value: value}, clause.Lt{Column: id, Value: opts.cursor.ID}),
+			)
+		}
+		query = query.Where(after)
+	} else if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+
+	// One extra row tells whether there is a next page
+	var rows []T
+	if err := query.Limit(opts.Limit + 1).Find(&rows).Error; err != nil {
+		return page, err
+	}
+	if len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+		next, err := nextCursor(db, rows[len(rows)-1], opts)
+		if err != nil {
+			return page, err
+		}
+		page.Meta.NextCursor = next
+	}
+	page.Data = rows
+	return page, nil
+}
+
+// cursor is the position after the last row of a page: its sort value and
+// primary key. Kind records the sort value's type so it is compared as the
+// same type again.
+type cursor struct {
+	Sort  string          `json:"s"`
+	Kind  string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+	ID    uint64          `json:"id"`
+}
+
+func (c cursor) value() (interface{}, error) {
+	switch c.Kind {
+	case "time":
+		var t time.Time
+		err := json.Unmarshal(c.Value, &t)
+		return t, err
+	case "int":
+		var n int64
+		err := json.Unmarshal(c.Value, &n)
+		return n, err
+	case "float":
+		var f float64
+		err := json.Unmarshal(c.Value, &f)
+		return f, err
+	default:
+		var s string
+		err := json.Unmarshal(c.Value, &s)
+		return s, err
+	}
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(raw, &c)
+	return c, err
+}
+
+var schemas sync.Map
+
+func nextCursor[T any](db *gorm.DB, last T, opts Options) (string, error) {
+	s, err := schema.Parse(new(T), &schemas, db.NamingStrategy)
+	if err != nil {
+		return "", err
+	}
+	sortField, idField := s.LookUpField(opts.column), s.LookUpField("id")
+	if sortField == nil || idField == nil {
+		return "", fmt.Errorf("listing: %s has no %s or id column", s.Name, opts.column)
+	}
+
+	row := reflect.ValueOf(&last).Elem()
+	value, _ := sortField.ValueOf(context.Background(), row)
+	id, _ := idField.ValueOf(context.Background(), row)
+
+	c := cursor{Sort: opts.Sort, Kind: "string"}
+	switch v := value.(type) {
+	case time.Time:
+		c.Kind = "time"
+	case float32, float64:
+		c.Kind = "float"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		c.Kind = "int"
+	case string:
+	default:
+		value = fmt.Sprint(v)
+	}
+	if c.Value, err = json.Marshal(value); err != nil {
+		return "", err
+	}
+	c.ID = reflect.ValueOf(id).Convert(reflect.TypeOf(uint64(0))).Uint()
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
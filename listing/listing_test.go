@@ -0,0 +1,199 @@
+package listing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m/apperr"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+var auditSpec = Spec{
+	Filters: map[string]Filter{
+		"action":   {Column: "action"},
+		"actor_id": {Column: "actor_id", Kind: Int},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+		"actor_name": "actor_name",
+	},
+	DefaultSort: "-created_at",
+	DateColumn:  "created_at",
+	Search:      "actor_name",
+}
+
+// seedLogs creates seven entries, two per day from 1 June 2024 with the
+// last day holding three, so sorting by day has ties.
+func seedLogs(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	days := []int{0, 0, 1, 1, 2, 2, 2}
+	for i, day := range days {
+		action := "update"
+		if i%2 == 0 {
+			action = "create"
+		}
+		log := models.AuditLog{
+			ActorID:   uint(i%3 + 1),
+			ActorName: []string{"ana", "ben", "carla"}[i%3],
+			Action:    action,
+			CreatedAt: start.AddDate(0, 0, day),
+		}
+		if err := db.Create(&log).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(logs []models.AuditLog) []uint {
+	out := make([]uint, len(logs))
+	for i, l := range logs {
+		out[i] = l.ID
+	}
+	return out
+}
+
+func mustParse(t *testing.T, query map[string]string) Options {
+	t.Helper()
+
+	opts, err := Parse(query, auditSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestParseDefaults(t *testing.T) {
+	opts := mustParse(t, nil)
+	if opts.Limit != DefaultLimit || opts.Offset != 0 || opts.Sort != "-created_at" {
+		t.Errorf("opts = %+v", opts)
+	}
+}
+
+func TestParseRejectsBadParameters(t *testing.T) {
+	_, err := Parse(map[string]string{
+		"limit":    "500",
+		"sort":     "password",
+		"from":     "June 1",
+		"actor_id": "ana",
+		"ignored":  "x",
+	}, auditSpec)
+
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperr.CodeValidation {
+		t.Fatalf("err = %v", err)
+	}
+	got := map[string]bool{}
+	for _, f := range appErr.Fields {
+		got[f.Field] = true
+	}
+	for _, field := range []string{"limit", "sort", "from", "actor_id"} {
+		if !got[field] {
+			t.Errorf("no error for %s in %+v", field, appErr.Fields)
+		}
+	}
+	if len(appErr.Fields) != 4 {
+		t.Errorf("fields = %+v", appErr.Fields)
+	}
+}
+
+func TestFindPagesByOffset(t *testing.T) {
+	db := testutil.NewDB(t)
+	seedLogs(t, db)
+
+	page, err := Find[models.AuditLog](db, mustParse(t, map[string]string{"sort": "id", "limit": "3", "offset": "3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(page.Data); len(got) != 3 || got[0] != 4 || got[2] != 6 {
+		t.Errorf("ids = %v, want [4 5 6]", got)
+	}
+	if page.Meta.Total != 7 || page.Meta.Offset != 3 || page.Meta.NextCursor == "" {
+		t.Errorf("meta = %+v", page.Meta)
+	}
+}
+
+func TestFindWalksCursorsAcrossTies(t *testing.T) {
+	db := testutil.NewDB(t)
+	seedLogs(t, db)
+
+	var seen []uint
+	query := map[string]string{"limit": "2"}
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatal("cursor never ran out")
+		}
+		page, err := Find[models.AuditLog](db, mustParse(t, query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, ids(page.Data)...)
+		if page.Meta.NextCursor == "" {
+			break
+		}
+		query["cursor"] = page.Meta.NextCursor
+	}
+
+	// Newest day first, and ties broken by descending id
+	want := []uint{7, 6, 5, 4, 3, 2, 1}
+	if len(seen) != len(want) {
+		t.Fatalf("ids = %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("ids = %v, want %v", seen, want)
+		}
+	}
+}
+
+func TestCursorMustMatchSort(t *testing.T) {
+	db := testutil.NewDB(t)
+	seedLogs(t, db)
+
+	page, err := Find[models.AuditLog](db, mustParse(t, map[string]string{"limit": "2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(map[string]string{"sort": "id", "cursor": page.Meta.NextCursor}, auditSpec); err == nil {
+		t.Error("cursor accepted for another sort")
+	}
+	if _, err := Parse(map[string]string{"offset": "2", "cursor": page.Meta.NextCursor}, auditSpec); err == nil {
+		t.Error("cursor accepted with offset")
+	}
+}
+
+func TestFindFiltersSearchAndDates(t *testing.T) {
+	db := testutil.NewDB(t)
+	seedLogs(t, db)
+
+	for name, tc := range map[string]struct {
+		query map[string]string
+		scope bool
+		want  int64
+	}{
+		"filter":     {query: map[string]string{"action": "create"}, want: 4},
+		"int filter": {query: map[string]string{"actor_id": "2"}, want: 2},
+		"search":     {query: map[string]string{"search": "arl"}, want: 2},
+		"date range": {query: map[string]string{"from": "2024-06-02", "to": "2024-06-02"}, want: 2},
+		"scope":      {query: map[string]string{"action": "create"}, scope: true, want: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := mustParse(t, tc.query)
+			if tc.scope {
+				opts = opts.Scope("actor_id", 1)
+			}
+			page, err := Find[models.AuditLog](db, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Meta.Total != tc.want || int64(len(page.Data)) != tc.want {
+				t.Errorf("total = %d, rows = %d, want %d", page.Meta.Total, len(page.Data), tc.want)
+			}
+		})
+	}
+}
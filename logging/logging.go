@@ -0,0 +1,41 @@
+// Package logging is the server's structured logger: JSON lines on stdout
+// through log/slog, with the request ID attached to everything logged while
+// a request is being served.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// Setup makes a JSON logger writing to w at the given level the default.
+// Libraries that still use the log package come out through it as info
+// records.
+func Setup(w io.Writer, level slog.Level) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID is the request ID ctx carries, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// From is the default logger, with the request ID when ctx carries one.
+func From(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		return slog.Default()
+	}
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
@@ -2,38 +2,212 @@ package main
 
 import (
 	// "fmt"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/m/apperr"
+	"github.com/m/commands"
+	"github.com/m/config"
+	"github.com/m/controllers"
 	"github.com/m/database"
-	"github.com/m/models"
+	"github.com/m/logging"
+	"github.com/m/metrics"
+	"github.com/m/middleware"
 	"github.com/m/routes"
+	"github.com/m/services"
+	"github.com/m/utils"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.Setup(os.Stdout, cfg.Log.Level)
+
+	if len(os.Args) > 1 {
+		runCommand(cfg, os.Args[1:])
+		return
+	}
 
-	database.SetupDatabase()
+	database.SetupDatabase(cfg.Database)
+	if err := database.DB.Use(metrics.QueryTimer{SlowThreshold: cfg.Log.SlowQuery}); err != nil {
+		log.Fatalf("Could not install the query timer: %v", err)
+	}
+
+	if err := utils.LoadSigningKeys(cfg.JWT.ActiveKeyID, cfg.JWT.Keys); err != nil {
+		log.Fatalf("Could not load JWT signing keys: %v", err)
+	}
+	if cfg.Env == "production" {
+		if err := utils.CheckProductionKeys(); err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
+	}
 
-	if err := database.DB.AutoMigrate(&models.Supplier{}); err != nil {
-		log.Fatalf("Could not auto-migrate supplier table: %v", err)
+	if err := database.RequireMigrated(database.DB); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
 	}
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apperr.ErrorHandler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BodyLimit:    cfg.Server.BodyLimit,
+	})
 
+	// Request IDs come first so the access log and everything after carry them
+	app.Use(middleware.RequestID())
+	app.Use(middleware.AccessLog())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowOrigins:  cfg.CORSOrigins,
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, " + middleware.HeaderRequestID,
+		ExposeHeaders: middleware.HeaderRequestID,
 	}))
 
-	routes.UserRoutes(app)
+	h := controllers.NewHandler(database.DB, services.New(database.DB, services.SalesRules{
+		DiscountCategories:  cfg.Sales.DiscountCategories,
+		VATExemptCategories: cfg.Sales.VATExemptCategories,
+		ZeroRatedCategories: cfg.Sales.ZeroRatedCategories,
+	}))
+	h.Mailer = utils.NewMailer(cfg.SMTP)
+	h.FrontendURL = cfg.FrontendURL
+	routes.UserRoutes(app, cfg, h)
+
+	slog.Info("server starting", "port", cfg.Port)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen("0.0.0.0:" + cfg.Port) // Bind to 0.0.0.0
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-listenErr:
+		log.Fatal(err)
+	case sig := <-stop:
+		slog.Info("draining requests", "signal", sig.String(), "timeout", cfg.Server.ShutdownTimeout.String())
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8097"
+	// Fail readiness first, then let in-flight requests such as a POS
+	// checkout finish before the database connections close
+	controllers.BeginShutdown()
+	if err := app.ShutdownWithTimeout(cfg.Server.ShutdownTimeout); err != nil {
+		slog.Warn("shutdown did not finish cleanly", "error", err)
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		sqlDB.Close()
 	}
+	slog.Info("server stopped")
+}
 
-	log.Printf("Server starting on port %s...", port)
-	log.Fatal(app.Listen("0.0.0.0:" + port)) // Bind to 0.0.0.0
+// runCommand handles one-off maintenance commands, e.g. `go run . migrate up`,
+// `go run . hash-passwords`,
+// `go run . create-admin -username root_admin -email admin@example.com` or
+// `go run . seed -months 6 -until 2024-06-30`.
+func runCommand(cfg *config.Config, args []string) {
+	switch args[0] {
+	case "migrate", "hash-passwords", "create-admin", "seed":
+	default:
+		log.Fatalf("Unknown command %q", args[0])
+	}
+
+	database.SetupDatabase(cfg.Database)
+	if args[0] != "migrate" {
+		if err := database.RequireMigrated(database.DB); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch args[0] {
+	case "migrate":
+		runMigrate(args[1:])
+	case "hash-passwords":
+		if err := commands.HashPasswords(database.DB); err != nil {
+			log.Fatalf("Could not hash passwords: %v", err)
+		}
+	case "create-admin":
+		fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+		username := fs.String("username", "", "admin username")
+		email := fs.String("email", "", "admin email used to log in")
+		password := fs.String("password", os.Getenv("ADMIN_PASSWORD"), "admin password (defaults to $ADMIN_PASSWORD)")
+		fs.Parse(args[1:])
+
+		if err := commands.CreateAdmin(database.DB, *username, *email, *password); err != nil {
+			log.Fatalf("Could not create admin: %v", err)
+		}
+	case "seed":
+		opts := commands.DefaultSeedOptions()
+		fs := flag.NewFlagSet("seed", flag.ExitOnError)
+		fs.IntVar(&opts.Suppliers, "suppliers", opts.Suppliers, "number of supplier stores")
+		fs.IntVar(&opts.ProductsPerSupplier, "products", opts.ProductsPerSupplier, "products per supplier")
+		fs.IntVar(&opts.Months, "months", opts.Months, "months of sales history")
+		fs.IntVar(&opts.SalesPerDay, "daily", opts.SalesPerDay, "average POS transactions per day")
+		fs.IntVar(&opts.Orders, "orders", opts.Orders, "number of restock orders")
+		fs.Uint64Var(&opts.Seed, "seed", opts.Seed, "random seed; the same seed and -until give the same data")
+		until := fs.String("until", opts.Until.Format(time.DateOnly), "last day of sales history (YYYY-MM-DD)")
+		fs.StringVar(&opts.Password, "password", os.Getenv("SEED_PASSWORD"), "password for the seeded accounts (defaults to $SEED_PASSWORD; unusable when empty)")
+		fs.BoolVar(&opts.Allow, "allow", false, "seed without APP_ENV, provided the database holds nothing but seed data")
+		fs.Parse(args[1:])
+
+		day, err := time.ParseInLocation(time.DateOnly, *until, time.Local)
+		if err != nil {
+			log.Fatalf("seed: -until must be YYYY-MM-DD, got %q", *until)
+		}
+		opts.Until = day
+		if err := commands.Seed(database.DB, cfg.Env, opts); err != nil {
+			log.Fatalf("Could not seed: %v", err)
+		}
+	}
+}
+
+// runMigrate implements `migrate up`, `migrate down [steps]` and
+// `migrate status`.
+func runMigrate(args []string) {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		if err := database.MigrateUp(database.DB); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("migrate down: steps must be a positive number, got %q", args[1])
+			}
+			steps = n
+		}
+		if err := database.MigrateDown(database.DB, steps); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "status":
+		statuses, err := database.Status(database.DB)
+		if err != nil {
+			log.Fatalf("Could not read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate action %q (want up, down or status)", action)
+	}
 }
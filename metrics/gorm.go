@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/m/logging"
+	"gorm.io/gorm"
+)
+
+// QueryTimer is a GORM plugin that records every statement in QueryDuration
+// and logs statements slower than SlowThreshold as warnings, with the request
+// ID when the statement runs with a request's context. Logged SQL keeps its
+// placeholders, so bound values such as password hashes never reach the log.
+type QueryTimer struct {
+	SlowThreshold time.Duration // zero disables slow query logs
+}
+
+const startKey = "metrics:start"
+
+func (QueryTimer) Name() string { return "metrics:query_timer" }
+
+func (q QueryTimer) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", start),
+		cb.Create().After("gorm:create").Register("metrics:after_create", q.after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", start),
+		cb.Query().After("gorm:query").Register("metrics:after_query", q.after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", start),
+		cb.Update().After("gorm:update").Register("metrics:after_update", q.after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", start),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", q.after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", start),
+		cb.Row().After("gorm:row").Register("metrics:after_row", q.after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", start),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", q.after("raw")),
+	)
+}
+
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (q QueryTimer) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(v.(time.Time))
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		QueryDuration.WithLabelValues(operation, table).Observe(elapsed.Seconds())
+
+		if q.SlowThreshold > 0 && elapsed > q.SlowThreshold {
+			logging.From(db.Statement.Context).Warn("slow query",
+				"operation", operation,
+				"table", table,
+				"duration_ms", elapsed.Milliseconds(),
+				"rows", db.RowsAffected,
+				"sql", db.Statement.SQL.String(),
+			)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/m/logging"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestQueryTimerRecordsAndLogsSlowQueries(t *testing.T) {
+	previous := slog.Default()
+	var buf bytes.Buffer
+	logging.Setup(&buf, slog.LevelInfo)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	db := testutil.NewDB(t)
+	// Every statement counts as slow
+	if err := db.Use(QueryTimer{SlowThreshold: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := QueryDuration.WithLabelValues("query", "otop_products").(prometheus.Metric)
+	before := histogramCount(t, queries)
+
+	ctx := logging.WithRequestID(context.Background(), "req-9")
+	var products []models.OtopProducts
+	if err := db.WithContext(ctx).Where("name = ?", "secret value").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := histogramCount(t, queries); got != before+1 {
+		t.Errorf("observations = %d, want %d", got, before+1)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "slow query" || record["request_id"] != "req-9" || record["table"] != "otop_products" {
+		t.Errorf("record = %v", record)
+	}
+	if sql, _ := record["sql"].(string); strings.Contains(sql, "secret value") {
+		t.Errorf("bound value logged: %s", sql)
+	}
+}
+
+func histogramCount(t *testing.T, h prometheus.Metric) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
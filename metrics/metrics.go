@@ -0,0 +1,68 @@
+// Package metrics holds the Prometheus collectors the server exposes at
+// /metrics: request latency by route, database query timings and business
+// counters.
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector below plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time spent in database statements, by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	Checkouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "otop_checkouts_total",
+		Help: "POS checkouts, by outcome: completed or rejected.",
+	}, []string{"outcome"})
+
+	ItemsSold = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "otop_items_sold_total",
+		Help: "Units sold, by channel: pos or sold_items.",
+	}, []string{"channel"})
+
+	FailedLogins = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "otop_failed_logins_total",
+		Help: "Sign-in attempts rejected for a wrong email or password.",
+	})
+
+	Lockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "otop_login_lockouts_total",
+		Help: "Emails or addresses locked out after repeated failed sign-ins.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		QueryDuration,
+		Checkouts,
+		ItemsSold,
+		FailedLogins,
+		Lockouts,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
@@ -2,115 +2,81 @@ package middleware
 
 import (
 	// "os"÷\\\
-	"strings"
+	"errors"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/m/apperr"
+	"github.com/m/models"
 	"github.com/m/utils"
+	"gorm.io/gorm"
 )
 
-func JWTProtected(c *fiber.Ctx) error {
-	tokenString := c.Get("Authorization")
+var errSessionRevoked = errors.New("session revoked")
+
+// authenticate verifies the token signature and checks in db that the
+// session it belongs to has not been logged out or revoked.
+func authenticate(db *gorm.DB, tokenString string) (*jwt.Token, error) {
 	token, err := utils.ParseToken(tokenString)
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized Pleased Input Token"})
+	if err != nil {
+		return nil, err
 	}
-	c.Locals("user", token)
-	return c.Next()
-}
-func IsAdmin(c *fiber.Ctx) error {
-	// Check if token is already set in Locals
-	userToken := c.Locals("user")
-	if userToken == nil {
-		// Get the Authorization header
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-		}
-
-		// Remove "Bearer " prefix and parse the token
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		parsedToken, err := utils.ParseToken(tokenStr)
-		if err != nil || !parsedToken.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-		}
-
-		// Store the parsed token in Locals
-		c.Locals("user", parsedToken)
-		userToken = parsedToken
+	if !token.Valid {
+		return nil, errSessionRevoked
 	}
 
-	// Type assertion to retrieve claims
-	claims, ok := userToken.(*jwt.Token).Claims.(jwt.MapClaims)
+	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	// Check for the "admin" role in claims
-	if role, exists := claims["role"].(string); !exists || role != "admin" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
-	}
-
-	return c.Next()
-}
-func IsSupplier(c *fiber.Ctx) error {
-	// Get the Authorization header
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized access"})
-	}
-
-	// Remove "Bearer " prefix and parse the token
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	userToken, err := utils.ParseToken(tokenStr)
-	if err != nil || !userToken.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		return nil, errSessionRevoked
 	}
-
-	// Retrieve the claims from the token
-	claims, ok := userToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" {
+		return nil, errSessionRevoked
 	}
 
-	// Check if the role claim exists and is "supplier"
-	if role, exists := claims["role"].(string); !exists || role != "supplier" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
+	var session models.Session
+	if err := db.Select("id", "revoked_at", "expires_at").First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, errSessionRevoked
 	}
-
-	if supplierID, exists := claims["supplier_id"].(float64); exists {
-		// Store the supplier_id and token in Locals
-		c.Locals("supplier_id", uint(supplierID)) // Store supplier_id in Locals
-	} else {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Supplier ID not found in token"})
+	if !session.Active(time.Now()) {
+		return nil, errSessionRevoked
 	}
 
-	// Store token in Locals for further use
-	c.Locals("supplier", userToken)
-	return c.Next()
+	return token, nil
 }
-func IsCashier(c *fiber.Ctx) error {
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized access"})
-	}
 
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	userToken, err := utils.ParseToken(tokenStr)
-	if err != nil || !userToken.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+// Authorize authenticates the request and checks that the caller's role is
+// granted permission. The verified token is stored in Locals("user"); for
+// suppliers it is also stored in Locals("supplier") together with
+// Locals("supplier_id").
+func Authorize(db *gorm.DB, permission Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, err := authenticate(db, c.Get("Authorization"))
+		if err != nil {
+			return apperr.Unauthorized("Unauthorized Pleased Input Token")
+		}
 
-	claims, ok := userToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return apperr.Unauthorized("Unauthorized")
+		}
 
-	if role, exists := claims["role"].(string); !exists || role != "cashier" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
-	}
+		role, _ := claims["role"].(string)
+		if !HasPermission(role, permission) {
+			return apperr.Forbidden("Forbidden")
+		}
 
-	c.Locals("cashier", userToken)
+		c.Locals("user", token)
+		if role == "supplier" {
+			supplierID, exists := claims["supplier_id"].(float64)
+			if !exists {
+				return apperr.Unauthorized("Supplier ID not found in token")
+			}
+			c.Locals("supplier", token)
+			c.Locals("supplier_id", uint(supplierID))
+		}
 
-	return c.Next()
+		return c.Next()
+	}
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/m/apperr"
+	"github.com/m/logging"
+	"github.com/m/metrics"
+)
+
+// HeaderRequestID carries the request ID in both directions, so a proxy's
+// ID is kept and clients can quote it when reporting a problem.
+const HeaderRequestID = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID takes the caller's X-Request-ID when it looks like an ID, or
+// makes one, and echoes it on the response. The ID is stored in
+// Locals("request_id") and in the user context, where logging.From finds it.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			id = utils.UUIDv4()
+		}
+		c.Set(HeaderRequestID, id)
+		c.Locals("request_id", id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// quietRoutes are polled constantly, so they are logged at debug level.
+var quietRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// AccessLog logs one line per request and records its latency by route
+// pattern. Errors are handed to the app's error handler here, so the status
+// it chooses is the one logged.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+		elapsed := time.Since(start)
+		status := c.Response().StatusCode()
+
+		// Requests no route matched would otherwise all count as "/"
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			route = "unmatched"
+		}
+		metrics.RequestDuration.WithLabelValues(c.Method(), route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+		ctx := c.UserContext()
+		logging.From(ctx).Log(ctx, level, "request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"route", route,
+			"status", status,
+			"duration_ms", float64(elapsed.Microseconds())/1000,
+			"bytes", len(c.Response().Body()),
+			"ip", c.IP(),
+			"user_agent", c.Get(fiber.HeaderUserAgent),
+		)
+		return nil
+	}
+}
+
+// MetricsAuth guards /metrics with a shared bearer token when token is set.
+func MetricsAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Next()
+		}
+		got := []byte(c.Get(fiber.HeaderAuthorization))
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+			return apperr.Unauthorized("Invalid metrics token")
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/m/apperr"
+	"github.com/m/logging"
+	"github.com/m/metrics"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	previous := slog.Default()
+	var buf bytes.Buffer
+	logging.Setup(&buf, slog.LevelDebug)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func newObservedApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Use(RequestID())
+	app.Use(AccessLog())
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		logging.From(c.UserContext()).Info("looking up item")
+		return apperr.NotFound("Item not found")
+	})
+	return app
+}
+
+func TestRequestIDIsKeptOrMade(t *testing.T) {
+	captureLogs(t)
+	app := newObservedApp()
+
+	req := httptest.NewRequest(fiber.MethodGet, "/items/1", nil)
+	req.Header.Set(HeaderRequestID, "edge-42")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(HeaderRequestID); got != "edge-42" {
+		t.Errorf("kept ID = %q, want edge-42", got)
+	}
+
+	req = httptest.NewRequest(fiber.MethodGet, "/items/1", nil)
+	req.Header.Set(HeaderRequestID, "not an id\n")
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get(HeaderRequestID); got == "" || got == "not an id\n" {
+		t.Errorf("made ID = %q", got)
+	}
+}
+
+func TestAccessLogCarriesRequestIDAndRoute(t *testing.T) {
+	buf := captureLogs(t)
+	app := newObservedApp()
+
+	req := httptest.NewRequest(fiber.MethodGet, "/items/7", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	records := logRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("records = %v, want the handler's line and the access line", records)
+	}
+	for _, r := range records {
+		if r["request_id"] != "req-1" {
+			t.Errorf("record without the request ID: %v", r)
+		}
+	}
+	access := records[1]
+	if access["msg"] != "request" || access["route"] != "/items/:id" || access["status"] != float64(404) || access["level"] != "WARN" {
+		t.Errorf("access record = %v", access)
+	}
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, family := range families {
+		if family.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			found = found || (labels["route"] == "/items/:id" && labels["status"] == "404")
+		}
+	}
+	if !found {
+		t.Error("no latency sample for GET /items/:id 404")
+	}
+}
+
+func TestMetricsAuth(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: apperr.ErrorHandler})
+	app.Get("/metrics", MetricsAuth("s3cret"), func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	for name, tc := range map[string]struct {
+		header string
+		want   int
+	}{
+		"no token":    {"", fiber.StatusUnauthorized},
+		"wrong token": {"Bearer nope", fiber.StatusUnauthorized},
+		"token":       {"Bearer s3cret", fiber.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/metrics", nil)
+			if tc.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tc.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
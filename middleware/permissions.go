@@ -0,0 +1,114 @@
+package middleware
+
+import "sort"
+
+// Permission names one action a route performs. Routes declare the permission
+// they need and roles are granted permissions in rolePermissions.
+type Permission string
+
+const (
+	// Public marks a route that needs no token at all.
+	Public Permission = "public"
+
+	PermSession         Permission = "auth:session"
+	PermUsersManage     Permission = "users:manage"
+	PermSuppliersManage Permission = "suppliers:manage"
+	PermSupplierSelf    Permission = "supplier:self"
+	PermCatalogRead     Permission = "catalog:read"
+	PermCatalogManage   Permission = "catalog:manage"
+	PermInventoryRead   Permission = "inventory:read"
+	PermInventoryAdjust Permission = "inventory:adjust"
+	PermOrdersRead      Permission = "orders:read"
+	PermOrdersManage    Permission = "orders:manage"
+	PermOrdersApprove   Permission = "orders:approve"
+	PermPOSCheckout     Permission = "pos:checkout"
+	PermSalesRead       Permission = "sales:read"
+	PermReportsRead     Permission = "reports:read"
+	PermAuditRead       Permission = "audit:read"
+)
+
+// AllPermissions lists every permission a route may declare.
+var AllPermissions = []Permission{
+	PermSession,
+	PermUsersManage,
+	PermSuppliersManage,
+	PermSupplierSelf,
+	PermCatalogRead,
+	PermCatalogManage,
+	PermInventoryRead,
+	PermInventoryAdjust,
+	PermOrdersRead,
+	PermOrdersManage,
+	PermOrdersApprove,
+	PermPOSCheckout,
+	PermSalesRead,
+	PermReportsRead,
+	PermAuditRead,
+}
+
+var rolePermissions = map[string][]Permission{
+	"admin": {
+		PermSession,
+		PermUsersManage,
+		PermSuppliersManage,
+		PermCatalogRead,
+		PermInventoryRead,
+		PermInventoryAdjust,
+		PermOrdersRead,
+		PermOrdersManage,
+		PermOrdersApprove,
+		PermPOSCheckout,
+		PermSalesRead,
+		PermReportsRead,
+		PermAuditRead,
+	},
+	"cashier": {
+		PermSession,
+		PermCatalogRead,
+		PermInventoryRead,
+		PermPOSCheckout,
+		PermSalesRead,
+	},
+	"supplier": {
+		PermSession,
+		PermSupplierSelf,
+		PermCatalogManage,
+		PermOrdersApprove,
+	},
+}
+
+// HasPermission reports whether role is granted permission.
+func HasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnownPermission reports whether permission is Public or listed in
+// AllPermissions.
+func IsKnownPermission(permission Permission) bool {
+	if permission == Public {
+		return true
+	}
+	for _, known := range AllPermissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesWith lists the roles granted permission, in alphabetical order.
+func RolesWith(permission Permission) []string {
+	var roles []string
+	for role := range rolePermissions {
+		if HasPermission(role, permission) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
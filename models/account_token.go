@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposePasswordReset      = "password_reset"
+	TokenPurposeSupplierActivation = "supplier_activation"
+)
+
+// AccountToken is a single-use, expiring token emailed to a user or supplier.
+// Only the SHA-256 hash of the token is stored.
+type AccountToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Purpose     string     `gorm:"index" json:"purpose"`
+	SubjectType string     `json:"subject_type"`
+	SubjectID   uint       `json:"subject_id"`
+	TokenHash   string     `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
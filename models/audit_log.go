@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog records one create, update or delete. Before, After and Changes
+// hold JSON documents.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ActorType string    `gorm:"index:idx_audit_logs_actor" json:"actor_type"`
+	ActorID   uint      `gorm:"index:idx_audit_logs_actor" json:"actor_id"`
+	ActorName string    `json:"actor_name"`
+	ActorRole string    `json:"actor_role"`
+	Action    string    `gorm:"index" json:"action"`
+	Entity    string    `gorm:"index:idx_audit_logs_entity" json:"entity"`
+	EntityID  string    `gorm:"index:idx_audit_logs_entity" json:"entity_id"`
+	Before    string    `gorm:"type:jsonb" json:"before"`
+	After     string    `gorm:"type:jsonb" json:"after"`
+	Changes   string    `gorm:"type:jsonb" json:"changes"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Custom JSON Marshal so the stored documents are embedded as JSON, not strings
+func (a AuditLog) MarshalJSON() ([]byte, error) {
+	type Alias AuditLog // Create an alias to avoid recursion
+	return json.Marshal(&struct {
+		Before  json.RawMessage `json:"before"`
+		After   json.RawMessage `json:"after"`
+		Changes json.RawMessage `json:"changes"`
+		Alias
+	}{
+		Before:  rawJSON(a.Before),
+		After:   rawJSON(a.After),
+		Changes: rawJSON(a.Changes),
+		Alias:   (Alias)(a),
+	})
+}
+
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s)
+}
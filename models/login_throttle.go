@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one key: an account email
+// ("email:<address>") or a client address ("ip:<address>").
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey;size:320" json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// LockoutEvent is recorded each time a key gets locked so admins can review
+// and lift lockouts.
+type LockoutEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Key         string     `gorm:"index" json:"key"`
+	Email       string     `json:"email"`
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at"`
+	UnlockedBy  uint       `json:"unlocked_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
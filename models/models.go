@@ -0,0 +1,24 @@
+package models
+
+// All returns every model the application stores, for building a test
+// database and checking it against the migrations.
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Supplier{},
+		&Product{},
+		&OtopProducts{},
+		&SoldItems{},
+		&Order{},
+		&Shift{},
+		&Transaction{},
+		&TransactionItem{},
+		&TransactionSupplier{},
+		&Payment{},
+		&Session{},
+		&AccountToken{},
+		&LoginThrottle{},
+		&LockoutEvent{},
+		&AuditLog{},
+	}
+}
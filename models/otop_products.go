@@ -19,8 +19,23 @@ type OtopProducts struct {
 	Supplier         Supplier `gorm:"foreignKey:SupplierID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"supplier"`
 	StoreName        string   `json:"store_name"`
 	SequentialNumber string   `json:"sequential_number"`
+
+	// VATClass overrides the VAT treatment of the product's category unless
+	// it is VATByCategory
+	VATClass string `json:"vat_class" gorm:"column:vat_class;not null;default:category"`
 }
 
+// VAT classes. Shelf prices of vatable goods include 12% VAT; exempt and
+// zero-rated goods carry none, but zero-rated sales are reported apart.
+// VATByCategory is only ever set on products.
+const (
+	VATVatable   = "vatable"
+	VATExempt    = "exempt"
+	VATZeroRated = "zero_rated"
+
+	VATByCategory = "category"
+)
+
 func (p *OtopProducts) BeforeCreate(tx *gorm.DB) (err error) {
 	// Validate the category field
 	if p.Category != "Food" && p.Category != "Non-Food" {
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	SubjectUser     = "user"
+	SubjectSupplier = "supplier"
+)
+
+// Session is one signed-in device. Access tokens carry the session ID in their
+// "sid" claim, so revoking the row invalidates them immediately.
+type Session struct {
+	ID                string     `gorm:"primaryKey;size:64" json:"id"`
+	SubjectType       string     `gorm:"index:idx_sessions_subject" json:"subject_type"`
+	SubjectID         uint       `gorm:"index:idx_sessions_subject" json:"subject_id"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"` // last rotated-out refresh token, used to detect reuse
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	ShiftOpen   = "open"
+	ShiftClosed = "closed"
+)
+
+// Shift is one cashier's session at a till, from the opening float to the
+// closing cash count. Every POS transaction rung up meanwhile links to it.
+type Shift struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	CashierID        uint       `gorm:"index;uniqueIndex:idx_shifts_open_cashier,where:status = 'open'" json:"cashier_id"`
+	Cashier          User       `gorm:"foreignKey:CashierID" json:"cashier"`
+	Status           string     `gorm:"index" json:"status"`
+	OpenedAt         time.Time  `json:"opened_at"`
+	ClosedAt         *time.Time `json:"closed_at"`
+	OpeningCash      float64    `json:"opening_cash"`
+	CashSales        float64    `json:"cash_sales"`
+	ExpectedCash     float64    `json:"expected_cash"`
+	CountedCash      float64    `json:"counted_cash"`
+	Variance         float64    `json:"variance"` // counted minus expected; negative means cash is short
+	TransactionCount int64      `json:"transaction_count"`
+	Notes            string     `json:"notes"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	// Takings per tender type net of change, e.g. {"cash": 1250, "gcash": 300}.
+	// Worked out from the shift's payments whenever a shift is read.
+	Tenders map[string]float64 `gorm:"-" json:"tenders"`
+}
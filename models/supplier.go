@@ -2,6 +2,11 @@ package models
 
 import "gorm.io/gorm"
 
+const (
+	SupplierPending = "pending" // invited, waiting for the supplier to set a password
+	SupplierActive  = "active"
+)
+
 type Supplier struct {
 	gorm.Model
 	ID           uint           `json:"id" gorm:"primary_key"`
@@ -9,8 +14,9 @@ type Supplier struct {
 	Email        string         `json:"email"`
 	PhoneNumber  string         `json:"phone_number"`
 	Address      string         `json:"address"`
-	Password     string         `json:"password"`
+	Password     string         `json:"-"`
 	Role         string         `gorm:"default:'supplier'"`
+	Status       string         `json:"status" gorm:"default:'active'"`
 	Products     []Product      `gorm:"foreignKey:SupplierID"`
 	Purchased    int            `gorm:"default:0"`
 	OtopProducts []OtopProducts `gorm:"foreignKey:SupplierID"`
@@ -8,13 +8,43 @@ import (
 
 type Transaction struct {
 	gorm.Model
+	Subtotal         float64           `json:"subtotal"`                                          // Sum of the lines at shelf prices
+	Discount         float64           `json:"discount"`                                          // Taken off the subtotal
+	VatableSales     float64           `json:"vatable_sales"`                                     // Sales of vatable goods, net of VAT
+	VAT              float64           `json:"vat" gorm:"column:vat"`                             // VAT included in Total
+	VATExemptSales   float64           `json:"vat_exempt_sales" gorm:"column:vat_exempt_sales"`   // Exempt goods and senior/PWD sales, before discount
+	ZeroRatedSales   float64           `json:"zero_rated_sales"`                                  // Sales taxed at 0%
 	Total            float64           `json:"total"`                                             // Total cost of the transaction
 	Received         float64           `json:"received"`                                          // Amount received from the customer
 	Change           float64           `json:"change"`                                            // Change returned to the customer
 	SupplierID       uint              `json:"supplier_id"`                                       // Foreign key linking to the supplier
+	ShiftID          *uint             `json:"shift_id" gorm:"index"`                             // Cashier shift the sale was rung up in
+	CashierID        uint              `json:"cashier_id" gorm:"index"`                           // User who rang up the sale
 	Supplier         Supplier          `json:"supplier"`                                          // Relation to Supplier
 	TransactionItems []TransactionItem `json:"transaction_items" gorm:"foreignKey:TransactionID"` // Relation to transaction items
+	Payments         []Payment         `json:"payments" gorm:"foreignKey:TransactionID"`          // How the customer paid
 	CreatedAt        time.Time         `json:"created_at"`                                        // Transaction date
+
+	// Senior citizen or PWD discount; Type is empty when none was given
+	Statutory StatutoryDiscount `json:"statutory_discount" gorm:"embedded;embeddedPrefix:statutory_"`
+}
+
+// Statutory discount types.
+const (
+	DiscountSenior = "senior"
+	DiscountPWD    = "pwd"
+)
+
+// StatutoryDiscount records a senior citizen or PWD discount for the
+// discount register: whose ID it was given on, the shelf value of the items
+// it covered and the VAT those items were exempted from. The 20% itself is
+// Transaction.Discount.
+type StatutoryDiscount struct {
+	Type          string  `json:"type"`
+	IDNumber      string  `json:"id_number"`
+	Name          string  `json:"name"`
+	EligibleSales float64 `json:"eligible_sales"`
+	VATExemption  float64 `json:"vat_exemption" gorm:"column:vat_exemption"`
 }
 
 type TransactionItem struct {
@@ -25,10 +55,43 @@ type TransactionItem struct {
 	Price         float64   `json:"price"`
 	Total         float64   `json:"total"`
 	SupplierID    uint      `json:"supplier_id"` // Add SupplierID to associate with each product
+	VATClass      string    `json:"vat_class" gorm:"column:vat_class"`
+	VAT           float64   `json:"vat" gorm:"column:vat"`                     // VAT included in Total
+	VATExemption  float64   `json:"vat_exemption" gorm:"column:vat_exemption"` // senior citizen and PWD lines only
+	Discount      float64   `json:"discount"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// Tender types a customer can pay with.
+const (
+	TenderCash  = "cash"
+	TenderGCash = "gcash"
+	TenderMaya  = "maya"
+	TenderCard  = "card"
+)
+
+// Tenders lists every tender type, in the order reports show them.
+var Tenders = []string{TenderCash, TenderGCash, TenderMaya, TenderCard}
+
+// Payment is one tender of a transaction. A sale paid partly by GCash and
+// partly in cash has two. Only cash is ever given change, so Amount less
+// Change is what the tender actually took.
+type Payment struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"index" json:"transaction_id"`
+	Method        string    `gorm:"index" json:"method"`
+	Amount        float64   `json:"amount"`
+	Change        float64   `json:"change"`
+	Reference     string    `json:"reference"` // wallet or card approval number; empty for cash
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName keeps payments next to the other transaction tables.
+func (Payment) TableName() string {
+	return "transaction_payments"
+}
+
 type TransactionSupplier struct {
 	ID            uint `gorm:"primaryKey"`
 	TransactionID uint
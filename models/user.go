@@ -7,6 +7,7 @@ type User struct {
 	ID       uint   `json:"id" gorm:"primary_key"`
 	UserName string `json:"username"`
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"-"`
 	Role     string `json:"role"`
+	Disabled bool   `json:"disabled" gorm:"default:false"` // disabled users cannot log in
 }
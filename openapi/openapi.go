@@ -0,0 +1,327 @@
+// Package openapi holds the subset of the OpenAPI 3 document model the server
+// publishes, and builds JSON schemas from Go types by reflection so request
+// and response schemas follow the structs the handlers actually use.
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	// Permission is the route's authorization requirement; see middleware.
+	Permission string `json:"x-permission,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // "path" or "query"
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// JSON is the content map for a JSON body with the given schema.
+func JSON(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Generator turns Go values into schemas. Named structs become shared
+// component schemas referenced with $ref.
+type Generator struct {
+	Schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{Schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema for the type of v, or nil when v is nil.
+func (g *Generator) SchemaOf(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "" && isNullTime(t):
+		// gorm.DeletedAt and sql.NullTime marshal as a time or null
+		s = &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		s = &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	case t.Kind() == reflect.Struct:
+		s = g.object(t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s = &Schema{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s.Format = "int64"
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number"}
+	default:
+		// interface{} and friends: any JSON value
+		s = &Schema{}
+	}
+
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func isNullTime(t reflect.Type) bool {
+	field, ok := t.FieldByName("Time")
+	_, marshals := reflect.PtrTo(t).MethodByName("MarshalJSON")
+	return ok && field.Type == timeType && t.NumField() == 2 && marshals
+}
+
+// component registers a named struct once and returns its component name.
+// Structs from different packages that share a name are told apart by
+// package.
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := typeName(t)
+	if _, taken := g.Schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.Schemas[name] = nil // reserve the name before recursing
+	g.Schemas[name] = g.object(t)
+	return name
+}
+
+// typeName is t's name, with instantiated generics named after their type
+// arguments: listing.Page[models.Order] becomes OrderPage.
+func typeName(t reflect.Type) string {
+	base, args, generic := strings.Cut(t.Name(), "[")
+	if !generic {
+		return base
+	}
+	var name strings.Builder
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		arg = strings.TrimLeft(arg, "*[]")
+		arg = arg[strings.LastIndex(arg, ".")+1:]
+		name.WriteString(strings.ToUpper(arg[:1]) + arg[1:])
+	}
+	return name.String() + base
+}
+
+type property struct {
+	name     string
+	depth    int
+	schema   *Schema
+	required bool
+}
+
+// object follows encoding/json: exported fields by their json names, fields
+// of untagged embedded structs promoted, shallower fields winning.
+func (g *Generator) object(t reflect.Type) *Schema {
+	var props []property
+	g.collect(t, 0, &props)
+
+	winners := map[string]property{}
+	var order []string
+	for _, p := range props {
+		if w, seen := winners[p.name]; seen {
+			if w.depth <= p.depth {
+				continue
+			}
+		} else {
+			order = append(order, p.name)
+		}
+		winners[p.name] = p
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, name := range order {
+		p := winners[name]
+		s.Properties[name] = p.schema
+		if p.required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+func (g *Generator) collect(t reflect.Type, depth int, props *[]property) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			g.collect(embedded, depth+1, props)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.schema(field.Type)
+		if opts == "string" && schema.Type != "" {
+			schema = &Schema{Type: "string"}
+		}
+		required := applyValidation(schema, field.Tag.Get("validate"))
+		*props = append(*props, property{name: name, depth: depth, schema: schema, required: required})
+	}
+}
+
+// applyValidation copies the rules in a validate tag that OpenAPI can express
+// onto schema, and reports whether the field is required. Rules after dive
+// apply to slice elements.
+func applyValidation(schema *Schema, tag string) bool {
+	required := false
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = required || target == schema
+		case "dive":
+			if schema.Items == nil {
+				return required
+			}
+			// Shared component schemas carry their own rules
+			if schema.Items.Ref != "" {
+				return required
+			}
+			target = schema.Items
+		case "email":
+			target.Format = "email"
+		case "ip":
+			target.Format = "ip"
+		case "datetime":
+			if param == "2006-01-02" {
+				target.Format = "date"
+			}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "gt", "gte":
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				target.Minimum = &n
+				target.ExclusiveMinimum = name == "gt"
+			}
+		case "min":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch target.Type {
+			case "string":
+				target.MinLength = &n
+			case "array":
+				target.MinItems = &n
+			default:
+				f := float64(n)
+				target.Minimum = &f
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+type line struct {
+	Quantity int64 `json:"quantity" validate:"gt=0"`
+}
+
+type order struct {
+	base
+	ID     uint    `json:"id"`
+	Email  string  `json:"email" validate:"required,email"`
+	Status string  `json:"status" validate:"omitempty,oneof=open closed"`
+	Lines  []line  `json:"lines" validate:"required,min=1,dive"`
+	Note   *string `json:"note"`
+	Secret string  `json:"-"`
+}
+
+func TestSchemaFollowsJSONAndValidateTags(t *testing.T) {
+	g := NewGenerator()
+	ref := g.SchemaOf(order{})
+	if ref.Ref != "#/components/schemas/order" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	s := g.Schemas["order"]
+
+	for _, name := range []string{"ID", "CreatedAt", "id", "email", "status", "lines", "note"} {
+		if s.Properties[name] == nil {
+			t.Errorf("missing property %s", name)
+		}
+	}
+	if s.Properties["Secret"] != nil || s.Properties["-"] != nil {
+		t.Error(`json:"-" field was documented`)
+	}
+	if got := s.Properties["CreatedAt"].Format; got != "date-time" {
+		t.Errorf("CreatedAt format = %q", got)
+	}
+	if got := s.Properties["email"].Format; got != "email" {
+		t.Errorf("email format = %q", got)
+	}
+	if got := s.Properties["status"].Enum; len(got) != 2 {
+		t.Errorf("status enum = %v", got)
+	}
+	if lines := s.Properties["lines"]; lines.MinItems == nil || *lines.MinItems != 1 {
+		t.Errorf("lines = %+v, want minItems 1", lines)
+	}
+	if !s.Properties["note"].Nullable {
+		t.Error("pointer field should be nullable")
+	}
+	if len(s.Required) != 2 || s.Required[0] != "email" || s.Required[1] != "lines" {
+		t.Errorf("required = %v", s.Required)
+	}
+
+	quantity := g.Schemas["line"].Properties["quantity"]
+	if quantity.Minimum == nil || *quantity.Minimum != 0 || !quantity.ExclusiveMinimum {
+		t.Errorf("quantity = %+v, want exclusive minimum 0", quantity)
+	}
+}
+
+type page[T any] struct {
+	Data []T `json:"data"`
+}
+
+func TestGenericComponentsAreNamedAfterTheirArguments(t *testing.T) {
+	g := NewGenerator()
+	ref := g.SchemaOf(page[line]{})
+	if ref.Ref != "#/components/schemas/Linepage" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	items := g.Schemas["Linepage"].Properties["data"].Items
+	if items == nil || items.Ref != "#/components/schemas/line" {
+		t.Errorf("data items = %+v", items)
+	}
+}
@@ -0,0 +1,462 @@
+package routes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/m/apperr"
+	"github.com/m/controllers"
+	"github.com/m/listing"
+	middleware "github.com/m/middleware"
+	"github.com/m/models"
+	"github.com/m/openapi"
+	"github.com/m/services"
+)
+
+// routeDoc describes one route for the OpenAPI document. Request and Response
+// are zero values of the body types; their schemas are generated from the
+// struct and validate tags. routes_test.go fails if a route has no entry.
+type routeDoc struct {
+	Summary  string
+	Request  interface{}
+	Response interface{}
+	Status   int // success status, 200 when zero
+	Query    []queryParam
+
+	// List marks a collection route; its paging, sort and filter parameters
+	// come from the spec.
+	List *listing.Spec
+
+	// ContentType overrides application/json for non-JSON responses.
+	ContentType string
+}
+
+type queryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+func query(name, typ, description string) queryParam {
+	return queryParam{Name: name, Type: typ, Description: description}
+}
+
+// listQuery is the query parameters listing.Parse reads for spec.
+func listQuery(spec listing.Spec) []queryParam {
+	sorts := make([]string, 0, len(spec.Sorts))
+	for key := range spec.Sorts {
+		sorts = append(sorts, key)
+	}
+	sort.Strings(sorts)
+
+	params := []queryParam{
+		query("limit", "integer", fmt.Sprintf("page size, default %d, at most %d", listing.DefaultLimit, listing.MaxLimit)),
+		query("offset", "integer", "rows to skip"),
+		query("cursor", "string", "meta.next_cursor of the previous page; not with offset"),
+		query("sort", "string", fmt.Sprintf("one of %s, prefixed with - for descending; default %s", strings.Join(sorts, ", "), spec.DefaultSort)),
+	}
+	if spec.Search != "" {
+		params = append(params, query("search", "string", "substring of "+spec.Search))
+	}
+	if spec.DateColumn != "" {
+		params = append(params,
+			query("from", "date", "first day of "+spec.DateColumn+", YYYY-MM-DD"),
+			query("to", "date", "last day of "+spec.DateColumn+" (inclusive), YYYY-MM-DD"))
+	}
+
+	filters := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		filters = append(filters, name)
+	}
+	sort.Strings(filters)
+	for _, name := range filters {
+		typ := "string"
+		switch spec.Filters[name].Kind {
+		case listing.Int:
+			typ = "integer"
+		case listing.Bool:
+			typ = "boolean"
+		}
+		params = append(params, query(name, typ, ""))
+	}
+	return params
+}
+
+// Bodies that handlers build with fiber.Map, described here for the document.
+type (
+	messageResponse struct {
+		Message string `json:"message"`
+	}
+	livenessResponse struct {
+		Status string `json:"status"`
+	}
+	readinessResponse struct {
+		Status string                 `json:"status"`
+		Checks map[string]interface{} `json:"checks"`
+	}
+	jwksResponse struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	tokenResponse struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	loginResponse struct {
+		tokenResponse
+		Role string `json:"role"`
+
+		// Supplier accounts only
+		ID          uint   `json:"id,omitempty"`
+		SupplierID  uint   `json:"supplier_id,omitempty"`
+		StoreName   string `json:"store_name,omitempty"`
+		PhoneNumber string `json:"phone_number,omitempty"`
+		Address     string `json:"addres,omitempty"`
+	}
+	supplierCreatedResponse struct {
+		Message  string          `json:"message"`
+		Supplier models.Supplier `json:"supplier"`
+	}
+	supplierPurchasesResponse struct {
+		SupplierID uint `json:"supplier_id"`
+		Purchased  int  `json:"purchased"`
+	}
+	supplierTotalPurchasedResponse struct {
+		SupplierID     string  `json:"supplier_id"`
+		TotalPurchased float64 `json:"total_purchased"`
+	}
+	totalQuantityResponse struct {
+		TotalQuantity int64 `json:"total_quantity"`
+	}
+	totalProductsResponse struct {
+		TotalProducts int64 `json:"total_products"`
+	}
+	totalSuppliersResponse struct {
+		TotalSuppliers int64 `json:"total_suppliers"`
+	}
+	topSoldResponse struct {
+		TopSoldProducts []services.ProductSales `json:"top_sold_products"`
+	}
+	soldItemRecorded struct {
+		SoldItem models.SoldItems `json:"soldItem"`
+		Supplier models.Supplier  `json:"supplier"`
+	}
+	soldItemsResponse struct {
+		Data              []models.SoldItems `json:"data"`
+		Meta              listing.Meta       `json:"meta"`
+		OverallAmountSold float64            `json:"overall_amount_sold"`
+	}
+	receiptResponse struct {
+		TransactionID uint                     `json:"transaction_id"`
+		ShiftID       *uint                    `json:"shift_id"`
+		CashierID     uint                     `json:"cashier_id"`
+		Date          string                   `json:"date"`
+		Items         []models.TransactionItem `json:"items"`
+		Subtotal      float64                  `json:"subtotal"`
+		Statutory     models.StatutoryDiscount `json:"statutory_discount"`
+		Discount      float64                  `json:"discount"`
+		VatableSales  float64                  `json:"vatable_sales"`
+		VAT           float64                  `json:"vat"`
+		VATExempt     float64                  `json:"vat_exempt_sales"`
+		ZeroRated     float64                  `json:"zero_rated_sales"`
+		Total         float64                  `json:"total"`
+		Received      float64                  `json:"received"`
+		Change        float64                  `json:"change"`
+		Payments      []models.Payment         `json:"payments"`
+	}
+	errorResponse struct {
+		Code   apperr.Code         `json:"code"`
+		Error  string              `json:"error"`
+		Fields []apperr.FieldError `json:"fields,omitempty"`
+	}
+)
+
+var routeDocs = map[string]routeDoc{
+	"GET /healthz":                 {Summary: "Liveness probe", Response: livenessResponse{}},
+	"GET /readyz":                  {Summary: "Readiness probe: database reachable and schema up to date; 503 otherwise", Response: readinessResponse{}},
+	"GET /metrics":                 {Summary: "Prometheus metrics; needs METRICS_TOKEN as a bearer token when it is set", ContentType: "text/plain; version=0.0.4"},
+	"GET /.well-known/jwks.json":   {Summary: "Public keys for verifying access tokens", Response: jwksResponse{}},
+	"GET /api/docs":                {Summary: "Interactive API reference", ContentType: fiber.MIMETextHTMLCharsetUTF8},
+	"GET /api/docs/openapi.json":   {Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+	"POST /api/login":              {Summary: "Sign in as a user or supplier", Request: controllers.LoginRequest{}, Response: loginResponse{}},
+	"POST /api/refresh":            {Summary: "Exchange a refresh token for a new token pair", Request: controllers.RefreshRequest{}, Response: tokenResponse{}},
+	"POST /api/password/forgot":    {Summary: "Email a password reset link", Request: controllers.ForgotPasswordRequest{}, Response: messageResponse{}},
+	"POST /api/password/reset":     {Summary: "Set a new password with a reset token", Request: controllers.SetPasswordRequest{}, Response: messageResponse{}},
+	"POST /api/suppliers/activate": {Summary: "Activate an invited supplier account", Request: controllers.SetPasswordRequest{}, Response: messageResponse{}},
+	"POST /api/logout":             {Summary: "End the current session", Response: messageResponse{}},
+	"POST /api/logout_all":         {Summary: "End every session of the caller", Response: messageResponse{}},
+	"PUT /api/updateItem":          {Summary: "Update all products of a supplier's store", Request: controllers.UpdateStoreProductsRequest{}, Response: messageResponse{}},
+
+	"GET /supplier/purchases":         {Summary: "The signed-in supplier's purchase count", Response: supplierPurchasesResponse{}},
+	"POST /supplier":                  {Summary: "Create a pending supplier and email an invitation", Request: controllers.CreateSupplierRequest{}, Response: supplierCreatedResponse{}, Status: fiber.StatusCreated},
+	"GET /supplier":                   {Summary: "List suppliers", Response: listing.Page[models.Supplier]{}, List: &services.SupplierListing},
+	"GET /supplier/:storeName":        {Summary: "Get a supplier by store name", Response: models.Supplier{}},
+	"PUT /supplier/:storeName":        {Summary: "Update a supplier; the parameter holds the supplier ID", Request: controllers.UpdateSupplierRequest{}, Response: models.Supplier{}},
+	"DELETE /supplier/:storeName":     {Summary: "Delete a supplier; the parameter holds the supplier ID", Response: messageResponse{}},
+	"POST /supplier/:id/invitation":   {Summary: "Resend a supplier's activation email", Response: messageResponse{}},
+	"DELETE /supplier/:id/invitation": {Summary: "Revoke a supplier's outstanding activation link", Response: messageResponse{}},
+	"GET /api/users":                  {Summary: "List staff accounts", Response: listing.Page[models.User]{}, List: &services.UserListing},
+	"POST /api/users":                 {Summary: "Create a staff account", Request: controllers.CreateUserRequest{}, Response: models.User{}, Status: fiber.StatusCreated},
+	"GET /api/users/:id":              {Summary: "Get a staff account", Response: models.User{}},
+	"PATCH /api/users/:id":            {Summary: "Update a staff account", Request: controllers.UpdateUserRequest{}, Response: models.User{}},
+	"POST /api/users/:id/disable":     {Summary: "Disable a staff account and end its sessions", Response: models.User{}},
+	"POST /api/users/:id/enable":      {Summary: "Re-enable a staff account", Response: models.User{}},
+	"DELETE /api/users/:id":           {Summary: "Delete a staff account", Response: messageResponse{}},
+	"POST /api/sessions/revoke":       {Summary: "Sign a user or supplier out everywhere", Request: controllers.RevokeSessionsRequest{}, Response: messageResponse{}},
+	"GET /api/admin/lockouts":         {Summary: "List login lockouts, newest first", Response: listing.Page[models.LockoutEvent]{}, Query: []queryParam{query("active", "boolean", "only lockouts still in force")}, List: &controllers.LockoutListing},
+	"POST /api/admin/lockouts/unlock": {Summary: "Lift a login lockout for an email or IP", Request: controllers.UnlockLoginRequest{}, Response: messageResponse{}},
+	"GET /api/admin/audit":            {Summary: "Search the audit log, newest first", Response: listing.Page[models.AuditLog]{}, List: &controllers.AuditListing},
+
+	"POST /products":                    {Summary: "Add a product to the signed-in supplier's catalog", Request: controllers.ProductRequest{}, Response: models.Product{}, Status: fiber.StatusCreated},
+	"GET /products":                     {Summary: "List catalog products", Response: listing.Page[models.Product]{}, List: &services.ProductListing},
+	"GET /products/:supplier_id":        {Summary: "List a supplier's own catalog products", Response: listing.Page[models.Product]{}, List: &services.ProductListing},
+	"PUT /products/:id":                 {Summary: "Update one of the supplier's catalog products", Request: controllers.UpdateProductRequest{}, Response: models.Product{}},
+	"DELETE /products/:id":              {Summary: "Delete one of the supplier's catalog products", Response: messageResponse{}},
+	"POST /products/confirm/:id":        {Summary: "Supplier confirms an order and ships the stock", Response: models.Order{}},
+	"GET /products/orders/:supplier_id": {Summary: "List a supplier's orders", Response: listing.Page[models.Order]{}, List: &services.OrderListing},
+	"PUT /orders/:id/confirm":           {Summary: "The order's supplier marks a pending order verified", Response: models.Order{}},
+	"GET /orders/:supplier_id":          {Summary: "List a supplier's orders", Response: listing.Page[models.Order]{}, List: &services.OrderListing},
+	"GET /suppliers/all_purchases":      {Summary: "Top six suppliers by purchases", Response: []services.SupplierPurchaseCount{}},
+	"GET /suppliers/all_purchase":       {Summary: "Purchases for every supplier", Response: []services.SupplierPurchaseCount{}},
+	"GET /suppliers/purchases/:id":      {Summary: "Purchases for one supplier", Response: services.SupplierPurchaseCount{}},
+
+	"POST /api/otop/add_products":                                    {Summary: "Add a product to a store's inventory", Request: controllers.CreateOtopProductRequest{}, Response: models.OtopProducts{}, Status: fiber.StatusCreated},
+	"GET /api/otop/products":                                         {Summary: "List inventory products with their supplier", Response: listing.Page[models.OtopProducts]{}, List: &services.OtopProductListing},
+	"DELETE /api/otop/:id":                                           {Summary: "Delete an inventory product", Response: messageResponse{}},
+	"PUT /api/otop/:id":                                              {Summary: "Update an inventory product", Request: controllers.UpdateOtopProductRequest{}, Response: models.OtopProducts{}},
+	"GET /api/otop/total_quantity":                                   {Summary: "Total units in stock", Response: totalQuantityResponse{}},
+	"GET /api/otop/total_quantity_name":                              {Summary: "Units in stock per product name", Response: []services.NameQuantity{}},
+	"GET /api/otop/total_products":                                   {Summary: "Number of distinct products", Response: totalProductsResponse{}},
+	"GET /api/otop/total_categories":                                 {Summary: "Number of products per category", Response: map[string]int64{}},
+	"GET /api/otop/total_suppliers":                                  {Summary: "Number of suppliers", Response: totalSuppliersResponse{}},
+	"GET /api/otop/total_suppliers_product":                          {Summary: "Number of products per supplier", Response: []services.SupplierProductCount{}},
+	"GET /api/otop/total_amount_suppliers/:supplier_id/total_amount": {Summary: "Total amount purchased from a supplier", Response: supplierTotalPurchasedResponse{}},
+	"POST /api/otop/sold_items":                                      {Summary: "Record sold items and take them off stock", Request: []controllers.SoldItemRequest{}, Response: []soldItemRecorded{}, Status: fiber.StatusCreated},
+	"GET /api/otop/solds_products":                                   {Summary: "Sold items with the overall amount of all that match", Response: soldItemsResponse{}, List: &services.SoldItemListing},
+	"GET /api/otop/solds_products/:supplier_id":                      {Summary: "Sold items of one supplier", Response: listing.Page[models.SoldItems]{}, List: &services.SoldItemListing},
+	"POST /api/otop/add_cart":                                        {Summary: "Check that a product can be added to the cart", Request: controllers.AddToCartRequest{}, Response: messageResponse{}},
+	"GET /api/otop/most_solds":                                       {Summary: "Best-selling products", Response: topSoldResponse{}},
+	"POST /api/otop/POS":                                             {Summary: "Check out a cart in the caller's open shift", Request: controllers.CheckoutRequest{}, Response: receiptResponse{}},
+	"POST /api/otop/getSummary":                                      {Summary: "Sales totals per period", Request: controllers.SummaryRequest{}, Response: map[string]float64{}},
+	"POST /api/otop/supplierSummary":                                 {Summary: "A supplier's sales totals per period", Request: controllers.SupplierSalesRequest{}, Response: map[string]float64{}},
+	"POST /api/otop/tenderSummary":                                   {Summary: "POS takings per period by tender type, net of change", Request: controllers.SummaryRequest{}, Response: map[string]map[string]float64{}},
+	"POST /api/otop/vatSummary":                                      {Summary: "POS sales per period split into vatable, VAT, exempt and zero-rated", Request: controllers.SummaryRequest{}, Response: map[string]services.VATBreakdown{}},
+	"POST /api/otop/supplierVatSummary":                              {Summary: "A supplier's lines in POS sales per period, split like vatSummary", Request: controllers.SupplierSalesRequest{}, Response: map[string]services.VATBreakdown{}},
+	"GET /api/reports/discounts":                                     {Summary: "Monthly senior citizen and PWD discount register", Response: services.DiscountRegister{}, Query: []queryParam{query("month", "string", "month as YYYY-MM, default this month"), query("type", "string", "senior or pwd, default both")}},
+	"POST /api/otop/getByDate":                                       {Summary: "Sold items between two dates", Request: controllers.DateRange{}, Response: soldItemsResponse{}, List: &services.SoldItemListing},
+
+	"POST /order":       {Summary: "Order stock of a catalog product from its supplier", Request: controllers.CreateOrderRequest{}, Response: models.Order{}, Status: fiber.StatusCreated},
+	"GET /order":        {Summary: "List orders", Response: listing.Page[models.Order]{}, List: &services.OrderListing},
+	"GET /order/:id":    {Summary: "Get an order", Response: models.Order{}},
+	"PUT /order/:id":    {Summary: "Update an order and take its quantity from stock", Request: controllers.UpdateOrderRequest{}, Response: models.Order{}},
+	"DELETE /order/:id": {Summary: "Delete an order", Response: messageResponse{}},
+
+	"GET /api/products/total_quantity":        {Summary: "Total units across catalog products", Response: totalQuantityResponse{}},
+	"GET /api/products":                       {Summary: "List catalog products", Response: listing.Page[models.Product]{}, List: &services.ProductListing},
+	"GET /api/products/supplier/:supplier_id": {Summary: "List a supplier's catalog products", Response: listing.Page[models.Product]{}, List: &services.ProductListing},
+
+	"POST /api/shifts/open":   {Summary: "Open a shift with a cash float", Request: controllers.OpenShiftRequest{}, Response: models.Shift{}, Status: fiber.StatusCreated},
+	"POST /api/shifts/close":  {Summary: "Close the caller's shift with a cash count", Request: controllers.CloseShiftRequest{}, Response: models.Shift{}},
+	"GET /api/shifts/current": {Summary: "The caller's open shift with running totals", Response: models.Shift{}},
+	"GET /api/shifts":         {Summary: "List shifts with the cash totals of all closed shifts that match", Response: services.ShiftPage{}, List: &services.ShiftListing},
+}
+
+var (
+	specOnce sync.Once
+	spec     *openapi.Document
+)
+
+// openAPISpec builds the document from every route registered through handle.
+func openAPISpec() *openapi.Document {
+	specOnce.Do(func() { spec = buildSpec() })
+	return spec
+}
+
+func buildSpec() *openapi.Document {
+	g := openapi.NewGenerator()
+	errorSchema := g.SchemaOf(errorResponse{})
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   "OTOP.PH API",
+			Version: "1.0",
+			Description: "Errors share one body: a stable `code`, a readable `error` and, " +
+				"for validation failures, per-field `fields`.",
+		},
+		Paths: map[string]map[string]*openapi.Operation{},
+	}
+
+	keys := make([]string, 0, len(routePermissions))
+	for key := range routePermissions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// OpenAPI treats /products/{id} and /products/{supplier_id} as the same
+	// path, so routes that differ only in parameter names share the first
+	// path's names
+	canonical := map[string]string{}
+
+	for _, key := range keys {
+		method, path, _ := strings.Cut(key, " ")
+		permission := routePermissions[key]
+		rd := routeDocs[key]
+
+		op := &openapi.Operation{
+			Tags:        []string{routeTag(path)},
+			Summary:     rd.Summary,
+			OperationID: operationID(method, path),
+			Responses:   map[string]openapi.Response{},
+		}
+		openAPIPath := openAPIPath(path)
+		shape := pathShape(path)
+		if first, ok := canonical[shape]; ok {
+			openAPIPath = first
+		} else {
+			canonical[shape] = openAPIPath
+		}
+		names := pathParams(openAPIPath)
+		for i, name := range pathParams(path) {
+			typ := "string"
+			if name == "id" || strings.HasSuffix(name, "_id") {
+				typ = "integer"
+			}
+			param := openapi.Parameter{Name: names[i], In: "path", Required: true, Schema: &openapi.Schema{Type: typ}}
+			if names[i] != name {
+				param.Description = name
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		queries := rd.Query
+		if rd.List != nil {
+			queries = append(listQuery(*rd.List), queries...)
+		}
+		for _, q := range queries {
+			schema := &openapi.Schema{Type: q.Type}
+			if q.Type == "date" {
+				schema = &openapi.Schema{Type: "string", Format: "date"}
+			}
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: q.Name, In: "query", Description: q.Description, Schema: schema})
+		}
+
+		if rd.Request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(g.SchemaOf(rd.Request))}
+		}
+
+		status := rd.Status
+		if status == 0 {
+			status = fiber.StatusOK
+		}
+		success := openapi.Response{Description: "Success"}
+		switch {
+		case rd.ContentType != "":
+			success.Content = map[string]openapi.MediaType{rd.ContentType: {Schema: &openapi.Schema{Type: "string"}}}
+		case rd.Response != nil:
+			success.Content = openapi.JSON(g.SchemaOf(rd.Response))
+		}
+		op.Responses[fmt.Sprint(status)] = success
+		op.Responses["default"] = openapi.Response{Description: "Error", Content: openapi.JSON(errorSchema)}
+
+		if permission != middleware.Public {
+			op.Permission = string(permission)
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			op.Description = fmt.Sprintf("Requires the `%s` permission (roles: %s).",
+				permission, strings.Join(middleware.RolesWith(permission), ", "))
+		}
+
+		if doc.Paths[openAPIPath] == nil {
+			doc.Paths[openAPIPath] = map[string]*openapi.Operation{}
+		}
+		doc.Paths[openAPIPath][strings.ToLower(method)] = op
+	}
+
+	doc.Components = openapi.Components{
+		Schemas: g.Schemas,
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+	return doc
+}
+
+// pathParams lists the parameter names in a Fiber or OpenAPI path.
+func pathParams(path string) []string {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "{") {
+			params = append(params, strings.Trim(segment, ":{}"))
+		}
+	}
+	return params
+}
+
+// pathShape is path with the parameter names left out.
+func pathShape(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = ":"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// openAPIPath turns /order/:id into /order/{id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// routeTag groups operations by the first path segment after /api.
+func routeTag(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/api"), "/")
+	if len(segments) < 2 || segments[1] == "" || strings.HasPrefix(segments[1], ":") {
+		return "api"
+	}
+	return strings.TrimPrefix(segments[1], ".")
+}
+
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, ":.{}")
+		if segment != "" {
+			id += "_" + strings.NewReplacer(".", "_", "-", "_").Replace(segment)
+		}
+	}
+	return id
+}
+
+// docsPage renders the document with Swagger UI.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>OTOP.PH API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "/api/docs/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
+
+func serveDocs(c *fiber.Ctx) error {
+	c.Type("html", "utf-8")
+	return c.SendString(docsPage)
+}
+
+func serveOpenAPI(c *fiber.Ctx) error {
+	return c.JSON(openAPISpec())
+}
@@ -1,86 +1,197 @@
 package routes
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/m/config"
 	"github.com/m/controllers"
+	"github.com/m/metrics"
 	middleware "github.com/m/middleware"
+	"github.com/m/utils"
+	"gorm.io/gorm"
 )
 
-func UserRoutes(app *fiber.App) {
+// routePermissions records the permission every route was registered with,
+// keyed by routeKey. routes_test.go fails if a route bypasses handle.
+var routePermissions = map[string]middleware.Permission{}
+
+// routeTable registers routes whose tokens are checked against the sessions
+// in db.
+type routeTable struct {
+	db *gorm.DB
+}
+
+// handle registers a route behind the authorization middleware for
+// permission. Use middleware.Public for routes that need no token.
+func (r routeTable) handle(router fiber.Router, method, path string, permission middleware.Permission, handlers ...fiber.Handler) {
+	prefix := ""
+	if group, ok := router.(*fiber.Group); ok {
+		prefix = group.Prefix
+	}
+	routePermissions[routeKey(method, joinPath(prefix, path))] = permission
+
+	if permission != middleware.Public {
+		handlers = append([]fiber.Handler{middleware.Authorize(r.db, permission)}, handlers...)
+	}
+	router.Add(method, path, handlers...)
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return strings.TrimRight(prefix, "/") + path
+}
+
+// routeKey normalises a path the way Fiber matches it by default.
+func routeKey(method, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return method + " " + path
+}
+
+// UserRoutes serves every endpoint through h. Metrics are guarded by the
+// token in cfg.
+func UserRoutes(app *fiber.App, cfg *config.Config, h *controllers.Handler) {
+	get, post, put, del := fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodDelete
+	handle := routeTable{db: h.DB}.handle
+
+	// Probes for the load balancer and orchestrator
+	handle(app, get, "/healthz", middleware.Public, controllers.Liveness)
+	handle(app, get, "/readyz", middleware.Public, h.Readiness)
+
+	// Prometheus scrape endpoint, behind METRICS_TOKEN when it is set
+	handle(app, get, "/metrics", middleware.Public, middleware.MetricsAuth(cfg.Log.MetricsToken), metrics.Handler())
+
+	// API reference generated from the routes below; see docs.go
+	handle(app, get, "/api/docs", middleware.Public, serveDocs)
+	handle(app, get, "/api/docs/openapi.json", middleware.Public, serveOpenAPI)
+
+	// Public verification keys for other internal services
+	handle(app, get, "/.well-known/jwks.json", middleware.Public, func(c *fiber.Ctx) error {
+		return c.JSON(utils.PublicJWKS())
+	})
 
 	// public routes (DONE)
 	api := app.Group("/api")
-	api.Post("/register", controllers.Register)
-	api.Post("/login", controllers.UnifiedLogin)
-	api.Post("/logout", controllers.Logout)
-	api.Put("/updateItem", controllers.UpdateOtopProductHandler)
+	handle(api, post, "/login", middleware.Public, h.UnifiedLogin)
+	handle(api, post, "/refresh", middleware.Public, h.RefreshToken)
+	handle(api, post, "/password/forgot", middleware.Public, h.RequestPasswordReset)
+	handle(api, post, "/password/reset", middleware.Public, h.ResetPassword)
+	handle(api, post, "/suppliers/activate", middleware.Public, h.ActivateSupplier)
+	handle(api, post, "/logout", middleware.PermSession, h.Logout)
+	handle(api, post, "/logout_all", middleware.PermSession, h.LogoutAll)
+	handle(api, put, "/updateItem", middleware.PermInventoryAdjust, h.UpdateOtopProductHandler)
+
+	// Supplier's own purchase count; registered before /supplier/:storeName so it is not shadowed
+	handle(app, get, "/supplier/purchases", middleware.PermSupplierSelf, h.GetMyTotalPurchases)
 
 	// Admin-only routes(DONE)
 	supplier := app.Group("/supplier")
-	supplier.Use(middleware.JWTProtected)
-	supplier.Use(middleware.IsAdmin)
-	supplier.Post("/", controllers.CreateSupplier)
-	supplier.Get("/", controllers.GetSuppliers)
-	supplier.Get("/:storeName", controllers.GetSupplierByStoreName)
-	supplier.Put("/:storeName", controllers.UpdateSupplier)
-	supplier.Delete("/:storeName", controllers.DeleteSupplier)
+	handle(supplier, post, "/", middleware.PermSuppliersManage, h.CreateSupplier)
+	handle(supplier, get, "/", middleware.PermSuppliersManage, h.GetSuppliers)
+	handle(supplier, get, "/:storeName", middleware.PermSuppliersManage, h.GetSupplierByStoreName)
+	handle(supplier, put, "/:storeName", middleware.PermSuppliersManage, h.UpdateSupplier)
+	handle(supplier, del, "/:storeName", middleware.PermSuppliersManage, h.DeleteSupplier)
+	handle(supplier, post, "/:id/invitation", middleware.PermSuppliersManage, h.ResendSupplierInvitation)
+	handle(supplier, del, "/:id/invitation", middleware.PermSuppliersManage, h.RevokeSupplierInvitation)
+
+	// Staff accounts are managed by admins; the first admin comes from `create-admin`
+	users := app.Group("/api/users")
+	handle(users, get, "/", middleware.PermUsersManage, h.GetUsers)
+	handle(users, post, "/", middleware.PermUsersManage, h.CreateUser)
+	handle(users, get, "/:id", middleware.PermUsersManage, h.GetUser)
+	handle(users, fiber.MethodPatch, "/:id", middleware.PermUsersManage, h.UpdateUser)
+	handle(users, post, "/:id/disable", middleware.PermUsersManage, h.DisableUser)
+	handle(users, post, "/:id/enable", middleware.PermUsersManage, h.EnableUser)
+	handle(users, del, "/:id", middleware.PermUsersManage, h.DeleteUser)
+
+	// Admin can sign a user or supplier out of every device
+	handle(app, post, "/api/sessions/revoke", middleware.PermUsersManage, h.RevokeSubjectSessions)
+
+	// Admin review and release of login lockouts
+	handle(app, get, "/api/admin/lockouts", middleware.PermUsersManage, h.GetLockouts)
+	handle(app, post, "/api/admin/lockouts/unlock", middleware.PermUsersManage, h.UnlockLogin)
+
+	// Audit trail of every data change
+	handle(app, get, "/api/admin/audit", middleware.PermAuditRead, h.GetAuditLogs)
 
 	// Add products by supplier (DONE)
-	supplierRoutes := app.Group("/products", middleware.IsSupplier, middleware.JWTProtected)
-	supplierRoutes.Post("/", controllers.AddProduct)
-	supplierRoutes.Get("/", controllers.GetProducts)
-	supplierRoutes.Get("/:supplier_id", controllers.GetProductByName) // search by name
-	supplierRoutes.Put("/:id", controllers.UpdateProduct)
-	supplierRoutes.Delete("/:id", controllers.DeleteProduct)
-	supplierRoutes.Get("/", controllers.GetSupplierProducts)
-	supplierRoutes.Get("/", controllers.GetMyProducts)
-	supplierRoutes.Post("/confirm/:id", controllers.ConfirmOrders)            //supplier will confirm the order from admin by order id
-	supplierRoutes.Get("/orders/:supplier_id", controllers.GetSupplierOrders) // with toke of the suppliers unique
+	supplierRoutes := app.Group("/products")
+	handle(supplierRoutes, post, "/", middleware.PermCatalogManage, h.AddProduct)
+	handle(supplierRoutes, get, "/", middleware.PermCatalogManage, h.GetProducts)
+	handle(supplierRoutes, get, "/:supplier_id", middleware.PermCatalogManage, h.GetProductByName) // search by name
+	handle(supplierRoutes, put, "/:id", middleware.PermCatalogManage, h.UpdateProduct)
+	handle(supplierRoutes, del, "/:id", middleware.PermCatalogManage, h.DeleteProduct)
+	handle(supplierRoutes, get, "/", middleware.PermCatalogManage, h.GetSupplierProducts)
+	handle(supplierRoutes, get, "/", middleware.PermCatalogManage, h.GetMyProducts)
+	handle(supplierRoutes, post, "/confirm/:id", middleware.PermOrdersApprove, h.ConfirmOrders)           //supplier will confirm the order from admin by order id
+	handle(supplierRoutes, get, "/orders/:supplier_id", middleware.PermSupplierSelf, h.GetSupplierOrders) // with toke of the suppliers unique
 
 	// the supplier will confirmed the order from admin(NOT YET)
-	app.Put("/orders/:id/confirm", controllers.ConfirmOrder)
-	app.Get("/orders/:supplier_id", controllers.GetSupplierOrders)
-	app.Get("/suppliers/all_purchases", controllers.GetSupplierPurchaseCounts)             // for addmin only with limit of 6 suppliers
-	app.Get("/suppliers/all_purchase", controllers.GetSupplierPurchaseCount)               // all suppliers will get puschased
-	app.Get("/suppliers/purchases/:id", controllers.GetSupplierPurchasesByID)              // for admin only
-	app.Get("/supplier/purchases", middleware.IsSupplier, controllers.GetMyTotalPurchases) // for suppliers
+	handle(app, put, "/orders/:id/confirm", middleware.PermOrdersApprove, h.ConfirmOrder)
+	handle(app, get, "/orders/:supplier_id", middleware.PermOrdersRead, h.GetSupplierOrders)
+	handle(app, get, "/suppliers/all_purchases", middleware.PermReportsRead, h.GetSupplierPurchaseCounts) // for addmin only with limit of 6 suppliers
+	handle(app, get, "/suppliers/all_purchase", middleware.PermReportsRead, h.GetSupplierPurchaseCount)   // all suppliers will get puschased
+	handle(app, get, "/suppliers/purchases/:id", middleware.PermReportsRead, h.GetSupplierPurchasesByID)  // for admin only
 
 	//Can Get Total Otop Products Stocks & Name(DONE)
-	app.Post("/api/otop/add_products", controllers.CreateOtopProduct, func(c *fiber.Ctx) error {
+	handle(app, post, "/api/otop/add_products", middleware.PermInventoryAdjust, h.CreateOtopProduct, func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "Total otop products"})
 	})
-	app.Get("/api/otop/products", controllers.GetOtopProducts) // get all otop products (USED)
-	app.Delete("/api/otop/:id", controllers.DeleteOtopProduct) // delete product using drop down (USED)
-	app.Put("/api/otop/:id", controllers.UpdateOtopProduct)
-	app.Get("/api/otop/total_quantity", controllers.GetOtopTotalQuantity)                                            // total of otop products quantity of all products
-	app.Get("/api/otop/total_quantity_name", controllers.GetOtopTotalQuantityName)                                   // diffrerent store name and total  quantity of products
-	app.Get("/api/otop/total_products", controllers.GetOtopTotalProducts)                                            // total number of products(USED)
-	app.Get("/api/otop/total_categories", controllers.GetTotalProductsByCategory)                                    // total products on food and non-food(USED)
-	app.Get("/api/otop/total_suppliers", controllers.GetTotalSuppliers)                                              // count all suppliers(USED)
-	app.Get("/api/otop/total_suppliers_product", controllers.GetSupplierProductCounts)                               // supplier and number of products
-	app.Get("/api/otop/total_amount_suppliers/:supplier_id/total_amount", controllers.GetTotalPurchasedBySupplierID) // total of every suppliers amount of purchased
-
-	app.Post("/api/otop/sold_items", controllers.RecordSoldItem)                           // makinng solds POS
-	app.Get("/api/otop/solds_products", controllers.GetAllSoldItems)                       // the total solds
-	app.Get("/api/otop/solds_products/:supplier_id", controllers.GetSoldItemsBySupplierID) // by supplier solds
-	app.Post("/api/otop/add_cart", controllers.AddToCartHandler)
-	app.Get("api/otop/most_solds", controllers.GetTopSoldProducts)
+	handle(app, get, "/api/otop/products", middleware.PermInventoryRead, h.GetOtopProducts) // get all otop products (USED)
+	handle(app, del, "/api/otop/:id", middleware.PermInventoryAdjust, h.DeleteOtopProduct)  // delete product using drop down (USED)
+	handle(app, put, "/api/otop/:id", middleware.PermInventoryAdjust, h.UpdateOtopProduct)
+	handle(app, get, "/api/otop/total_quantity", middleware.PermInventoryRead, h.GetOtopTotalQuantity)                                          // total of otop products quantity of all products
+	handle(app, get, "/api/otop/total_quantity_name", middleware.PermInventoryRead, h.GetOtopTotalQuantityName)                                 // diffrerent store name and total  quantity of products
+	handle(app, get, "/api/otop/total_products", middleware.PermInventoryRead, h.GetOtopTotalProducts)                                          // total number of products(USED)
+	handle(app, get, "/api/otop/total_categories", middleware.PermInventoryRead, h.GetTotalProductsByCategory)                                  // total products on food and non-food(USED)
+	handle(app, get, "/api/otop/total_suppliers", middleware.PermReportsRead, h.GetTotalSuppliers)                                              // count all suppliers(USED)
+	handle(app, get, "/api/otop/total_suppliers_product", middleware.PermReportsRead, h.GetSupplierProductCounts)                               // supplier and number of products
+	handle(app, get, "/api/otop/total_amount_suppliers/:supplier_id/total_amount", middleware.PermReportsRead, h.GetTotalPurchasedBySupplierID) // total of every suppliers amount of purchased
+
+	handle(app, post, "/api/otop/sold_items", middleware.PermPOSCheckout, h.RecordSoldItem)                         // makinng solds POS
+	handle(app, get, "/api/otop/solds_products", middleware.PermSalesRead, h.GetAllSoldItems)                       // the total solds
+	handle(app, get, "/api/otop/solds_products/:supplier_id", middleware.PermSalesRead, h.GetSoldItemsBySupplierID) // by supplier solds
+	handle(app, post, "/api/otop/add_cart", middleware.PermPOSCheckout, h.AddToCartHandler)
+	handle(app, get, "/api/otop/most_solds", middleware.PermSalesRead, h.GetTopSoldProducts)
 	// Order Management for the admin with supplier (DONE)
 	admin := app.Group("/order")
-	admin.Post("/", controllers.CreateOrder)
-	admin.Get("/", controllers.GetOrders)
-	admin.Get("/:id", controllers.GetOrder)
-	admin.Put("/:id", controllers.UpdateOrder)
-	admin.Delete("/:id", controllers.DeleteOrder)
+	handle(admin, post, "/", middleware.PermOrdersManage, h.CreateOrder)
+	handle(admin, get, "/", middleware.PermOrdersRead, h.GetOrders)
+	handle(admin, get, "/:id", middleware.PermOrdersRead, h.GetOrder)
+	handle(admin, put, "/:id", middleware.PermOrdersManage, h.UpdateOrder)
+	handle(admin, del, "/:id", middleware.PermOrdersManage, h.DeleteOrder)
 
 	//for admin and cashier (DONE)
-	app.Get("/api/products/total_quantity", controllers.GetTotalQuantity)
-	app.Get("/api/products", controllers.GetProducts)
-	app.Get("/api/products/supplier/:supplier_id", controllers.GetProductsByStore)
-
-	app.Post("/api/otop/POS", controllers.POSController)
-	app.Post("/api/otop/getSummary", controllers.GetSalesSummary)
-	app.Post("/api/otop/supplierSummary", controllers.GetSupplierSalesSummary)
-	app.Post("/api/otop/getByDate", controllers.GetSoldItemsByDateRangePost)
+	handle(app, get, "/api/products/total_quantity", middleware.PermCatalogRead, h.GetTotalQuantity)
+	handle(app, get, "/api/products", middleware.PermCatalogRead, h.GetProducts)
+	handle(app, get, "/api/products/supplier/:supplier_id", middleware.PermCatalogRead, h.GetProductsByStore)
+
+	handle(app, post, "/api/otop/POS", middleware.PermPOSCheckout, h.POSController)
+
+	// Cashier shifts: open with a float, close with a cash count
+	handle(app, post, "/api/shifts/open", middleware.PermPOSCheckout, h.OpenShift)
+	handle(app, post, "/api/shifts/close", middleware.PermPOSCheckout, h.CloseShift)
+	handle(app, get, "/api/shifts/current", middleware.PermPOSCheckout, h.GetCurrentShift)
+	handle(app, get, "/api/shifts", middleware.PermReportsRead, h.GetShifts)
+
+	handle(app, post, "/api/otop/getSummary", middleware.PermSalesRead, h.GetSalesSummary)
+	handle(app, post, "/api/otop/supplierSummary", middleware.PermSalesRead, h.GetSupplierSalesSummary)
+	handle(app, post, "/api/otop/tenderSummary", middleware.PermSalesRead, h.GetTenderSalesSummary)
+	handle(app, post, "/api/otop/vatSummary", middleware.PermSalesRead, h.GetVATSalesSummary)
+	handle(app, post, "/api/otop/supplierVatSummary", middleware.PermSalesRead, h.GetSupplierVATSalesSummary)
+
+	// Senior citizen and PWD discount register for compliance
+	handle(app, get, "/api/reports/discounts", middleware.PermReportsRead, h.GetDiscountRegister)
+	handle(app, post, "/api/otop/getByDate", middleware.PermSalesRead, h.GetSoldItemsByDateRangePost)
 }
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/m/config"
+	"github.com/m/controllers"
+	middleware "github.com/m/middleware"
+	"github.com/m/openapi"
+	"github.com/m/services"
+)
+
+func TestEveryRouteDeclaresPermission(t *testing.T) {
+	app := fiber.New()
+	UserRoutes(app, &config.Config{}, controllers.NewHandler(nil, services.New(nil, services.DefaultSalesRules())))
+
+	for _, route := range app.GetRoutes(true) {
+		// Fiber registers HEAD automatically for every GET route
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		key := routeKey(route.Method, route.Path)
+		permission, ok := routePermissions[key]
+		if !ok {
+			t.Errorf("%s is registered without a permission", key)
+			continue
+		}
+		if !middleware.IsKnownPermission(permission) {
+			t.Errorf("%s declares unknown permission %q", key, permission)
+		}
+	}
+}
+
+func TestEveryRouteHasSpecEntry(t *testing.T) {
+	app := fiber.New()
+	UserRoutes(app, &config.Config{}, controllers.NewHandler(nil, services.New(nil, services.DefaultSalesRules())))
+
+	registered := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		key := routeKey(route.Method, route.Path)
+		registered[key] = true
+
+		if doc, ok := routeDocs[key]; !ok || doc.Summary == "" {
+			t.Errorf("%s has no entry in routeDocs", key)
+		}
+	}
+	for key := range routeDocs {
+		if !registered[key] {
+			t.Errorf("routeDocs describes %s, which is not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	app := fiber.New()
+	UserRoutes(app, &config.Config{}, controllers.NewHandler(nil, services.New(nil, services.DefaultSalesRules())))
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/docs/openapi.json", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	checkout := doc.Paths["/api/otop/POS"]["post"]
+	if checkout == nil || checkout.Permission != string(middleware.PermPOSCheckout) || len(checkout.Security) == 0 {
+		t.Fatalf("checkout operation = %+v", checkout)
+	}
+	if login := doc.Paths["/api/login"]["post"]; login == nil || len(login.Security) != 0 {
+		t.Errorf("login should be public: %+v", login)
+	}
+	if order := doc.Paths["/order/{id}"]["get"]; order == nil || len(order.Parameters) != 1 || order.Parameters[0].Name != "id" {
+		t.Errorf("order path parameters = %+v", order)
+	}
+
+	request := doc.Components.Schemas["CheckoutRequest"]
+	if request == nil {
+		t.Fatal("CheckoutRequest schema missing")
+	}
+	items := request.Properties["items"]
+	if items == nil || items.MinItems == nil || *items.MinItems != 1 {
+		t.Errorf("items schema = %+v, want minItems 1", items)
+	}
+	if len(request.Required) == 0 {
+		t.Error("CheckoutRequest lists no required fields")
+	}
+}
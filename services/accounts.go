@@ -0,0 +1,24 @@
+package services
+
+import (
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+// CheckEmailAvailable makes sure no other user or supplier signs in with
+// email. excludeUserID skips the user being edited.
+func CheckEmailAvailable(db *gorm.DB, email string, excludeUserID uint) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("email = ? AND id <> ?", email, excludeUserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := db.Model(&models.Supplier{}).Where("email = ?", email).Count(&count).Error; err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
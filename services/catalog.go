@@ -0,0 +1,128 @@
+package services
+
+import (
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+// Catalog manages the products suppliers offer for ordering. Products are
+// numbered by models.Product's BeforeCreate hook.
+type Catalog interface {
+	List(opts listing.Options) (listing.Page[models.Product], error)
+	ListBySupplier(supplierID uint, opts listing.Options) (listing.Page[models.Product], error)
+	Get(id uint) (models.Product, error)
+	// Create adds a product to the supplier's catalog.
+	Create(actor audit.Actor, supplierID uint, product models.Product) (models.Product, error)
+	// Update changes a product that belongs to supplierID.
+	Update(actor audit.Actor, id, supplierID uint, update ProductUpdate) (ProductChange, error)
+	// Delete removes a product that belongs to supplierID.
+	Delete(actor audit.Actor, id, supplierID uint) (models.Product, error)
+	TotalQuantity() (int64, error)
+}
+
+// ProductUpdate is what a supplier can change about a catalog product.
+type ProductUpdate struct {
+	Name     string
+	Price    float64
+	Quantity int64
+}
+
+type catalogService struct {
+	db *gorm.DB
+}
+
+func NewCatalog(db *gorm.DB) Catalog {
+	return &catalogService{db: db}
+}
+
+// ProductListing is how catalog products can be filtered and sorted.
+var ProductListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"category":    {Column: "category"},
+		"supplier_id": {Column: "supplier_id", Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"price":      "price",
+		"quantity":   "quantity",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	DateColumn:  "created_at",
+	Search:      "name",
+}
+
+func (s *catalogService) List(opts listing.Options) (listing.Page[models.Product], error) {
+	return listing.Find[models.Product](s.db, opts)
+}
+
+func (s *catalogService) ListBySupplier(supplierID uint, opts listing.Options) (listing.Page[models.Product], error) {
+	return listing.Find[models.Product](s.db, opts.Scope("supplier_id", supplierID))
+}
+
+func (s *catalogService) Get(id uint) (models.Product, error) {
+	var product models.Product
+	err := s.db.First(&product, id).Error
+	return product, notFound(err, "product", id)
+}
+
+func (s *catalogService) Create(actor audit.Actor, supplierID uint, product models.Product) (models.Product, error) {
+	product.SupplierID = supplierID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "product", product.ID, nil, product)
+	})
+	return product, err
+}
+
+func (s *catalogService) Update(actor audit.Actor, id, supplierID uint, update ProductUpdate) (ProductChange, error) {
+	var change ProductChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, id).Error; err != nil {
+			return notFound(err, "product", id)
+		}
+		if product.SupplierID != supplierID {
+			return ErrNotProductOwner
+		}
+		change.Before = product
+
+		product.Name = update.Name
+		product.Price = update.Price
+		product.Quantity = update.Quantity
+		if err := tx.Save(&product).Error; err != nil {
+			return err
+		}
+		change.After = product
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "product", product.ID, change.Before, change.After)
+	})
+	return change, err
+}
+
+func (s *catalogService) Delete(actor audit.Actor, id, supplierID uint) (models.Product, error) {
+	var product models.Product
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, id).Error; err != nil {
+			return notFound(err, "product", id)
+		}
+		if product.SupplierID != supplierID {
+			return ErrNotProductOwner
+		}
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionDelete, "product", product.ID, product, nil)
+	})
+	return product, err
+}
+
+func (s *catalogService) TotalQuantity() (int64, error) {
+	var total int64
+	err := s.db.Model(&models.Product{}).Select("COALESCE(SUM(quantity), 0)").Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrNoOpenShift         = errors.New("cashier has no open shift")
+	ErrInsufficientPayment = errors.New("received amount is less than the total")
+	ErrNonCashOverpayment  = errors.New("card and e-wallet payments exceed the total")
+	ErrNothingDiscountable = errors.New("no item in the cart qualifies for the senior citizen or PWD discount")
+	ErrNoSuppliers         = errors.New("no valid suppliers found for the transaction")
+	ErrOrderNotPending     = errors.New("order already confirmed or completed")
+	ErrNotOrderSupplier    = errors.New("order belongs to another supplier")
+	ErrInvalidInterval     = errors.New("interval must be daily, weekly, monthly, or yearly")
+	ErrDuplicate           = errors.New("already exists")
+	ErrSupplierMismatch    = errors.New("product belongs to another supplier")
+	ErrEmailTaken          = errors.New("email is already registered")
+	ErrNotProductOwner     = errors.New("product belongs to another supplier")
+	ErrShiftAlreadyOpen    = errors.New("cashier already has an open shift")
+	ErrLastAdmin           = errors.New("at least one active admin must remain")
+	ErrSessionInvalid      = errors.New("session is invalid or expired")
+	ErrAccountInactive     = errors.New("account is not active")
+)
+
+// NotFoundError names the missing record. It matches ErrNotFound with
+// errors.Is.
+type NotFoundError struct {
+	Entity string
+	ID     interface{}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %v not found", e.Entity, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// InsufficientStockError is returned when a sale or order asks for more than
+// is on hand.
+type InsufficientStockError struct {
+	ProductID uint
+	Name      string
+	Available int64
+	Requested int64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: %d available, %d requested", e.ProductID, e.Available, e.Requested)
+}
+
+// notFound converts gorm's record-not-found into a NotFoundError and passes
+// other errors through.
+func notFound(err error, entity string, id interface{}) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotFoundError{Entity: entity, ID: id}
+	}
+	return err
+}
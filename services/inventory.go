@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+// StockChange is one OTOP product row before and after a change, kept for
+// the audit trail.
+type StockChange struct {
+	Before models.OtopProducts
+	After  models.OtopProducts
+}
+
+// SupplierChange is a supplier row before and after a change.
+type SupplierChange struct {
+	Before models.Supplier
+	After  models.Supplier
+}
+
+type NameQuantity struct {
+	ProductName   string `json:"product_name"`
+	TotalQuantity int64  `json:"total_quantity"`
+}
+
+// Inventory manages the OTOP products on the shelf.
+type Inventory interface {
+	// List returns products with their supplier, optionally filtered by a
+	// name search.
+	List(opts listing.Options) (listing.Page[models.OtopProducts], error)
+	Get(id uint) (models.OtopProducts, error)
+	// Create stocks a new product for the supplier named by StoreName and
+	// bumps that supplier's purchase count.
+	Create(actor audit.Actor, product models.OtopProducts) (models.OtopProducts, SupplierChange, error)
+	Save(actor audit.Actor, product *models.OtopProducts) error
+	// UpdateByStore updates every product of a supplier's store with the
+	// non-zero fields of p.
+	UpdateByStore(actor audit.Actor, p models.OtopProducts) ([]StockChange, error)
+	Delete(actor audit.Actor, id uint) (models.OtopProducts, error)
+	// CheckCartItem makes sure the product exists and belongs to the supplier.
+	CheckCartItem(productID, supplierID uint) error
+
+	TotalQuantity() (int64, error)
+	QuantityByName() ([]NameQuantity, error)
+	DistinctProductCount() (int64, error)
+	CountByCategory() (map[string]int64, error)
+}
+
+type inventoryService struct {
+	db *gorm.DB
+}
+
+func NewInventory(db *gorm.DB) Inventory {
+	return &inventoryService{db: db}
+}
+
+// deductStock takes quantity off a product's stock. Every sale goes through
+// here, whichever endpoint rang it up. The decrement is a single conditional
+// UPDATE, so concurrent sales can neither oversell nor lose each other's
+// changes; run it inside the sale's transaction and the row stays locked
+// until the sale commits. The change is audited in the same transaction.
+func deductStock(db *gorm.DB, actor audit.Actor, productID uint, quantity int64) (StockChange, error) {
+	res := db.Model(&models.OtopProducts{}).
+		Where("id = ? AND quantity >= ?", productID, quantity).
+		UpdateColumn("quantity", gorm.Expr("quantity - ?", quantity))
+	if res.Error != nil {
+		return StockChange{}, res.Error
+	}
+
+	var product models.OtopProducts
+	if err := db.First(&product, productID).Error; err != nil {
+		return StockChange{}, notFound(err, "product", productID)
+	}
+	if res.RowsAffected == 0 {
+		return StockChange{}, &InsufficientStockError{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Available: product.Quantity,
+			Requested: quantity,
+		}
+	}
+
+	before := product
+	before.Quantity += quantity
+	if err := audit.RecordTx(db, actor, audit.ActionUpdate, "otop_product", product.ID, before, product); err != nil {
+		return StockChange{}, err
+	}
+	return StockChange{Before: before, After: product}, nil
+}
+
+// OtopProductListing is how inventory products can be filtered and sorted.
+var OtopProductListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"category":    {Column: "category"},
+		"supplier_id": {Column: "supplier_id", Kind: listing.Int},
+		"store_name":  {Column: "store_name"},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"price":      "price",
+		"quantity":   "quantity",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	DateColumn:  "created_at",
+	Search:      "name",
+}
+
+func (s *inventoryService) List(opts listing.Options) (listing.Page[models.OtopProducts], error) {
+	return listing.Find[models.OtopProducts](s.db, opts, "Supplier")
+}
+
+func (s *inventoryService) Get(id uint) (models.OtopProducts, error) {
+	var product models.OtopProducts
+	err := s.db.First(&product, id).Error
+	return product, notFound(err, "product", id)
+}
+
+func (s *inventoryService) Create(actor audit.Actor, product models.OtopProducts) (models.OtopProducts, SupplierChange, error) {
+	var change SupplierChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Descriptions identify products on the shelf, so they must be unique
+		var existing models.OtopProducts
+		if err := tx.Where("description = ?", product.Description).First(&existing).Error; err == nil {
+			return ErrDuplicate
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var supplier models.Supplier
+		if err := tx.Where("store_name = ?", product.StoreName).First(&supplier).Error; err != nil {
+			return notFound(err, "store", product.StoreName)
+		}
+
+		// Increment the Purchased count
+		change.Before = supplier
+		if err := tx.Model(&supplier).UpdateColumn("purchased", gorm.Expr("purchased + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&change.After, supplier.ID).Error; err != nil {
+			return err
+		}
+		if err := audit.RecordTx(tx, actor, audit.ActionUpdate, "supplier", supplier.ID, change.Before, change.After); err != nil {
+			return err
+		}
+
+		var lastProduct models.OtopProducts
+		if err := tx.Raw("SELECT * FROM otop_products ORDER BY created_at DESC LIMIT 1").Scan(&lastProduct).Error; err != nil {
+			slog.Warn("fetching last product for its sequential number", "error", err)
+		}
+
+		// Generate new sequential number, format 'SP-0001'
+		seqNumber := 1
+		if lastProduct.ID != 0 {
+			fmt.Sscanf(lastProduct.SequentialNumber, "SP-%04d", &seqNumber)
+			seqNumber++
+		}
+		product.SequentialNumber = fmt.Sprintf("SP-%04d", seqNumber)
+		product.SupplierID = supplier.ID
+		product.CreatedAt = time.Now()
+
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "otop_product", product.ID, nil, product)
+	})
+	if err != nil {
+		return product, change, err
+	}
+
+	// Return the product with its supplier
+	err = s.db.Preload("Supplier").First(&product, product.ID).Error
+	return product, change, err
+}
+
+func (s *inventoryService) Save(actor audit.Actor, product *models.OtopProducts) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var before models.OtopProducts
+		if err := tx.First(&before, product.ID).Error; err != nil {
+			return notFound(err, "product", product.ID)
+		}
+		if err := tx.Save(product).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "otop_product", product.ID, before, product)
+	})
+}
+
+func (s *inventoryService) UpdateByStore(actor audit.Actor, p models.OtopProducts) ([]StockChange, error) {
+	var changes []StockChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var before []models.OtopProducts
+		if err := tx.Where("supplier_id = ? AND store_name = ?", p.SupplierID, p.StoreName).Find(&before).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.OtopProducts{}).Where("supplier_id = ? AND store_name = ?", p.SupplierID, p.StoreName).
+			Updates(models.OtopProducts{
+				Name:        p.Name,
+				Description: p.Description,
+				Price:       p.Price,
+				Category:    p.Category,
+				Quantity:    p.Quantity,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &NotFoundError{Entity: "store products", ID: p.StoreName}
+		}
+
+		changes = make([]StockChange, 0, len(before))
+		for _, product := range before {
+			var after models.OtopProducts
+			if err := tx.First(&after, product.ID).Error; err != nil {
+				return err
+			}
+			if err := audit.RecordTx(tx, actor, audit.ActionUpdate, "otop_product", product.ID, product, after); err != nil {
+				return err
+			}
+			changes = append(changes, StockChange{Before: product, After: after})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (s *inventoryService) Delete(actor audit.Actor, id uint) (models.OtopProducts, error) {
+	var product models.OtopProducts
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, id).Error; err != nil {
+			return notFound(err, "product", id)
+		}
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionDelete, "otop_product", product.ID, product, nil)
+	})
+	return product, err
+}
+
+func (s *inventoryService) CheckCartItem(productID, supplierID uint) error {
+	product, err := s.Get(productID)
+	if err != nil {
+		return err
+	}
+
+	var supplier models.Supplier
+	if err := s.db.First(&supplier, supplierID).Error; err != nil {
+		return notFound(err, "supplier", supplierID)
+	}
+
+	if product.SupplierID != supplier.ID {
+		return fmt.Errorf("product supplier %d, supplier %d: %w", product.SupplierID, supplier.ID, ErrSupplierMismatch)
+	}
+	return nil
+}
+
+func (s *inventoryService) TotalQuantity() (int64, error) {
+	var total int64
+	err := s.db.Model(&models.OtopProducts{}).Select("COALESCE(SUM(quantity), 0)").Scan(&total).Error
+	return total, err
+}
+
+func (s *inventoryService) QuantityByName() ([]NameQuantity, error) {
+	var result []NameQuantity
+	err := s.db.Model(&models.OtopProducts{}).
+		Select("name as product_name, SUM(quantity) as total_quantity").
+		Group("name").
+		Scan(&result).Error
+	return result, err
+}
+
+func (s *inventoryService) DistinctProductCount() (int64, error) {
+	var count int64
+	err := s.db.Model(&models.OtopProducts{}).Select("COUNT(DISTINCT name)").Scan(&count).Error
+	return count, err
+}
+
+func (s *inventoryService) CountByCategory() (map[string]int64, error) {
+	counts := map[string]int64{}
+	for _, category := range []string{"Food", "Non-Food"} {
+		var count int64
+		if err := s.db.Model(&models.OtopProducts{}).Where("category = ?", category).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		counts[category] = count
+	}
+	return counts, nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/m/models"
+	"github.com/m/testutil"
+)
+
+func TestFailedStockingLeavesThePurchaseCount(t *testing.T) {
+	db := testutil.NewDB(t)
+	supplier := models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com"}
+	if err := db.Create(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	inventory := NewInventory(db)
+
+	_, _, err := inventory.Create(testActor, models.OtopProducts{Name: "Ube Jam", Description: "jar", Category: "Toys", StoreName: supplier.StoreName})
+	if err == nil {
+		t.Fatal("stocked a product with an invalid category")
+	}
+	if err := db.First(&supplier, supplier.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if supplier.Purchased != 0 {
+		t.Errorf("purchased = %d after a failed insert, want 0", supplier.Purchased)
+	}
+
+	_, change, err := inventory.Create(testActor, models.OtopProducts{Name: "Ube Jam", Description: "jar", Category: "Food", StoreName: supplier.StoreName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.Before.Purchased != 0 || change.After.Purchased != 1 {
+		t.Errorf("supplier change = %d -> %d, want 0 -> 1", change.Before.Purchased, change.After.Purchased)
+	}
+}
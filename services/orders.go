@@ -0,0 +1,234 @@
+package services
+
+import (
+	"time"
+
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+const (
+	OrderPending  = "pending"
+	OrderVerified = "verified"
+)
+
+// ProductChange is a catalog product before and after an order took stock
+// from it.
+type ProductChange struct {
+	Before models.Product
+	After  models.Product
+}
+
+// OrderChange is an order before and after an update, plus the catalog stock
+// it consumed, if any.
+type OrderChange struct {
+	Before  models.Order
+	After   models.Order
+	Product *ProductChange
+}
+
+// Orders handles purchase orders placed by admins with suppliers.
+type Orders interface {
+	// Create places a pending order with the supplier of the catalog
+	// product, priced from that product.
+	Create(actor audit.Actor, order models.Order) (models.Order, error)
+	List(opts listing.Options) (listing.Page[models.Order], error)
+	ListBySupplier(supplierID uint, opts listing.Options) (listing.Page[models.Order], error)
+	Get(id uint) (models.Order, error)
+	// Update saves an edited order and takes its quantity from the catalog
+	// product's stock.
+	Update(actor audit.Actor, order models.Order) (OrderChange, error)
+	Delete(actor audit.Actor, id uint) (models.Order, error)
+	// Confirm marks a pending order verified on behalf of its supplier.
+	Confirm(actor audit.Actor, id, supplierID uint) (OrderChange, error)
+	// ConfirmAndDeduct is Confirm that also takes the ordered quantity from
+	// the catalog product's stock.
+	ConfirmAndDeduct(actor audit.Actor, id, supplierID uint) (OrderChange, error)
+}
+
+type ordersService struct {
+	db *gorm.DB
+}
+
+func NewOrders(db *gorm.DB) Orders {
+	return &ordersService{db: db}
+}
+
+// deductProductStock takes quantity off a supplier catalog product with the
+// same conditional UPDATE as deductStock, so concurrent orders can neither
+// oversell nor lose each other's changes. Run it inside the order's
+// transaction; the change is audited there too.
+func deductProductStock(db *gorm.DB, actor audit.Actor, productID uint, quantity int64) (ProductChange, error) {
+	res := db.Model(&models.Product{}).
+		Where("id = ? AND quantity >= ?", productID, quantity).
+		UpdateColumn("quantity", gorm.Expr("quantity - ?", quantity))
+	if res.Error != nil {
+		return ProductChange{}, res.Error
+	}
+
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return ProductChange{}, notFound(err, "product", productID)
+	}
+	if res.RowsAffected == 0 {
+		return ProductChange{}, &InsufficientStockError{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Available: product.Quantity,
+			Requested: quantity,
+		}
+	}
+
+	before := product
+	before.Quantity += quantity
+	if err := audit.RecordTx(db, actor, audit.ActionUpdate, "product", product.ID, before, product); err != nil {
+		return ProductChange{}, err
+	}
+	return ProductChange{Before: before, After: product}, nil
+}
+
+func (s *ordersService) Create(actor audit.Actor, order models.Order) (models.Order, error) {
+	order.Status = OrderPending
+	order.OrderDate = time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, order.ProductID).Error; err != nil {
+			return notFound(err, "product", order.ProductID)
+		}
+		if product.Quantity < order.Quantity {
+			return &InsufficientStockError{ProductID: product.ID, Name: product.Name, Available: product.Quantity, Requested: order.Quantity}
+		}
+
+		order.SupplierID = product.SupplierID
+		order.ProductName = product.Name
+		order.Price = product.Price
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "order", order.ID, nil, order)
+	})
+	return order, err
+}
+
+// OrderListing is how orders can be filtered and sorted.
+var OrderListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"status":      {Column: "status"},
+		"supplier_id": {Column: "supplier_id", Kind: listing.Int},
+		"product_id":  {Column: "product_id", Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"order_date": "order_date",
+		"quantity":   "quantity",
+		"price":      "price",
+	},
+	DefaultSort: "-order_date",
+	DateColumn:  "order_date",
+}
+
+func (s *ordersService) List(opts listing.Options) (listing.Page[models.Order], error) {
+	return listing.Find[models.Order](s.db, opts)
+}
+
+func (s *ordersService) ListBySupplier(supplierID uint, opts listing.Options) (listing.Page[models.Order], error) {
+	return listing.Find[models.Order](s.db, opts.Scope("supplier_id", supplierID))
+}
+
+func (s *ordersService) Get(id uint) (models.Order, error) {
+	var order models.Order
+	err := s.db.First(&order, id).Error
+	return order, notFound(err, "order", id)
+}
+
+func (s *ordersService) Update(actor audit.Actor, order models.Order) (OrderChange, error) {
+	var change OrderChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Order
+		if err := tx.First(&before, order.ID).Error; err != nil {
+			return notFound(err, "order", order.ID)
+		}
+		change.Before = before
+
+		productChange, err := deductProductStock(tx, actor, order.ProductID, order.Quantity)
+		if err != nil {
+			return err
+		}
+		change.Product = &productChange
+
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		change.After = order
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "order", order.ID, change.Before, change.After)
+	})
+	return change, err
+}
+
+func (s *ordersService) Delete(actor audit.Actor, id uint) (models.Order, error) {
+	var order models.Order
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&order, id).Error; err != nil {
+			return notFound(err, "order", id)
+		}
+		if err := tx.Delete(&order).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionDelete, "order", order.ID, order, nil)
+	})
+	return order, err
+}
+
+func (s *ordersService) Confirm(actor audit.Actor, id, supplierID uint) (OrderChange, error) {
+	return s.confirm(actor, id, supplierID, false)
+}
+
+func (s *ordersService) ConfirmAndDeduct(actor audit.Actor, id, supplierID uint) (OrderChange, error) {
+	return s.confirm(actor, id, supplierID, true)
+}
+
+func (s *ordersService) confirm(actor audit.Actor, id, supplierID uint, deduct bool) (OrderChange, error) {
+	var change OrderChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.First(&order, id).Error; err != nil {
+			return notFound(err, "order", id)
+		}
+		change.Before = order
+
+		if order.Status != OrderPending {
+			return ErrOrderNotPending
+		}
+		if order.SupplierID != supplierID {
+			return ErrNotOrderSupplier
+		}
+
+		// Only the first of two concurrent confirmations flips the status
+		res := tx.Model(&models.Order{}).Where("id = ? AND status = ?", id, OrderPending).
+			Updates(map[string]interface{}{"status": OrderVerified, "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrOrderNotPending
+		}
+
+		if deduct {
+			productChange, err := deductProductStock(tx, actor, order.ProductID, order.Quantity)
+			if err != nil {
+				return err
+			}
+			change.Product = &productChange
+		}
+
+		if err := tx.First(&change.After, id).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "order", id, change.Before, change.After)
+	})
+	return change, err
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+func TestSerializedOrderConfirmationsNeverOversell(t *testing.T) {
+	confirmRace(t, testutil.NewDB(t))
+}
+
+func TestConcurrentOrderConfirmationsNeverOversell(t *testing.T) {
+	confirmRace(t, testutil.NewPostgresDB(t))
+}
+
+// confirmRace has a supplier confirm four orders of 2 against a catalog
+// stock of 5, each twice at once, and checks exactly two went through, each
+// deducting once.
+func confirmRace(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	supplier := models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com"}
+	if err := db.Create(&supplier).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{Name: "Pili Nuts", Price: 50, Quantity: 5, Category: "Food", SupplierID: supplier.ID}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	orders := NewOrders(db)
+	ids := make([]uint, 4)
+	for i := range ids {
+		order, err := orders.Create(testActor, models.Order{ProductID: product.ID, Quantity: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = order.ID
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2*len(ids))
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = orders.ConfirmAndDeduct(testActor, ids[i/2], supplier.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	confirmed := 0
+	for _, err := range errs {
+		var insufficient *InsufficientStockError
+		switch {
+		case err == nil:
+			confirmed++
+		case !errors.As(err, &insufficient) && !errors.Is(err, ErrOrderNotPending):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if confirmed != 2 {
+		t.Errorf("confirmed %d orders, want 2", confirmed)
+	}
+	if err := db.First(&product, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if product.Quantity != 1 {
+		t.Errorf("stock = %d, want 1", product.Quantity)
+	}
+	var verified int64
+	db.Model(&models.Order{}).Where("status = ?", OrderVerified).Count(&verified)
+	if verified != 2 {
+		t.Errorf("%d orders verified, want 2", verified)
+	}
+}
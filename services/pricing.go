@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/m/models"
+)
+
+// VATRate is the Philippine value-added tax. Shelf prices include it.
+const VATRate = 0.12
+
+// StatutoryDiscountRate is the senior citizen and PWD discount. It is taken
+// off the price without VAT, as those sales are also VAT exempt.
+const StatutoryDiscountRate = 0.20
+
+// SalesRules are the pricing rules that vary by hub.
+type SalesRules struct {
+	// DiscountCategories are the product categories the senior citizen and
+	// PWD discount applies to.
+	DiscountCategories []string
+
+	// Categories sold without VAT. A product's own VAT class wins over these.
+	VATExemptCategories []string
+	ZeroRatedCategories []string
+}
+
+// vatClass is the product's VAT class, falling back to its category's.
+func (r SalesRules) vatClass(product models.OtopProducts) string {
+	switch {
+	case product.VATClass != "" && product.VATClass != models.VATByCategory:
+		return product.VATClass
+	case slices.Contains(r.VATExemptCategories, product.Category):
+		return models.VATExempt
+	case slices.Contains(r.ZeroRatedCategories, product.Category):
+		return models.VATZeroRated
+	default:
+		return models.VATVatable
+	}
+}
+
+// DefaultSalesRules discounts food only, matching the configuration default.
+func DefaultSalesRules() SalesRules {
+	return SalesRules{DiscountCategories: []string{"Food"}}
+}
+
+// DiscountClaim is a senior citizen or PWD discount asked for at the till,
+// with the ID it was checked against.
+type DiscountClaim struct {
+	Type     string // models.DiscountSenior or models.DiscountPWD
+	IDNumber string
+	Name     string
+}
+
+// Bill is what the server charges for a sale. The till's own figures are
+// only ever checked against it, never trusted.
+//
+// The VAT breakdown adds up to the total before discount:
+// VatableSales + VAT + VATExemptSales + ZeroRatedSales - Discount = Total.
+type Bill struct {
+	Lines    []BillLine
+	Subtotal float64 // sum of the lines at shelf prices
+	Discount float64
+	Total    float64 // subtotal less VAT exemption and discount; what the customer pays
+	Received float64 // every tender added up
+	Change   float64 // given back from the cash tendered
+	Payments []models.Payment
+
+	VatableSales   float64 // vatable lines net of VAT
+	VAT            float64 // VAT included in Total
+	VATExemptSales float64 // exempt lines, and senior/PWD lines net of VAT
+	ZeroRatedSales float64
+
+	Statutory models.StatutoryDiscount
+}
+
+// Tender is one payment as sent by the till.
+type Tender struct {
+	Method    string // one of models.Tenders
+	Amount    float64
+	Reference string
+}
+
+// BillLine is one cart line priced from the product row.
+type BillLine struct {
+	ProductID uint
+	Name      string
+	Quantity  int64
+	UnitPrice float64
+	Total     float64 // at the shelf price
+	VATClass  string  // models.VATVatable, VATExempt or VATZeroRated
+	VAT       float64 // included in Total
+
+	// Set on lines the senior citizen or PWD discount covers
+	VATExemption float64
+	Discount     float64
+}
+
+// Mismatch is one figure the till sent that disagrees with the server's.
+type Mismatch struct {
+	Field    string // request field, e.g. "items[0].price"
+	Sent     float64
+	Expected float64
+}
+
+// PriceMismatchError is returned when the till's cart is priced differently
+// from the server's, usually because a price changed after the cart was
+// built.
+type PriceMismatchError struct {
+	Mismatches []Mismatch
+}
+
+func (e *PriceMismatchError) Error() string {
+	return fmt.Sprintf("%d figure(s) differ from the server's pricing", len(e.Mismatches))
+}
+
+// roundCentavos rounds an amount in pesos to the centavo.
+func roundCentavos(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// priceCart bills lines at the prices in changes, the product rows the sale
+// has locked, applies any senior citizen or PWD discount to the categories
+// rules allow, and checks the bill against what the till sent.
+//
+// Shelf prices include VAT on vatable goods. Discounted vatable lines lose
+// their VAT before the 20% comes off, and are then sold as exempt; exempt and
+// zero-rated lines have no VAT to lose, so the 20% comes off the shelf price.
+func priceCart(in CheckoutInput, changes []StockChange, rules SalesRules) (Bill, error) {
+	var bill Bill
+	var mismatches []Mismatch
+	check := func(field string, sent *float64, expected float64) {
+		if sent != nil && roundCentavos(*sent) != expected {
+			mismatches = append(mismatches, Mismatch{Field: field, Sent: *sent, Expected: expected})
+		}
+	}
+
+	for i, item := range in.Items {
+		product := changes[i].After
+		line := BillLine{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: roundCentavos(product.Price),
+			VATClass:  rules.vatClass(product),
+		}
+		line.Total = roundCentavos(line.UnitPrice * float64(item.Quantity))
+		check(fmt.Sprintf("items[%d].price", i), item.Price, line.UnitPrice)
+		check(fmt.Sprintf("items[%d].total", i), item.Total, line.Total)
+
+		discounted := in.Discount != nil && slices.Contains(rules.DiscountCategories, product.Category)
+		switch {
+		case line.VATClass == models.VATVatable && discounted:
+			exclusive := roundCentavos(line.Total / (1 + VATRate))
+			line.VATExemption = roundCentavos(line.Total - exclusive)
+			line.Discount = roundCentavos(exclusive * StatutoryDiscountRate)
+			bill.VATExemptSales += exclusive
+		case line.VATClass == models.VATVatable:
+			line.VAT = roundCentavos(line.Total * VATRate / (1 + VATRate))
+			bill.VatableSales += line.Total - line.VAT
+			bill.VAT += line.VAT
+		case line.VATClass == models.VATZeroRated:
+			bill.ZeroRatedSales += line.Total
+		default:
+			bill.VATExemptSales += line.Total
+		}
+		if discounted {
+			if line.VATClass != models.VATVatable {
+				line.Discount = roundCentavos(line.Total * StatutoryDiscountRate)
+			}
+			bill.Statutory.EligibleSales += line.Total
+			bill.Statutory.VATExemption += line.VATExemption
+			bill.Discount += line.Discount
+		}
+
+		bill.Lines = append(bill.Lines, line)
+		bill.Subtotal += line.Total
+	}
+
+	if in.Discount != nil {
+		if bill.Statutory.EligibleSales == 0 {
+			return bill, ErrNothingDiscountable
+		}
+		bill.Statutory.Type = in.Discount.Type
+		bill.Statutory.IDNumber = in.Discount.IDNumber
+		bill.Statutory.Name = in.Discount.Name
+		bill.Statutory.EligibleSales = roundCentavos(bill.Statutory.EligibleSales)
+		bill.Statutory.VATExemption = roundCentavos(bill.Statutory.VATExemption)
+	}
+
+	bill.Subtotal = roundCentavos(bill.Subtotal)
+	bill.Discount = roundCentavos(bill.Discount)
+	bill.Total = roundCentavos(bill.Subtotal - bill.Statutory.VATExemption - bill.Discount)
+	bill.VatableSales = roundCentavos(bill.VatableSales)
+	bill.VAT = roundCentavos(bill.VAT)
+	bill.VATExemptSales = roundCentavos(bill.VATExemptSales)
+	bill.ZeroRatedSales = roundCentavos(bill.ZeroRatedSales)
+	check("total", in.Total, bill.Total)
+	if len(mismatches) > 0 {
+		return bill, &PriceMismatchError{Mismatches: mismatches}
+	}
+
+	if err := bill.settle(in.Payments); err != nil {
+		return bill, err
+	}
+	check("change", in.Change, bill.Change)
+	if len(mismatches) > 0 {
+		return bill, &PriceMismatchError{Mismatches: mismatches}
+	}
+	return bill, nil
+}
+
+// settle records the tenders against the bill. They must cover the total,
+// and since only cash is given change, card and wallet payments together
+// may not exceed it. The change comes off the last cash tender.
+func (b *Bill) settle(tenders []Tender) error {
+	var received, nonCash float64
+	lastCash := -1
+	b.Payments = make([]models.Payment, 0, len(tenders))
+	for i, t := range tenders {
+		amount := roundCentavos(t.Amount)
+		received += amount
+		if t.Method == models.TenderCash {
+			lastCash = i
+		} else {
+			nonCash += amount
+		}
+		b.Payments = append(b.Payments, models.Payment{Method: t.Method, Amount: amount, Reference: t.Reference})
+	}
+	b.Received = roundCentavos(received)
+
+	if roundCentavos(nonCash) > b.Total {
+		return ErrNonCashOverpayment
+	}
+	if b.Received < b.Total {
+		return ErrInsufficientPayment
+	}
+	b.Change = roundCentavos(b.Received - b.Total)
+
+	// Cash covers at least the change, as non-cash tenders stop at the total
+	owed := b.Change
+	for i := lastCash; i >= 0 && owed > 0; i-- {
+		if b.Payments[i].Method != models.TenderCash {
+			continue
+		}
+		given := math.Min(owed, b.Payments[i].Amount)
+		b.Payments[i].Change = given
+		owed = roundCentavos(owed - given)
+	}
+	return nil
+}
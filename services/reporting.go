@@ -0,0 +1,461 @@
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+type ProductSales struct {
+	ProductID    uint    `json:"product_id"`
+	Name         string  `json:"name"`
+	QuantitySold int     `json:"quantity_sold"`
+	Price        float64 `json:"price"`
+	TotalAmount  float64 `json:"total_amount"`
+}
+
+type StoreNameCount struct {
+	StoreName string `json:"store_name"`
+	Count     int64  `json:"count"`
+}
+
+type SupplierProductCount struct {
+	StoreName    string `json:"store_name"`
+	ProductCount int64  `json:"product_count"`
+}
+
+type SupplierPurchaseCount struct {
+	StoreName     string `json:"store_name"`
+	Email         string `json:"email,omitempty"`
+	PurchaseCount int64  `json:"purchase_count"`
+}
+
+// DiscountRegisterEntry is one sale in the senior citizen and PWD discount
+// register.
+type DiscountRegisterEntry struct {
+	TransactionID uint      `json:"transaction_id"`
+	Date          time.Time `json:"date"`
+	Type          string    `json:"type"`
+	IDNumber      string    `json:"id_number"`
+	Name          string    `json:"name"`
+	CashierID     uint      `json:"cashier_id"`
+	EligibleSales float64   `json:"eligible_sales"` // shelf value of the discounted items
+	VATExemption  float64   `json:"vat_exemption"`
+	Discount      float64   `json:"discount"`
+	NetSales      float64   `json:"net_sales"` // what the customer paid for those items
+}
+
+// DiscountRegister is a month of senior citizen and PWD discounts, as kept
+// for compliance.
+type DiscountRegister struct {
+	Month   string                  `json:"month"` // YYYY-MM
+	Type    string                  `json:"type,omitempty"`
+	Entries []DiscountRegisterEntry `json:"entries"`
+	Totals  struct {
+		Count         int     `json:"count"`
+		EligibleSales float64 `json:"eligible_sales"`
+		VATExemption  float64 `json:"vat_exemption"`
+		Discount      float64 `json:"discount"`
+		NetSales      float64 `json:"net_sales"`
+	} `json:"totals"`
+}
+
+// VATBreakdown is the VAT analysis of POS sales. Total is what customers
+// paid: the four sales figures less the senior citizen and PWD discounts.
+type VATBreakdown struct {
+	VatableSales   float64 `json:"vatable_sales"`
+	VAT            float64 `json:"vat"`
+	VATExemptSales float64 `json:"vat_exempt_sales"`
+	ZeroRatedSales float64 `json:"zero_rated_sales"`
+	Discount       float64 `json:"discount"`
+	Total          float64 `json:"total"`
+}
+
+// add totals a transaction into the breakdown.
+func (b *VATBreakdown) add(t models.Transaction) {
+	b.VatableSales = roundCentavos(b.VatableSales + t.VatableSales)
+	b.VAT = roundCentavos(b.VAT + t.VAT)
+	b.VATExemptSales = roundCentavos(b.VATExemptSales + t.VATExemptSales)
+	b.ZeroRatedSales = roundCentavos(b.ZeroRatedSales + t.ZeroRatedSales)
+	b.Discount = roundCentavos(b.Discount + t.Discount)
+	b.Total = roundCentavos(b.Total + t.Total)
+}
+
+// addLine totals one sale line into the breakdown, split the way the sale
+// itself was.
+func (b *VATBreakdown) addLine(item models.TransactionItem) {
+	switch {
+	case item.VATClass == models.VATVatable && item.VATExemption > 0:
+		b.VATExemptSales = roundCentavos(b.VATExemptSales + item.Total - item.VATExemption)
+	case item.VATClass == models.VATVatable:
+		b.VatableSales = roundCentavos(b.VatableSales + item.Total - item.VAT)
+		b.VAT = roundCentavos(b.VAT + item.VAT)
+	case item.VATClass == models.VATZeroRated:
+		b.ZeroRatedSales = roundCentavos(b.ZeroRatedSales + item.Total)
+	default:
+		b.VATExemptSales = roundCentavos(b.VATExemptSales + item.Total)
+	}
+	b.Discount = roundCentavos(b.Discount + item.Discount)
+	b.Total = roundCentavos(b.Total + item.Total - item.VATExemption - item.Discount)
+}
+
+// Reporting answers the dashboard and sales summary queries.
+type Reporting interface {
+	// SalesSummary buckets sold item amounts for interval (daily, weekly,
+	// monthly or yearly) around now. Every figure comes from the sold items;
+	// TenderSummary and VATSummary report the POS sales.
+	SalesSummary(interval string, now time.Time) (map[string]float64, error)
+	// SupplierSalesSummary is SalesSummary for one supplier's items, at the
+	// amounts they were sold for.
+	SupplierSalesSummary(interval string, supplierID uint, now time.Time) (map[string]float64, error)
+	// TenderSummary buckets POS takings like SalesSummary, split by tender
+	// type and net of change.
+	TenderSummary(interval string, now time.Time) (map[string]map[string]float64, error)
+	// VATSummary buckets POS sales like SalesSummary, split into vatable,
+	// VAT, exempt and zero-rated sales.
+	VATSummary(interval string, now time.Time) (map[string]VATBreakdown, error)
+	// SupplierVATSummary is VATSummary for one supplier's lines in POS
+	// sales.
+	SupplierVATSummary(interval string, supplierID uint, now time.Time) (map[string]VATBreakdown, error)
+	// DiscountRegister lists the month's senior citizen and PWD discounts,
+	// of discountType only when it is set.
+	DiscountRegister(month time.Time, discountType string) (DiscountRegister, error)
+	TopSoldProducts(limit int) ([]ProductSales, error)
+	SupplierCountsByStoreName() ([]StoreNameCount, error)
+	SupplierProductCounts() ([]SupplierProductCount, error)
+	// SupplierPurchaseCounts counts orders per supplier, busiest first when
+	// limit is above zero.
+	SupplierPurchaseCounts(limit int) ([]SupplierPurchaseCount, error)
+	SupplierPurchasesByID(supplierID uint) (SupplierPurchaseCount, error)
+	TotalPurchasedBySupplier(supplierID string) (float64, error)
+}
+
+type reportingService struct {
+	db *gorm.DB
+}
+
+func NewReporting(db *gorm.DB) Reporting {
+	return &reportingService{db: db}
+}
+
+// summaryBuckets returns the window an interval covers, the empty buckets it
+// reports, and which bucket a sale time falls into ("" to skip it).
+func summaryBuckets(interval string, now time.Time) (from, to time.Time, buckets map[string]float64, bucket func(time.Time) string, err error) {
+	switch interval {
+	case "daily":
+		// Monday to Saturday of the current week
+		if now.Weekday() == time.Sunday {
+			now = now.AddDate(0, 0, -6)
+		}
+		start := now.AddDate(0, 0, -(int(now.Weekday()) - 1))
+		from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+		to = from.AddDate(0, 0, 6)
+		buckets = map[string]float64{}
+		for day := time.Monday; day <= time.Saturday; day++ {
+			buckets[day.String()] = 0
+		}
+		bucket = func(t time.Time) string {
+			if t.Weekday() == time.Sunday {
+				return ""
+			}
+			return t.Weekday().String()
+		}
+	case "weekly":
+		// Weeks of the current month, counted from the 1st
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		buckets = map[string]float64{"Week 1": 0, "Week 2": 0, "Week 3": 0, "Week 4": 0, "Week 5": 0}
+		bucket = func(t time.Time) string {
+			week := (t.Day()-1)/7 + 1
+			if week > 5 {
+				week = 5
+			}
+			return "Week " + strconv.Itoa(week)
+		}
+	case "monthly":
+		// Months of the current year
+		from = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		to = from.AddDate(1, 0, 0)
+		buckets = map[string]float64{}
+		for month := time.January; month <= time.December; month++ {
+			buckets[month.String()] = 0
+		}
+		bucket = func(t time.Time) string { return t.Month().String() }
+	case "yearly":
+		// The last four years and the current one
+		startYear := now.Year() - 4
+		from = time.Date(startYear, time.January, 1, 0, 0, 0, 0, now.Location())
+		buckets = map[string]float64{}
+		for year := startYear; year <= now.Year(); year++ {
+			buckets[strconv.Itoa(year)] = 0
+		}
+		bucket = func(t time.Time) string { return strconv.Itoa(t.Year()) }
+	default:
+		err = ErrInvalidInterval
+	}
+	return
+}
+
+func (s *reportingService) SalesSummary(interval string, now time.Time) (map[string]float64, error) {
+	from, to, buckets, bucket, err := summaryBuckets(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Preload("Product").Where("created_at >= ?", from)
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	var items []models.SoldItems
+	if err := query.Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if key := bucket(item.CreatedAt); key != "" {
+			if _, ok := buckets[key]; ok {
+				buckets[key] += float64(item.QuantitySold) * item.Product.Price
+			}
+		}
+	}
+	return buckets, nil
+}
+
+func (s *reportingService) SupplierSalesSummary(interval string, supplierID uint, now time.Time) (map[string]float64, error) {
+	from, to, buckets, bucket, err := summaryBuckets(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Preload("Product").Where("sold_date >= ? AND supplier_id = ?", from, supplierID)
+	if !to.IsZero() {
+		query = query.Where("sold_date < ?", to)
+	}
+	var items []models.SoldItems
+	if err := query.Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if key := bucket(item.SoldDate); key != "" {
+			if _, ok := buckets[key]; ok {
+				// TotalAmount already covers every unit sold
+				buckets[key] += item.TotalAmount
+			}
+		}
+	}
+	return buckets, nil
+}
+
+func (s *reportingService) SupplierVATSummary(interval string, supplierID uint, now time.Time) (map[string]VATBreakdown, error) {
+	from, to, buckets, bucket, err := summaryBuckets(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&models.TransactionItem{}).
+		Select("transactions.created_at, transaction_items.vat_class, transaction_items.vat, transaction_items.vat_exemption, transaction_items.discount, transaction_items.total").
+		Joins("JOIN transactions ON transactions.id = transaction_items.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transaction_items.supplier_id = ? AND transactions.created_at >= ?", supplierID, from)
+	if !to.IsZero() {
+		query = query.Where("transactions.created_at < ?", to)
+	}
+	// CreatedAt is the sale's time, not the line's
+	var lines []models.TransactionItem
+	if err := query.Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]VATBreakdown, len(buckets))
+	for key := range buckets {
+		summary[key] = VATBreakdown{}
+	}
+	for _, line := range lines {
+		key := bucket(line.CreatedAt)
+		if breakdown, ok := summary[key]; ok {
+			breakdown.addLine(line)
+			summary[key] = breakdown
+		}
+	}
+	return summary, nil
+}
+
+func (s *reportingService) TenderSummary(interval string, now time.Time) (map[string]map[string]float64, error) {
+	from, to, buckets, bucket, err := summaryBuckets(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Table("transaction_payments").
+		Select("transactions.created_at, transaction_payments.method, transaction_payments.amount - transaction_payments.change AS amount").
+		Joins("JOIN transactions ON transactions.id = transaction_payments.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transactions.created_at >= ?", from)
+	if !to.IsZero() {
+		query = query.Where("transactions.created_at < ?", to)
+	}
+	var payments []struct {
+		CreatedAt time.Time
+		Method    string
+		Amount    float64
+	}
+	if err := query.Scan(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]map[string]float64, len(buckets))
+	for key := range buckets {
+		summary[key] = make(map[string]float64, len(models.Tenders))
+		for _, method := range models.Tenders {
+			summary[key][method] = 0
+		}
+	}
+	for _, p := range payments {
+		if tenders, ok := summary[bucket(p.CreatedAt)]; ok {
+			tenders[p.Method] += p.Amount
+		}
+	}
+	return summary, nil
+}
+
+func (s *reportingService) VATSummary(interval string, now time.Time) (map[string]VATBreakdown, error) {
+	from, to, buckets, bucket, err := summaryBuckets(interval, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&models.Transaction{}).
+		Select("created_at, vatable_sales, vat, vat_exempt_sales, zero_rated_sales, discount, total").
+		Where("created_at >= ?", from)
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	var transactions []models.Transaction
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	summary := make(map[string]VATBreakdown, len(buckets))
+	for key := range buckets {
+		summary[key] = VATBreakdown{}
+	}
+	for _, t := range transactions {
+		key := bucket(t.CreatedAt)
+		if breakdown, ok := summary[key]; ok {
+			breakdown.add(t)
+			summary[key] = breakdown
+		}
+	}
+	return summary, nil
+}
+
+func (s *reportingService) DiscountRegister(month time.Time, discountType string) (DiscountRegister, error) {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	register := DiscountRegister{Month: from.Format("2006-01"), Type: discountType, Entries: []DiscountRegisterEntry{}}
+
+	query := s.db.Model(&models.Transaction{}).
+		Where("statutory_type <> '' AND created_at >= ? AND created_at < ?", from, from.AddDate(0, 1, 0)).
+		Order("created_at, id")
+	if discountType != "" {
+		query = query.Where("statutory_type = ?", discountType)
+	}
+	var transactions []models.Transaction
+	if err := query.Find(&transactions).Error; err != nil {
+		return register, err
+	}
+
+	for _, t := range transactions {
+		entry := DiscountRegisterEntry{
+			TransactionID: t.ID,
+			Date:          t.CreatedAt,
+			Type:          t.Statutory.Type,
+			IDNumber:      t.Statutory.IDNumber,
+			Name:          t.Statutory.Name,
+			CashierID:     t.CashierID,
+			EligibleSales: t.Statutory.EligibleSales,
+			VATExemption:  t.Statutory.VATExemption,
+			Discount:      t.Discount,
+		}
+		entry.NetSales = roundCentavos(entry.EligibleSales - entry.VATExemption - entry.Discount)
+		register.Entries = append(register.Entries, entry)
+
+		register.Totals.Count++
+		register.Totals.EligibleSales += entry.EligibleSales
+		register.Totals.VATExemption += entry.VATExemption
+		register.Totals.Discount += entry.Discount
+		register.Totals.NetSales += entry.NetSales
+	}
+	register.Totals.EligibleSales = roundCentavos(register.Totals.EligibleSales)
+	register.Totals.VATExemption = roundCentavos(register.Totals.VATExemption)
+	register.Totals.Discount = roundCentavos(register.Totals.Discount)
+	register.Totals.NetSales = roundCentavos(register.Totals.NetSales)
+	return register, nil
+}
+
+func (s *reportingService) TopSoldProducts(limit int) ([]ProductSales, error) {
+	var top []ProductSales
+	err := s.db.Table("sold_items").
+		Select("sold_items.product_id, products.name, products.price, SUM(sold_items.quantity_sold) as quantity_sold, SUM(sold_items.quantity_sold * products.price) as total_amount").
+		Joins("JOIN products ON products.id = sold_items.product_id").
+		Group("sold_items.product_id, products.name, products.price").
+		Order("quantity_sold DESC").
+		Limit(limit).
+		Scan(&top).Error
+	return top, err
+}
+
+func (s *reportingService) SupplierCountsByStoreName() ([]StoreNameCount, error) {
+	var results []StoreNameCount
+	err := s.db.Model(&models.Supplier{}).
+		Select("store_name, COUNT(*) as count").
+		Group("store_name").
+		Scan(&results).Error
+	return results, err
+}
+
+func (s *reportingService) SupplierProductCounts() ([]SupplierProductCount, error) {
+	var results []SupplierProductCount
+	err := s.db.Model(&models.Product{}).
+		Select("suppliers.store_name, COUNT(products.id) as product_count").
+		Joins("JOIN suppliers ON suppliers.id = products.supplier_id").
+		Group("suppliers.store_name").
+		Scan(&results).Error
+	return results, err
+}
+
+func (s *reportingService) SupplierPurchaseCounts(limit int) ([]SupplierPurchaseCount, error) {
+	query := s.db.Model(&models.Supplier{}).
+		Select("suppliers.store_name, COUNT(orders.id) as purchase_count").
+		Joins("LEFT JOIN orders ON orders.supplier_id = suppliers.id").
+		Group("suppliers.id")
+	if limit > 0 {
+		query = query.Order("purchase_count DESC").Limit(limit)
+	}
+
+	var results []SupplierPurchaseCount
+	err := query.Scan(&results).Error
+	return results, err
+}
+
+func (s *reportingService) SupplierPurchasesByID(supplierID uint) (SupplierPurchaseCount, error) {
+	var result SupplierPurchaseCount
+	err := s.db.Model(&models.Supplier{}).
+		Select("suppliers.store_name, suppliers.email, COUNT(orders.id) as purchase_count").
+		Joins("LEFT JOIN orders ON orders.supplier_id = suppliers.id").
+		Where("suppliers.id = ?", supplierID).
+		Group("suppliers.id").
+		Scan(&result).Error
+	if err == nil && result.StoreName == "" {
+		err = &NotFoundError{Entity: "supplier", ID: supplierID}
+	}
+	return result, err
+}
+
+func (s *reportingService) TotalPurchasedBySupplier(supplierID string) (float64, error) {
+	var total float64
+	err := s.db.Model(&models.OtopProducts{}).
+		Where("supplier_id = ?", supplierID).
+		Select("SUM(price * quantity * purchased)").
+		Scan(&total).Error
+	return total, err
+}
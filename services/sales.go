@@ -0,0 +1,282 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckoutItem is one cart line as sent by the till. Price and Total are
+// what the till showed; when sent they must match the server's pricing.
+type CheckoutItem struct {
+	ProductID uint
+	Quantity  int64
+	Price     *float64
+	Total     *float64
+}
+
+// CheckoutInput is a sale as sent by the till. Total and Change, when sent,
+// are checked against the server's figures like the line prices. Without
+// Payments, Received is taken as a single cash payment.
+type CheckoutInput struct {
+	CashierID uint
+	Items     []CheckoutItem
+	Payments  []Tender
+	Discount  *DiscountClaim
+	Received  float64
+	Total     *float64
+	Change    *float64
+}
+
+// CheckoutResult is the saved sale with the server's bill and the stock it
+// took.
+type CheckoutResult struct {
+	Transaction  models.Transaction
+	Bill         Bill
+	Items        []models.TransactionItem
+	StockChanges []StockChange
+}
+
+// SoldItemResult is one recorded sold item with its product and supplier
+// loaded.
+type SoldItemResult struct {
+	Item  models.SoldItems
+	Stock StockChange
+}
+
+// SoldItemListing is how sold items can be filtered and sorted. The date
+// range applies to when the item was recorded.
+var SoldItemListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"supplier_id": {Column: "supplier_id", Kind: listing.Int},
+		"product_id":  {Column: "product_id", Kind: listing.Int},
+	},
+	Sorts: map[string]string{
+		"id":           "id",
+		"created_at":   "created_at",
+		"quantity":     "quantity_sold",
+		"total_amount": "total_amount",
+	},
+	DefaultSort: "-created_at",
+	DateColumn:  "created_at",
+}
+
+// Sales rings up sales at the POS.
+type Sales interface {
+	Checkout(actor audit.Actor, in CheckoutInput) (CheckoutResult, error)
+	RecordSoldItems(actor audit.Actor, items []models.SoldItems) ([]SoldItemResult, error)
+	ListSoldItems(opts listing.Options) (listing.Page[models.SoldItems], error)
+	// SoldAmount is the value of every sold item opts selects, at current
+	// product prices, ignoring paging.
+	SoldAmount(opts listing.Options) (float64, error)
+}
+
+type salesService struct {
+	db    *gorm.DB
+	rules SalesRules
+}
+
+func NewSales(db *gorm.DB, rules SalesRules) Sales {
+	return &salesService{db: db, rules: rules}
+}
+
+// FindOpenShift returns the cashier's open shift, or gorm.ErrRecordNotFound.
+func FindOpenShift(db *gorm.DB, cashierID uint) (models.Shift, error) {
+	var shift models.Shift
+	err := db.Where("cashier_id = ? AND status = ?", cashierID, models.ShiftOpen).First(&shift).Error
+	return shift, err
+}
+
+// LockOpenShift is FindOpenShift that also locks the shift row until tx ends.
+// Sales take a "SHARE" lock so they still ring up side by side; closing takes
+// "UPDATE", so it waits for sales in flight and later sales find no open
+// shift.
+func LockOpenShift(tx *gorm.DB, cashierID uint, strength string) (models.Shift, error) {
+	return FindOpenShift(tx.Clauses(clause.Locking{Strength: strength}), cashierID)
+}
+
+// byProductID returns the indexes of lines ordered by product ID. Sales lock
+// their product rows in this order, so two carts sharing products cannot
+// deadlock each other.
+func byProductID(n int, productID func(int) uint) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return productID(order[a]) < productID(order[b]) })
+	return order
+}
+
+// Checkout prices the cart from the product rows, saves the sale, its items
+// and supplier links and takes the stock in one database transaction: either
+// all of it happens or none of it does.
+func (s *salesService) Checkout(actor audit.Actor, in CheckoutInput) (CheckoutResult, error) {
+	if len(in.Payments) == 0 {
+		in.Payments = []Tender{{Method: models.TenderCash, Amount: in.Received}}
+	}
+
+	var result CheckoutResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result = CheckoutResult{}
+
+		// Every sale is rung up in the cashier's open shift
+		shift, err := LockOpenShift(tx, in.CashierID, "SHARE")
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrNoOpenShift
+			}
+			return err
+		}
+
+		changes := make([]StockChange, len(in.Items))
+		for _, i := range byProductID(len(in.Items), func(i int) uint { return in.Items[i].ProductID }) {
+			change, err := deductStock(tx, actor, in.Items[i].ProductID, in.Items[i].Quantity)
+			if err != nil {
+				return err
+			}
+			changes[i] = change
+		}
+
+		bill, err := priceCart(in, changes, s.rules)
+		if err != nil {
+			return err
+		}
+
+		supplierIDs := make(map[uint]bool) // unique suppliers of the items
+		var suppliers []uint               // the same, in cart order
+		for i, item := range in.Items {
+			if supplierID := changes[i].After.SupplierID; supplierID > 0 && !supplierIDs[supplierID] {
+				supplierIDs[supplierID] = true
+				suppliers = append(suppliers, supplierID)
+			}
+			result.Items = append(result.Items, models.TransactionItem{
+				ProductID:    item.ProductID,
+				Quantity:     item.Quantity,
+				Price:        bill.Lines[i].UnitPrice,
+				Total:        bill.Lines[i].Total,
+				VATClass:     bill.Lines[i].VATClass,
+				VAT:          bill.Lines[i].VAT,
+				VATExemption: bill.Lines[i].VATExemption,
+				Discount:     bill.Lines[i].Discount,
+				SupplierID:   changes[i].After.SupplierID,
+			})
+		}
+		if len(suppliers) == 0 {
+			return ErrNoSuppliers
+		}
+
+		// The transaction row keeps the first supplier; all of them are linked
+		// through transaction_suppliers
+		transaction := models.Transaction{
+			Subtotal:       bill.Subtotal,
+			Discount:       bill.Discount,
+			Statutory:      bill.Statutory,
+			VatableSales:   bill.VatableSales,
+			VAT:            bill.VAT,
+			VATExemptSales: bill.VATExemptSales,
+			ZeroRatedSales: bill.ZeroRatedSales,
+			Total:          bill.Total,
+			Received:       bill.Received,
+			Change:         bill.Change,
+			SupplierID:     suppliers[0],
+			ShiftID:        &shift.ID,
+			CashierID:      in.CashierID,
+			CreatedAt:      time.Now(),
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		for i := range result.Items {
+			result.Items[i].TransactionID = transaction.ID
+		}
+		if err := tx.Create(&result.Items).Error; err != nil {
+			return err
+		}
+		for i := range bill.Payments {
+			bill.Payments[i].TransactionID = transaction.ID
+			bill.Payments[i].CreatedAt = transaction.CreatedAt
+		}
+		if err := tx.Create(&bill.Payments).Error; err != nil {
+			return err
+		}
+		transaction.Payments = bill.Payments
+
+		links := make([]models.TransactionSupplier, 0, len(suppliers))
+		for _, supplierID := range suppliers {
+			links = append(links, models.TransactionSupplier{TransactionID: transaction.ID, SupplierID: supplierID})
+		}
+		if err := tx.Create(&links).Error; err != nil {
+			return err
+		}
+
+		result.Transaction = transaction
+		result.Bill = bill
+		result.StockChanges = changes
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "transaction", transaction.ID, nil, transaction)
+	})
+	if err != nil {
+		return CheckoutResult{}, err
+	}
+	return result, nil
+}
+
+// RecordSoldItems records every item and takes its stock in one database
+// transaction, so a failure on any item leaves stock untouched.
+func (s *salesService) RecordSoldItems(actor audit.Actor, items []models.SoldItems) ([]SoldItemResult, error) {
+	var results []SoldItemResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		results = make([]SoldItemResult, len(items))
+
+		changes := make([]StockChange, len(items))
+		for _, i := range byProductID(len(items), func(i int) uint { return items[i].ProductID }) {
+			change, err := deductStock(tx, actor, items[i].ProductID, items[i].QuantitySold)
+			if err != nil {
+				return err
+			}
+			changes[i] = change
+		}
+
+		for i, item := range items {
+			item.TotalAmount = float64(item.QuantitySold) * changes[i].Before.Price
+			item.SoldDate = time.Now()
+			item.SupplierID = changes[i].Before.SupplierID
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+
+			// Reload the sold item with its product and supplier
+			var full models.SoldItems
+			if err := tx.Preload("Product").Preload("Product.Supplier").First(&full, item.ID).Error; err != nil {
+				return err
+			}
+			if err := audit.RecordTx(tx, actor, audit.ActionCreate, "sold_item", full.ID, nil, full); err != nil {
+				return err
+			}
+			results[i] = SoldItemResult{Item: full, Stock: changes[i]}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *salesService) ListSoldItems(opts listing.Options) (listing.Page[models.SoldItems], error) {
+	return listing.Find[models.SoldItems](s.db, opts, "Product")
+}
+
+func (s *salesService) SoldAmount(opts listing.Options) (float64, error) {
+	var amount float64
+	err := opts.Where(s.db.Model(&models.SoldItems{})).
+		Joins("JOIN otop_products ON otop_products.id = sold_items.product_id AND otop_products.deleted_at IS NULL").
+		Select("COALESCE(SUM(sold_items.quantity_sold * otop_products.price), 0)").
+		Scan(&amount).Error
+	return amount, err
+}
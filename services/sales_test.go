@@ -0,0 +1,411 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m/audit"
+	"github.com/m/models"
+	"github.com/m/testutil"
+	"gorm.io/gorm"
+)
+
+var testActor = audit.Actor{ID: 7, Name: "till", Role: "cashier", Type: models.SubjectUser, IP: "10.0.0.1"}
+
+// stockedTill makes a supplier, a product for each quantity and a cashier
+// with an open shift.
+func stockedTill(t *testing.T, db *gorm.DB, quantities ...int64) (cashierID uint, products []models.OtopProducts) {
+	t.Helper()
+
+	supplier := models.Supplier{StoreName: "Albay Delicacies", Email: "albay@example.com"}
+	cashier := models.User{UserName: "till", Email: "till@example.com", Role: "cashier"}
+	for _, row := range []interface{}{&supplier, &cashier} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, quantity := range quantities {
+		product := models.OtopProducts{
+			Name:        []string{"Ube Jam", "Pili Nuts", "Abaca Bag"}[i],
+			Description: "test stock",
+			Price:       100,
+			Quantity:    quantity,
+			Category:    "Food",
+			SupplierID:  supplier.ID,
+			StoreName:   supplier.StoreName,
+		}
+		if err := db.Create(&product).Error; err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, product)
+	}
+	shift := models.Shift{CashierID: cashier.ID, Status: models.ShiftOpen, OpenedAt: time.Now()}
+	if err := db.Create(&shift).Error; err != nil {
+		t.Fatal(err)
+	}
+	return cashier.ID, products
+}
+
+func stockOf(t *testing.T, db *gorm.DB, id uint) int64 {
+	t.Helper()
+
+	var product models.OtopProducts
+	if err := db.First(&product, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product.Quantity
+}
+
+// On SQLite's single connection the tills' checkouts run one after another,
+// so this covers the stock check, not locking; see
+// TestConcurrentCheckoutsNeverOversell.
+func TestSerializedCheckoutsNeverOversell(t *testing.T) {
+	checkoutRace(t, testutil.NewDB(t))
+}
+
+// Only PostgreSQL runs the checkouts side by side with the row locks they
+// rely on.
+func TestConcurrentCheckoutsNeverOversell(t *testing.T) {
+	checkoutRace(t, testutil.NewPostgresDB(t))
+}
+
+// checkoutRace has 12 tills sell the last 5 jars at once and checks exactly 5
+// sales went through.
+func checkoutRace(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	cashierID, products := stockedTill(t, db, 5)
+	jam := products[0]
+	sales := NewSales(db, DefaultSalesRules())
+
+	const tills = 12
+	var wg sync.WaitGroup
+	errs := make([]error, tills)
+	for i := 0; i < tills; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = sales.Checkout(testActor, CheckoutInput{
+				CashierID: cashierID,
+				Items:     []CheckoutItem{{ProductID: jam.ID, Quantity: 1}},
+				Received:  100,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	sold := 0
+	for _, err := range errs {
+		var insufficient *InsufficientStockError
+		switch {
+		case err == nil:
+			sold++
+		case !errors.As(err, &insufficient):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if sold != 5 {
+		t.Errorf("sold %d jars, want 5", sold)
+	}
+	if got := stockOf(t, db, jam.ID); got != 0 {
+		t.Errorf("stock = %d, want 0", got)
+	}
+	var transactions, items int64
+	db.Model(&models.Transaction{}).Count(&transactions)
+	db.Model(&models.TransactionItem{}).Count(&items)
+	if transactions != 5 || items != 5 {
+		t.Errorf("transactions = %d, items = %d, want 5 of each", transactions, items)
+	}
+}
+
+func TestFailedCheckoutRollsBackEverything(t *testing.T) {
+	db := testutil.NewDB(t)
+	cashierID, products := stockedTill(t, db, 10, 1)
+	jam, nuts := products[0], products[1]
+
+	_, err := NewSales(db, DefaultSalesRules()).Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items: []CheckoutItem{
+			{ProductID: jam.ID, Quantity: 3},
+			{ProductID: nuts.ID, Quantity: 2},
+		},
+		Received: 500,
+	})
+	var insufficient *InsufficientStockError
+	if !errors.As(err, &insufficient) || insufficient.ProductID != nuts.ID || insufficient.Available != 1 {
+		t.Fatalf("err = %v, want insufficient stock for the nuts", err)
+	}
+
+	if got := stockOf(t, db, jam.ID); got != 10 {
+		t.Errorf("jam stock = %d, want 10 after the rollback", got)
+	}
+	var transactions, items, links int64
+	db.Model(&models.Transaction{}).Count(&transactions)
+	db.Model(&models.TransactionItem{}).Count(&items)
+	db.Model(&models.TransactionSupplier{}).Count(&links)
+	if transactions+items+links != 0 {
+		t.Errorf("left %d transactions, %d items, %d links behind", transactions, items, links)
+	}
+}
+
+func TestCheckoutIsAuditedInItsTransaction(t *testing.T) {
+	db := testutil.NewDB(t)
+	cashierID, products := stockedTill(t, db, 10)
+	sales := NewSales(db, DefaultSalesRules())
+	sale := CheckoutInput{CashierID: cashierID, Items: []CheckoutItem{{ProductID: products[0].ID, Quantity: 2}}, Received: 500}
+
+	result, err := sales.Checkout(testActor, sale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []models.AuditLog
+	db.Order("id").Find(&entries)
+	if len(entries) != 2 || entries[0].Entity != "otop_product" || entries[1].Entity != "transaction" ||
+		entries[1].EntityID != fmt.Sprint(result.Transaction.ID) || entries[1].ActorID != testActor.ID || entries[1].IP != testActor.IP {
+		t.Fatalf("audit entries = %+v", entries)
+	}
+
+	// Without an audit trail the sale does not go through
+	if err := db.Migrator().DropTable(&models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sales.Checkout(testActor, sale); err == nil {
+		t.Fatal("checkout succeeded without its audit entry")
+	}
+	if got := stockOf(t, db, products[0].ID); got != 8 {
+		t.Errorf("stock = %d, want 8: the unaudited sale was kept", got)
+	}
+	var transactions int64
+	db.Model(&models.Transaction{}).Count(&transactions)
+	if transactions != 1 {
+		t.Errorf("transactions = %d, want 1", transactions)
+	}
+}
+
+func amount(v float64) *float64 { return &v }
+
+func TestCheckoutPricesOnTheServer(t *testing.T) {
+	db := testutil.NewDB(t)
+	cashierID, products := stockedTill(t, db, 10)
+	jam := products[0]
+	sales := NewSales(db, DefaultSalesRules())
+
+	// A till that prices the jam at a peso is refused, and nothing is sold
+	_, err := sales.Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items:     []CheckoutItem{{ProductID: jam.ID, Quantity: 3, Price: amount(1), Total: amount(3)}},
+		Received:  3,
+		Total:     amount(3),
+	})
+	var mismatch *PriceMismatchError
+	if !errors.As(err, &mismatch) || len(mismatch.Mismatches) != 3 {
+		t.Fatalf("err = %v, want three mismatches", err)
+	}
+	if m := mismatch.Mismatches[0]; m.Field != "items[0].price" || m.Expected != 100 {
+		t.Errorf("first mismatch = %+v", m)
+	}
+	if got := stockOf(t, db, jam.ID); got != 10 {
+		t.Errorf("stock = %d after a refused sale", got)
+	}
+
+	// Paying too little for the server's total is short payment
+	_, err = sales.Checkout(testActor, CheckoutInput{CashierID: cashierID, Items: []CheckoutItem{{ProductID: jam.ID, Quantity: 3}}, Received: 299.99})
+	if !errors.Is(err, ErrInsufficientPayment) {
+		t.Fatalf("err = %v, want insufficient payment", err)
+	}
+
+	result, err := sales.Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items:     []CheckoutItem{{ProductID: jam.ID, Quantity: 3, Price: amount(100)}},
+		Received:  500,
+		Change:    amount(200),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := result.Transaction
+	if txn.Subtotal != 300 || txn.Total != 300 || txn.VAT != 32.14 || txn.Change != 200 {
+		t.Errorf("transaction = subtotal %v, total %v, vat %v, change %v", txn.Subtotal, txn.Total, txn.VAT, txn.Change)
+	}
+	if item := result.Items[0]; item.Price != 100 || item.Total != 300 {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestCheckoutAppliesStatutoryDiscountToEligibleItems(t *testing.T) {
+	db := testutil.NewDB(t)
+	cashierID, products := stockedTill(t, db, 10, 10)
+	jam, bag := products[0], products[1]
+	if err := db.Model(&bag).Update("category", "Non-Food").Error; err != nil {
+		t.Fatal(err)
+	}
+	sales := NewSales(db, SalesRules{DiscountCategories: []string{"Food"}})
+	senior := &DiscountClaim{Type: models.DiscountSenior, IDNumber: "SC-0042", Name: "Lola Basyang"}
+
+	// Food is discounted, so a bag alone cannot be
+	_, err := sales.Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items:     []CheckoutItem{{ProductID: bag.ID, Quantity: 1}},
+		Discount:  senior,
+		Received:  100,
+	})
+	if !errors.Is(err, ErrNothingDiscountable) {
+		t.Fatalf("err = %v, want nothing discountable", err)
+	}
+
+	// ₱300 of jam: ₱267.86 without VAT, less 20% (₱53.57). The ₱100 bag
+	// keeps its price and VAT.
+	result, err := sales.Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items: []CheckoutItem{
+			{ProductID: jam.ID, Quantity: 3},
+			{ProductID: bag.ID, Quantity: 1},
+		},
+		Discount: senior,
+		Received: 400,
+		Total:    amount(314.29),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := result.Transaction
+	if txn.Subtotal != 400 || txn.Discount != 53.57 || txn.Total != 314.29 || txn.VAT != 10.71 || txn.Change != 85.71 {
+		t.Errorf("transaction = subtotal %v, discount %v, total %v, vat %v, change %v", txn.Subtotal, txn.Discount, txn.Total, txn.VAT, txn.Change)
+	}
+	want := models.StatutoryDiscount{Type: "senior", IDNumber: "SC-0042", Name: "Lola Basyang", EligibleSales: 300, VATExemption: 32.14}
+	if txn.Statutory != want {
+		t.Errorf("statutory = %+v, want %+v", txn.Statutory, want)
+	}
+
+	register, err := NewReporting(db).DiscountRegister(time.Now(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(register.Entries) != 1 || register.Totals.NetSales != 214.29 || register.Entries[0].IDNumber != "SC-0042" {
+		t.Errorf("register = %+v", register)
+	}
+	if pwd, _ := NewReporting(db).DiscountRegister(time.Now(), models.DiscountPWD); len(pwd.Entries) != 0 {
+		t.Errorf("pwd register = %+v", pwd)
+	}
+}
+
+func TestCheckoutSplitsSalesByVATClass(t *testing.T) {
+	db := testutil.NewDB(t)
+	cashierID, products := stockedTill(t, db, 10, 10, 10)
+	jam, nuts, bag := products[0], products[1], products[2]
+	if err := db.Model(&nuts).Update("vat_class", models.VATExempt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&bag).Update("category", "Non-Food").Error; err != nil {
+		t.Fatal(err)
+	}
+	sales := NewSales(db, SalesRules{DiscountCategories: []string{"Food"}, ZeroRatedCategories: []string{"Non-Food"}})
+	cart := []CheckoutItem{{ProductID: jam.ID, Quantity: 1}, {ProductID: nuts.ID, Quantity: 1}, {ProductID: bag.ID, Quantity: 1}}
+
+	// Only the jam carries VAT; the nuts are exempt and the bag zero-rated
+	result, err := sales.Checkout(testActor, CheckoutInput{CashierID: cashierID, Items: cart, Received: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := result.Transaction
+	if txn.VatableSales != 89.29 || txn.VAT != 10.71 || txn.VATExemptSales != 100 || txn.ZeroRatedSales != 100 || txn.Total != 300 {
+		t.Errorf("transaction = vatable %v, vat %v, exempt %v, zero-rated %v, total %v", txn.VatableSales, txn.VAT, txn.VATExemptSales, txn.ZeroRatedSales, txn.Total)
+	}
+	for i, class := range []string{models.VATVatable, models.VATExempt, models.VATZeroRated} {
+		if item := result.Items[i]; item.VATClass != class {
+			t.Errorf("item %d = %+v, want %s", i, item, class)
+		}
+	}
+
+	// A senior's jam loses its VAT and is sold exempt. The exempt nuts have no
+	// VAT to lose, so 20% comes off the shelf price. The bag is not food.
+	result, err = sales.Checkout(testActor, CheckoutInput{
+		CashierID: cashierID,
+		Items:     cart,
+		Discount:  &DiscountClaim{Type: models.DiscountSenior, IDNumber: "SC-0042"},
+		Received:  300,
+		Total:     amount(251.43),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn = result.Transaction
+	if txn.VatableSales != 0 || txn.VAT != 0 || txn.VATExemptSales != 189.29 || txn.ZeroRatedSales != 100 || txn.Discount != 37.86 {
+		t.Errorf("transaction = vatable %v, vat %v, exempt %v, zero-rated %v, discount %v", txn.VatableSales, txn.VAT, txn.VATExemptSales, txn.ZeroRatedSales, txn.Discount)
+	}
+
+	summary, err := NewReporting(db).VATSummary("monthly", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VATBreakdown{VatableSales: 89.29, VAT: 10.71, VATExemptSales: 289.29, ZeroRatedSales: 200, Discount: 37.86, Total: 551.43}
+	if got := summary[time.Now().Month().String()]; got != want {
+		t.Errorf("this month = %+v, want %+v", got, want)
+	}
+
+	// Every line here is from one supplier, so theirs matches
+	month := time.Now().Month().String()
+	supplier, err := NewReporting(db).SupplierVATSummary("monthly", jam.SupplierID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := supplier[month]; got != want {
+		t.Errorf("supplier summary this month = %+v, want %+v", got, want)
+	}
+	if other, _ := NewReporting(db).SupplierVATSummary("monthly", jam.SupplierID+1, time.Now()); other[month] != (VATBreakdown{}) {
+		t.Errorf("another supplier's summary = %+v", other[month])
+	}
+}
+
+func TestRecordSoldItemsIsAllOrNothing(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, products := stockedTill(t, db, 4, 4)
+	jam, nuts := products[0], products[1]
+	sales := NewSales(db, DefaultSalesRules())
+
+	_, err := sales.RecordSoldItems(testActor, []models.SoldItems{
+		{ProductID: jam.ID, QuantitySold: 2},
+		{ProductID: nuts.ID + 100, QuantitySold: 1},
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want not found", err)
+	}
+	if got := stockOf(t, db, jam.ID); got != 4 {
+		t.Errorf("jam stock = %d, want 4 after the rollback", got)
+	}
+
+	results, err := sales.RecordSoldItems(testActor, []models.SoldItems{
+		{ProductID: nuts.ID, QuantitySold: 1},
+		{ProductID: jam.ID, QuantitySold: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Item.ProductID != nuts.ID || results[1].Stock.After.Quantity != 2 {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestSupplierSalesSummaryUsesSoldAmounts(t *testing.T) {
+	db := testutil.NewDB(t)
+	_, products := stockedTill(t, db, 5)
+	jam := products[0]
+
+	if _, err := NewSales(db, DefaultSalesRules()).RecordSoldItems(testActor, []models.SoldItems{
+		{ProductID: jam.ID, QuantitySold: 3},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := NewReporting(db).SupplierSalesSummary("monthly", jam.SupplierID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 at 100 each, counted once
+	if got := summary[time.Now().Month().String()]; got != 300 {
+		t.Errorf("this month = %v, want 300", got)
+	}
+}
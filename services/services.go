@@ -0,0 +1,33 @@
+// Package services holds the business rules behind the HTTP handlers. Each
+// domain is an interface so handlers can be exercised against fakes; the
+// GORM-backed implementations in this package are what the server runs.
+package services
+
+import "gorm.io/gorm"
+
+type Services struct {
+	Inventory Inventory
+	Sales     Sales
+	Orders    Orders
+	Suppliers Suppliers
+	Reporting Reporting
+	Catalog   Catalog
+	Shifts    Shifts
+	Users     Users
+	Sessions  Sessions
+}
+
+// New wires every service to db, pricing sales by rules.
+func New(db *gorm.DB, rules SalesRules) *Services {
+	return &Services{
+		Inventory: NewInventory(db),
+		Sales:     NewSales(db, rules),
+		Orders:    NewOrders(db),
+		Suppliers: NewSuppliers(db),
+		Reporting: NewReporting(db),
+		Catalog:   NewCatalog(db),
+		Shifts:    NewShifts(db),
+		Users:     NewUsers(db),
+		Sessions:  NewSessions(db),
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/m/models"
+	"github.com/m/utils"
+	"gorm.io/gorm"
+)
+
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// SessionTokens are handed to the client when a session starts or refreshes.
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// Sessions tracks signed-in devices. Access tokens carry the session ID, so
+// revoking a session signs that device out at once.
+type Sessions interface {
+	// Start records a new session for a user or supplier who just signed in.
+	Start(subjectType string, subjectID uint) (SessionTokens, error)
+	// Refresh swaps a refresh token for new tokens. Reusing a token that was
+	// already swapped revokes every session of its subject, and an account
+	// that is no longer active loses the session with ErrAccountInactive.
+	Refresh(refreshToken string) (SessionTokens, error)
+	// Revoke ends one session.
+	Revoke(sessionID string) error
+	// RevokeAll ends every session of a user or supplier.
+	RevokeAll(subjectType string, subjectID uint) error
+}
+
+type sessionsService struct {
+	db *gorm.DB
+}
+
+func NewSessions(db *gorm.DB) Sessions {
+	return &sessionsService{db: db}
+}
+
+// RevokeSubjectSessions ends every session of a user or supplier. Run it in
+// the transaction that disables, demotes or deletes the account.
+func RevokeSubjectSessions(db *gorm.DB, subjectType string, subjectID uint) error {
+	return revokeSessions(db.Where("subject_type = ? AND subject_id = ?", subjectType, subjectID))
+}
+
+func revokeSessions(scope *gorm.DB) error {
+	return scope.Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *sessionsService) Start(subjectType string, subjectID uint) (SessionTokens, error) {
+	sessionID, err := utils.RandomToken(24)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	session := models.Session{
+		ID:               sessionID,
+		SubjectType:      subjectType,
+		SubjectID:        subjectID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return SessionTokens{}, err
+	}
+
+	accessToken, err := s.accessTokenFor(session)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	return SessionTokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// accessTokenFor builds the access token from the subject's current row, so
+// role changes take effect on the next refresh. Disabled users and suppliers
+// that are not active get ErrAccountInactive instead.
+func (s *sessionsService) accessTokenFor(session models.Session) (string, error) {
+	switch session.SubjectType {
+	case models.SubjectSupplier:
+		var supplier models.Supplier
+		if err := s.db.First(&supplier, session.SubjectID).Error; err != nil {
+			return "", err
+		}
+		if supplier.Status != models.SupplierActive {
+			return "", ErrAccountInactive
+		}
+		return utils.GenerateToken(supplier.StoreName, "supplier", supplier.ID, supplier.ID, session.ID)
+	default:
+		var user models.User
+		if err := s.db.First(&user, session.SubjectID).Error; err != nil {
+			return "", err
+		}
+		if user.Disabled {
+			return "", ErrAccountInactive
+		}
+		return utils.GenerateToken(user.UserName, user.Role, user.ID, 0, session.ID)
+	}
+}
+
+func (s *sessionsService) Refresh(refreshToken string) (SessionTokens, error) {
+	session, newToken, err := s.rotate(refreshToken)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	accessToken, err := s.accessTokenFor(session)
+	if errors.Is(err, ErrAccountInactive) {
+		// The account was disabled after sign-in: end the session for good
+		if err := s.Revoke(session.ID); err != nil {
+			return SessionTokens{}, err
+		}
+		return SessionTokens{}, ErrAccountInactive
+	}
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	return SessionTokens{AccessToken: accessToken, RefreshToken: newToken}, nil
+}
+
+// rotate swaps a valid refresh token for a new one. Presenting a token that
+// was already rotated out means it leaked, so every session of its subject is
+// revoked.
+func (s *sessionsService) rotate(refreshToken string) (models.Session, string, error) {
+	var session models.Session
+	hash := utils.HashToken(refreshToken)
+
+	err := s.db.Where("refresh_token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if s.db.Where("previous_token_hash = ?", hash).First(&session).Error == nil {
+			if err := s.RevokeAll(session.SubjectType, session.SubjectID); err != nil {
+				return session, "", err
+			}
+		}
+		return session, "", ErrSessionInvalid
+	}
+	if err != nil {
+		return session, "", err
+	}
+	if !session.Active(time.Now()) {
+		return session, "", ErrSessionInvalid
+	}
+
+	newToken, err := utils.RandomToken(32)
+	if err != nil {
+		return session, "", err
+	}
+
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  utils.HashToken(newToken),
+			"previous_token_hash": hash,
+		})
+	if result.Error != nil {
+		return session, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another request rotated the same token first.
+		return session, "", ErrSessionInvalid
+	}
+
+	return session, newToken, nil
+}
+
+func (s *sessionsService) Revoke(sessionID string) error {
+	return revokeSessions(s.db.Where("id = ?", sessionID))
+}
+
+func (s *sessionsService) RevokeAll(subjectType string, subjectID uint) error {
+	return RevokeSubjectSessions(s.db, subjectType, subjectID)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+// ShiftChange is a shift before and after it was closed.
+type ShiftChange struct {
+	Before models.Shift
+	After  models.Shift
+}
+
+// ShiftTotals add up the closed shifts of a listing, across every page.
+type ShiftTotals struct {
+	ExpectedCash float64            `json:"expected_cash"`
+	CountedCash  float64            `json:"counted_cash"`
+	Variance     float64            `json:"variance"`
+	Tenders      map[string]float64 `json:"tenders" gorm:"-"`
+}
+
+// ShiftPage is one page of the shift history with the totals of every closed
+// shift that matches.
+type ShiftPage struct {
+	Data   []models.Shift `json:"data"`
+	Meta   listing.Meta   `json:"meta"`
+	Totals ShiftTotals    `json:"totals"`
+}
+
+// Shifts runs cashiers' shifts at the till, from the opening float to the
+// closing cash count.
+type Shifts interface {
+	// Open starts a shift for a cashier who has none open.
+	Open(actor audit.Actor, cashierID uint, openingCash float64) (models.Shift, error)
+	// Current is the cashier's open shift with its running totals.
+	Current(cashierID uint) (models.Shift, error)
+	// Close records the counted cash and the variance from the expected cash.
+	Close(actor audit.Actor, cashierID uint, countedCash float64, notes string) (ShiftChange, error)
+	List(opts listing.Options) (ShiftPage, error)
+}
+
+type shiftsService struct {
+	db *gorm.DB
+}
+
+func NewShifts(db *gorm.DB) Shifts {
+	return &shiftsService{db: db}
+}
+
+// ShiftListing is how the shift history can be filtered and sorted.
+var ShiftListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"cashier_id": {Column: "cashier_id", Kind: listing.Int},
+		"status":     {Column: "status"},
+	},
+	Sorts: map[string]string{
+		"id":        "id",
+		"opened_at": "opened_at",
+		"variance":  "variance",
+	},
+	DefaultSort: "-opened_at",
+	DateColumn:  "opened_at",
+}
+
+// shiftTenders sums what each shift's transactions took per tender type,
+// net of change. Every tender type is present, zero when unused.
+func shiftTenders(db *gorm.DB, shiftIDs interface{}) (map[uint]map[string]float64, error) {
+	var rows []struct {
+		ShiftID uint
+		Method  string
+		Amount  float64
+	}
+	err := db.Table("transaction_payments").
+		Select("transactions.shift_id, transaction_payments.method, COALESCE(SUM(transaction_payments.amount - transaction_payments.change), 0) AS amount").
+		Joins("JOIN transactions ON transactions.id = transaction_payments.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transactions.shift_id IN (?)", shiftIDs).
+		Group("transactions.shift_id, transaction_payments.method").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byShift := map[uint]map[string]float64{}
+	for _, row := range rows {
+		if byShift[row.ShiftID] == nil {
+			byShift[row.ShiftID] = emptyTenders()
+		}
+		byShift[row.ShiftID][row.Method] += row.Amount
+	}
+	return byShift, nil
+}
+
+func emptyTenders() map[string]float64 {
+	tenders := make(map[string]float64, len(models.Tenders))
+	for _, method := range models.Tenders {
+		tenders[method] = 0
+	}
+	return tenders
+}
+
+// tallyShift fills in the shift's takings by tender, cash sales, expected
+// cash and transaction count.
+func tallyShift(db *gorm.DB, shift *models.Shift) error {
+	byShift, err := shiftTenders(db, []uint{shift.ID})
+	if err != nil {
+		return err
+	}
+	var count int64
+	if err := db.Model(&models.Transaction{}).Where("shift_id = ?", shift.ID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	shift.Tenders = byShift[shift.ID]
+	if shift.Tenders == nil {
+		shift.Tenders = emptyTenders()
+	}
+	shift.CashSales = shift.Tenders[models.TenderCash]
+	shift.ExpectedCash = shift.OpeningCash + shift.CashSales
+	shift.TransactionCount = count
+	return nil
+}
+
+func (s *shiftsService) Open(actor audit.Actor, cashierID uint, openingCash float64) (models.Shift, error) {
+	if _, err := FindOpenShift(s.db, cashierID); err == nil {
+		return models.Shift{}, ErrShiftAlreadyOpen
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Shift{}, err
+	}
+
+	shift := models.Shift{
+		CashierID:   cashierID,
+		Status:      models.ShiftOpen,
+		OpenedAt:    time.Now(),
+		OpeningCash: openingCash,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// The check above can race another request; the partial unique index
+		// on open shifts has the final say
+		err := tx.Create(&shift).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrShiftAlreadyOpen
+		}
+		if err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "shift", shift.ID, nil, shift)
+	})
+	return shift, err
+}
+
+func (s *shiftsService) Current(cashierID uint) (models.Shift, error) {
+	shift, err := FindOpenShift(s.db, cashierID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return shift, ErrNoOpenShift
+	}
+	if err != nil {
+		return shift, err
+	}
+	return shift, tallyShift(s.db, &shift)
+}
+
+func (s *shiftsService) Close(actor audit.Actor, cashierID uint, countedCash float64, notes string) (ShiftChange, error) {
+	var change ShiftChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		shift, err := LockOpenShift(tx, cashierID, "UPDATE")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoOpenShift
+		}
+		if err != nil {
+			return err
+		}
+		change.Before = shift
+
+		// Only the cash drawer is counted; wallet and card takings are
+		// reported alongside it
+		if err := tallyShift(tx, &shift); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		shift.Status = models.ShiftClosed
+		shift.ClosedAt = &now
+		shift.CountedCash = countedCash
+		shift.Variance = shift.CountedCash - shift.ExpectedCash
+		shift.Notes = notes
+
+		if err := tx.Save(&shift).Error; err != nil {
+			return err
+		}
+		change.After = shift
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "shift", shift.ID, change.Before, change.After)
+	})
+	return change, err
+}
+
+func (s *shiftsService) List(opts listing.Options) (ShiftPage, error) {
+	shifts, err := listing.Find[models.Shift](s.db, opts, "Cashier")
+	if err != nil {
+		return ShiftPage{}, err
+	}
+	page := ShiftPage{Data: shifts.Data, Meta: shifts.Meta}
+
+	ids := make([]uint, 0, len(page.Data))
+	for _, shift := range page.Data {
+		ids = append(ids, shift.ID)
+	}
+	byShift, err := shiftTenders(s.db, ids)
+	if err != nil {
+		return page, err
+	}
+	for i := range page.Data {
+		page.Data[i].Tenders = byShift[page.Data[i].ID]
+		if page.Data[i].Tenders == nil {
+			page.Data[i].Tenders = emptyTenders()
+		}
+	}
+
+	closed := opts.Where(s.db.Model(&models.Shift{})).Where("status = ?", models.ShiftClosed)
+	err = closed.Session(&gorm.Session{}).
+		Select("COALESCE(SUM(expected_cash), 0) AS expected_cash, COALESCE(SUM(counted_cash), 0) AS counted_cash, COALESCE(SUM(variance), 0) AS variance").
+		Scan(&page.Totals).Error
+	if err != nil {
+		return page, err
+	}
+	closedTenders, err := shiftTenders(s.db, closed.Session(&gorm.Session{}).Select("id"))
+	if err != nil {
+		return page, err
+	}
+	page.Totals.Tenders = emptyTenders()
+	for _, tenders := range closedTenders {
+		for method, amount := range tenders {
+			page.Totals.Tenders[method] += amount
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+)
+
+// Suppliers manages supplier accounts and their store details. Invitations
+// and passwords are handled with the other account flows in controllers.
+type Suppliers interface {
+	List(opts listing.Options) (listing.Page[models.Supplier], error)
+	Get(id uint) (models.Supplier, error)
+	GetByStoreName(storeName string) (models.Supplier, error)
+	Create(actor audit.Actor, supplier *models.Supplier) error
+	Save(actor audit.Actor, supplier *models.Supplier) error
+	Delete(actor audit.Actor, id uint) (models.Supplier, error)
+	Count() (int64, error)
+}
+
+type suppliersService struct {
+	db *gorm.DB
+}
+
+func NewSuppliers(db *gorm.DB) Suppliers {
+	return &suppliersService{db: db}
+}
+
+// SupplierListing is how suppliers can be filtered and sorted.
+var SupplierListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"status":     {Column: "status"},
+		"store_name": {Column: "store_name"},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"store_name": "store_name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	DateColumn:  "created_at",
+}
+
+func (s *suppliersService) List(opts listing.Options) (listing.Page[models.Supplier], error) {
+	return listing.Find[models.Supplier](s.db, opts)
+}
+
+func (s *suppliersService) Get(id uint) (models.Supplier, error) {
+	var supplier models.Supplier
+	err := s.db.First(&supplier, id).Error
+	return supplier, notFound(err, "supplier", id)
+}
+
+func (s *suppliersService) GetByStoreName(storeName string) (models.Supplier, error) {
+	var supplier models.Supplier
+	err := s.db.Where("store_name = ?", storeName).First(&supplier).Error
+	return supplier, notFound(err, "supplier", storeName)
+}
+
+func (s *suppliersService) Create(actor audit.Actor, supplier *models.Supplier) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(supplier).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "supplier", supplier.ID, nil, supplier)
+	})
+}
+
+func (s *suppliersService) Save(actor audit.Actor, supplier *models.Supplier) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Supplier
+		if err := tx.First(&before, supplier.ID).Error; err != nil {
+			return notFound(err, "supplier", supplier.ID)
+		}
+		if err := tx.Save(supplier).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "supplier", supplier.ID, before, supplier)
+	})
+}
+
+func (s *suppliersService) Delete(actor audit.Actor, id uint) (models.Supplier, error) {
+	var supplier models.Supplier
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&supplier, id).Error; err != nil {
+			return notFound(err, "supplier", id)
+		}
+		if err := tx.Delete(&supplier).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionDelete, "supplier", supplier.ID, supplier, nil)
+	})
+	return supplier, err
+}
+
+func (s *suppliersService) Count() (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Supplier{}).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"github.com/m/audit"
+	"github.com/m/listing"
+	"github.com/m/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserChange is a staff account before and after a change.
+type UserChange struct {
+	Before models.User
+	After  models.User
+}
+
+// UserUpdate holds the account fields to change; nil or empty leaves one as
+// it is.
+type UserUpdate struct {
+	UserName *string
+	Email    *string
+	Role     *string
+}
+
+// Users manages staff accounts. An admin can never demote, disable or delete
+// the last active admin, and losing a role or access ends the account's
+// sessions.
+type Users interface {
+	List(opts listing.Options) (listing.Page[models.User], error)
+	Get(id uint) (models.User, error)
+	// Create adds an account whose Password is already hashed.
+	Create(actor audit.Actor, user models.User) (models.User, error)
+	Update(actor audit.Actor, id uint, update UserUpdate) (UserChange, error)
+	SetDisabled(actor audit.Actor, id uint, disabled bool) (UserChange, error)
+	Delete(actor audit.Actor, id uint) (models.User, error)
+}
+
+type usersService struct {
+	db *gorm.DB
+}
+
+func NewUsers(db *gorm.DB) Users {
+	return &usersService{db: db}
+}
+
+// UserListing is how staff accounts can be filtered and sorted.
+var UserListing = listing.Spec{
+	Filters: map[string]listing.Filter{
+		"role":     {Column: "role"},
+		"disabled": {Column: "disabled", Kind: listing.Bool},
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"username":   "user_name",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+	DefaultSort: "id",
+	DateColumn:  "created_at",
+}
+
+// ensureOtherActiveAdmin fails when userID is the last enabled admin, so an
+// admin cannot lock everyone out by demoting, disabling or deleting it. The
+// active admin rows stay locked until tx ends, so two admins disabling each
+// other at once cannot both pass.
+func ensureOtherActiveAdmin(tx *gorm.DB, userID uint) error {
+	var ids []uint
+	err := tx.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND disabled = ?", "admin", false).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id != userID {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+func (s *usersService) List(opts listing.Options) (listing.Page[models.User], error) {
+	return listing.Find[models.User](s.db, opts)
+}
+
+func (s *usersService) Get(id uint) (models.User, error) {
+	var user models.User
+	err := s.db.First(&user, id).Error
+	return user, notFound(err, "user", id)
+}
+
+func (s *usersService) Create(actor audit.Actor, user models.User) (models.User, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := CheckEmailAvailable(tx, user.Email, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionCreate, "user", user.ID, nil, user)
+	})
+	return user, err
+}
+
+func (s *usersService) Update(actor audit.Actor, id uint, update UserUpdate) (UserChange, error) {
+	var change UserChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return notFound(err, "user", id)
+		}
+		change.Before = user
+
+		if update.UserName != nil && *update.UserName != "" {
+			user.UserName = *update.UserName
+		}
+		if update.Email != nil && *update.Email != "" {
+			if err := CheckEmailAvailable(tx, *update.Email, user.ID); err != nil {
+				return err
+			}
+			user.Email = *update.Email
+		}
+		if update.Role != nil && *update.Role != user.Role {
+			if user.Role == "admin" && !user.Disabled {
+				if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+					return err
+				}
+			}
+			user.Role = *update.Role
+			if err := RevokeSubjectSessions(tx, models.SubjectUser, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		change.After = user
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "user", user.ID, change.Before, change.After)
+	})
+	return change, err
+}
+
+func (s *usersService) SetDisabled(actor audit.Actor, id uint, disabled bool) (UserChange, error) {
+	var change UserChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return notFound(err, "user", id)
+		}
+		change.Before = user
+
+		if disabled {
+			if user.Role == "admin" {
+				if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+					return err
+				}
+			}
+			if err := RevokeSubjectSessions(tx, models.SubjectUser, user.ID); err != nil {
+				return err
+			}
+		}
+
+		user.Disabled = disabled
+		if err := tx.Model(&user).Update("disabled", disabled).Error; err != nil {
+			return err
+		}
+		change.After = user
+		return audit.RecordTx(tx, actor, audit.ActionUpdate, "user", user.ID, change.Before, change.After)
+	})
+	return change, err
+}
+
+func (s *usersService) Delete(actor audit.Actor, id uint) (models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, id).Error; err != nil {
+			return notFound(err, "user", id)
+		}
+		if user.Role == "admin" && !user.Disabled {
+			if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := RevokeSubjectSessions(tx, models.SubjectUser, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return audit.RecordTx(tx, actor, audit.ActionDelete, "user", user.ID, user, nil)
+	})
+	return user, err
+}
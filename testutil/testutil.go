@@ -0,0 +1,119 @@
+// Package testutil sets up throwaway databases and authenticated requests for
+// handler and service tests.
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/m/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewDB opens an in-memory SQLite database with every model migrated.
+//
+// The database has a single connection, so statements from concurrent
+// goroutines queue for it and transactions run one after another.
+func NewDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// Each connection to :memory: is its own database, so keep exactly one
+	return setup(t, sqlite.Open(":memory:"), 1)
+}
+
+// NewFileDB is NewDB on a SQLite file in WAL mode with several connections,
+// so statements from concurrent goroutines really interleave. SQLite still
+// runs one write transaction at a time and has no row locks; use NewPostgresDB
+// to test those.
+func NewFileDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	return setup(t, sqlite.Open(path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)"), 8)
+}
+
+// NewPostgresDB is NewDB on PostgreSQL, in a schema from PostgresDSN. The
+// test is skipped when TEST_POSTGRES_DSN is not set.
+func NewPostgresDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	return setup(t, postgres.Open(PostgresDSN(t)), 8)
+}
+
+// PostgresDSN creates an empty schema on the PostgreSQL server named by the
+// TEST_POSTGRES_DSN environment variable, a key=value connection string such
+// as "host=localhost user=postgres dbname=otop_test", and returns a DSN that
+// uses it. The schema is dropped when the test ends, and the test is skipped
+// when the variable is not set.
+func PostgresDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return dsn + " search_path=" + schema
+}
+
+// setup opens dialector with at most conns connections and migrates every
+// model. The handle is closed when the test ends.
+func setup(t *testing.T, dialector gorm.Dialector, conns int) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("open %s: %v", dialector.Name(), err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("%s handle: %v", dialector.Name(), err)
+	}
+	sqlDB.SetMaxOpenConns(conns)
+
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatalf("migrate %s: %v", dialector.Name(), err)
+	}
+
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+// AsUser stands in for the JWT middleware: it stores the claims the real
+// middleware would for a signed-in user or supplier.
+func AsUser(id uint, role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := jwt.MapClaims{"sid": "test-session", "id": float64(id), "role": role}
+		c.Locals("user", &jwt.Token{Claims: claims, Valid: true})
+		if role == "supplier" {
+			c.Locals("supplier_id", id)
+		}
+		return c.Next()
+	}
+}
@@ -1,37 +1,49 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtKey = []byte("your_secret_key")
+// AccessTokenTTL is kept short because shared terminals rely on refresh
+// tokens, which can be revoked server-side, for longer sign-ins.
+const AccessTokenTTL = 15 * time.Minute
 
 type Claims struct {
 	UserName   string `json:"user_name"`
 	Role       string `json:"role"`
 	ID         uint   `json:"id"`
 	SupplierID uint   `json:"supplier_id"`
+	SessionID  string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(username string, role string, ID uint, supplierID uint) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateToken(username string, role string, ID uint, supplierID uint, sessionID string) (string, error) {
+	now := time.Now()
 
 	claims := &Claims{
 		UserName:   username,
 		Role:       role,
 		ID:         ID,
 		SupplierID: supplierID,
+		SessionID:  sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	if signingKeys == nil {
+		return "", errors.New("jwt signing keys are not configured")
+	}
+
+	key := signingKeys.active
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Sign)
 	if err != nil {
 		return "", err
 	}
@@ -55,11 +67,22 @@ func ParseToken(tokenStr string) (*jwt.Token, error) {
 	}
 
 	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the token's signing method is valid
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if signingKeys == nil {
+			return nil, errors.New("jwt signing keys are not configured")
+		}
+
+		// Look up the key named by the token so retiring keys still verify
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		// Ensure the token's signing method matches the key
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
 		}
 
-		return jwtKey, nil
+		return key.Verify, nil
 	})
 }
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is one entry of the JWT key set. Sign is nil for keys that may
+// only verify tokens, e.g. a retiring key or a public key held for another
+// service.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Sign   interface{}
+	Verify interface{}
+}
+
+type keySet struct {
+	active SigningKey
+	keys   map[string]SigningKey
+}
+
+var signingKeys *keySet
+
+// ConfigureSigningKeys installs the key set used by GenerateToken and
+// ParseToken. New tokens are signed with activeID; every key in the list is
+// accepted for verification so tokens signed before a rotation stay valid.
+func ConfigureSigningKeys(activeID string, list []SigningKey) error {
+	set := &keySet{keys: make(map[string]SigningKey, len(list))}
+	for _, key := range list {
+		if key.ID == "" {
+			return errors.New("jwt key without an id")
+		}
+		if _, exists := set.keys[key.ID]; exists {
+			return fmt.Errorf("duplicate jwt key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	active, ok := set.keys[activeID]
+	if !ok {
+		return fmt.Errorf("active jwt key %q is not in the key list", activeID)
+	}
+	if active.Sign == nil {
+		return fmt.Errorf("active jwt key %q cannot sign tokens", activeID)
+	}
+	set.active = active
+
+	signingKeys = set
+	return nil
+}
+
+// sampleSecrets are the HS256 secrets published in .env.example. Anyone with
+// the repository knows them.
+var sampleSecrets = []string{"dev-only-secret-change-me-0123456789"}
+
+// CheckProductionKeys refuses an active key that only belongs on a developer
+// machine: an HS256 secret from the sample configuration or one shorter than
+// 32 bytes.
+func CheckProductionKeys() error {
+	if signingKeys == nil {
+		return errors.New("jwt signing keys are not configured")
+	}
+	active := signingKeys.active
+	if active.Method != jwt.SigningMethodHS256 {
+		return nil
+	}
+	secret, _ := active.Sign.([]byte)
+	if len(secret) < 32 {
+		return fmt.Errorf("active jwt key %q: HS256 secret must be at least 32 bytes", active.ID)
+	}
+	for _, sample := range sampleSecrets {
+		if string(secret) == sample {
+			return fmt.Errorf("active jwt key %q uses the sample secret from .env.example", active.ID)
+		}
+	}
+	return nil
+}
+
+// LoadSigningKeys builds the key set from JWT_ACTIVE_KEY_ID and JWT_KEYS.
+// JWT_KEYS is a comma-separated list of kid=ALG:value entries, where value is
+// the secret for HS256 and a PEM file path for RS256 or EdDSA, e.g.
+//
+//	JWT_KEYS=2026-10=HS256:new-secret,2026-04=HS256:old-secret
+func LoadSigningKeys(activeID, spec string) error {
+	if activeID == "" {
+		return errors.New("JWT_ACTIVE_KEY_ID is not set")
+	}
+	if strings.TrimSpace(spec) == "" {
+		return errors.New("JWT_KEYS is not set")
+	}
+
+	var list []SigningKey
+	for _, entry := range strings.Split(spec, ",") {
+		key, err := parseKeyEntry(strings.TrimSpace(entry))
+		if err != nil {
+			return err
+		}
+		list = append(list, key)
+	}
+
+	return ConfigureSigningKeys(activeID, list)
+}
+
+func parseKeyEntry(entry string) (SigningKey, error) {
+	kid, rest, ok := strings.Cut(entry, "=")
+	if !ok {
+		return SigningKey{}, fmt.Errorf("jwt key %q must look like kid=ALG:value", entry)
+	}
+	alg, value, ok := strings.Cut(rest, ":")
+	if !ok || value == "" {
+		return SigningKey{}, fmt.Errorf("jwt key %q must look like kid=ALG:value", kid)
+	}
+
+	key := SigningKey{ID: kid}
+	switch strings.ToUpper(alg) {
+	case "HS256":
+		if len(value) < 32 {
+			return SigningKey{}, fmt.Errorf("jwt key %q: HS256 secret must be at least 32 characters", kid)
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.Sign = []byte(value)
+		key.Verify = []byte(value)
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if err := loadPEMKey(&key, value); err != nil {
+			return SigningKey{}, err
+		}
+	case "EDDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if err := loadPEMKey(&key, value); err != nil {
+			return SigningKey{}, err
+		}
+	default:
+		return SigningKey{}, fmt.Errorf("jwt key %q: unsupported algorithm %q", kid, alg)
+	}
+
+	return key, nil
+}
+
+// loadPEMKey accepts either a private key (sign and verify) or a public key
+// (verify only) and checks it matches the key's algorithm.
+func loadPEMKey(key *SigningKey, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("jwt key %q: %w", key.ID, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("jwt key %q: %s is not a PEM file", key.ID, path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("jwt key %q: unsupported PEM block %q", key.ID, block.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("jwt key %q: %w", key.ID, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Sign, key.Verify = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Verify = k
+	case ed25519.PrivateKey:
+		key.Sign, key.Verify = k, k.Public()
+	case ed25519.PublicKey:
+		key.Verify = k
+	default:
+		return fmt.Errorf("jwt key %q: unsupported key type %T", key.ID, parsed)
+	}
+
+	_, isRSA := key.Verify.(*rsa.PublicKey)
+	if (key.Method == jwt.SigningMethodRS256) != isRSA {
+		return fmt.Errorf("jwt key %q: key type does not match %s", key.ID, key.Method.Alg())
+	}
+	return nil
+}
+
+// PublicJWKS returns the asymmetric verification keys as a JSON Web Key Set
+// so other services can verify our tokens without the shared secret.
+func PublicJWKS() map[string]interface{} {
+	keys := []map[string]string{}
+	if signingKeys == nil {
+		return map[string]interface{}{"keys": keys}
+	}
+
+	for _, key := range signingKeys.keys {
+		switch pub := key.Verify.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": key.ID,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": key.ID,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
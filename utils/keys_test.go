@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testSecret  = "test-secret-0123456789-abcdefghijklmn"
+	otherSecret = "other-secret-0123456789-abcdefghijklm"
+)
+
+// useKeys installs a key set for one test and puts the previous one back.
+func useKeys(t *testing.T, activeID, spec string) {
+	t.Helper()
+
+	previous := signingKeys
+	t.Cleanup(func() { signingKeys = previous })
+	if err := LoadSigningKeys(activeID, spec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writePEM writes der as a PEM block of the given type and returns its path.
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func rsaKeyFiles(t *testing.T) (private, public string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		writePEM(t, "rsa.pub", "PUBLIC KEY", pub)
+}
+
+func ed25519KeyFile(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "ed.pem", "PRIVATE KEY", der)
+}
+
+func TestLoadSigningKeysRejectsBadEntries(t *testing.T) {
+	_, rsaPublic := rsaKeyFiles(t)
+	edPrivate := ed25519KeyFile(t)
+	notPEM := filepath.Join(t.TempDir(), "plain.txt")
+	os.WriteFile(notPEM, []byte("not a key"), 0o600)
+	certificate := writePEM(t, "cert.pem", "CERTIFICATE", []byte{1, 2, 3})
+	garbled := writePEM(t, "bad.pem", "PRIVATE KEY", []byte{1, 2, 3})
+
+	previous := signingKeys
+	t.Cleanup(func() { signingKeys = previous })
+
+	for name, tc := range map[string]struct {
+		activeID, spec, want string
+	}{
+		"no active id":        {"", "a=HS256:" + testSecret, "JWT_ACTIVE_KEY_ID"},
+		"no keys":             {"a", " ", "JWT_KEYS"},
+		"no kid":              {"a", "HS256:" + testSecret, "kid=ALG:value"},
+		"no value":            {"a", "a=HS256:", "kid=ALG:value"},
+		"short secret":        {"a", "a=HS256:short", "at least 32"},
+		"unknown algorithm":   {"a", "a=PS512:" + testSecret, "unsupported algorithm"},
+		"duplicate kid":       {"a", "a=HS256:" + testSecret + ",a=HS256:" + otherSecret, "duplicate"},
+		"active not listed":   {"b", "a=HS256:" + testSecret, "not in the key list"},
+		"active verify only":  {"a", "a=RS256:" + rsaPublic, "cannot sign"},
+		"missing file":        {"a", "a=RS256:" + filepath.Join(t.TempDir(), "nope.pem"), "no such file"},
+		"not pem":             {"a", "a=RS256:" + notPEM, "not a PEM file"},
+		"unsupported block":   {"a", "a=RS256:" + certificate, "unsupported PEM block"},
+		"garbled key":         {"a", "a=EdDSA:" + garbled, `jwt key "a"`},
+		"ed25519 under RS256": {"a", "a=RS256:" + edPrivate, "does not match RS256"},
+		"rsa under EdDSA":     {"a", "a=EdDSA:" + rsaPublic, "does not match EdDSA"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := LoadSigningKeys(tc.activeID, tc.spec)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTokenLooksUpTheKeyByKid(t *testing.T) {
+	useKeys(t, "old", "old=HS256:"+otherSecret)
+	oldToken, err := GenerateToken("ana", "admin", 1, 0, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a rotation the retiring key still verifies its tokens
+	useKeys(t, "new", "new=HS256:"+testSecret+",old=HS256:"+otherSecret)
+	newToken, err := GenerateToken("ana", "admin", 1, 0, "s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"old": oldToken, "new": "Bearer " + newToken} {
+		if parsed, err := ParseToken(token); err != nil || !parsed.Valid {
+			t.Errorf("%s token: %v", name, err)
+		}
+	}
+	if parsed, _ := jwt.Parse(newToken, nil); parsed == nil || parsed.Header["kid"] != "new" {
+		t.Errorf("new token is not signed with the active kid")
+	}
+
+	// Once the old key is dropped its tokens are refused
+	useKeys(t, "new", "new=HS256:"+testSecret)
+	if _, err := ParseToken(oldToken); err == nil || !strings.Contains(err.Error(), `unknown signing key "old"`) {
+		t.Errorf("err = %v, want unknown signing key", err)
+	}
+}
+
+func TestParseTokenPinsTheAlgorithmPerKey(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyFiles(t)
+	useKeys(t, "rsa", "rsa=RS256:"+rsaPrivate)
+	good, err := GenerateToken("ana", "admin", 1, 0, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(good); err != nil {
+		t.Fatalf("RS256 token: %v", err)
+	}
+
+	// An HS256 token keyed with the public key must not pass for the RSA kid
+	publicPEM, err := os.ReadFile(rsaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1, "role": "admin"})
+	forged.Header["kid"] = "rsa"
+	signed, err := forged.SignedString(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(signed); err == nil {
+		t.Error("HS256 token accepted under an RS256 key")
+	}
+}
+
+func TestPublicJWKSListsOnlyAsymmetricKeys(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+	edPrivate := ed25519KeyFile(t)
+	useKeys(t, "rsa", "rsa=RS256:"+rsaPrivate+",ed=EdDSA:"+edPrivate+",hs=HS256:"+testSecret)
+
+	keys, _ := PublicJWKS()["keys"].([]map[string]string)
+	byKid := map[string]map[string]string{}
+	for _, key := range keys {
+		byKid[key["kid"]] = key
+	}
+	if len(keys) != 2 || byKid["hs"] != nil {
+		t.Fatalf("keys = %v, want the RSA and Ed25519 keys only", keys)
+	}
+	if k := byKid["rsa"]; k["kty"] != "RSA" || k["alg"] != "RS256" || k["e"] != "AQAB" || k["n"] == "" {
+		t.Errorf("rsa key = %v", k)
+	}
+	if k := byKid["ed"]; k["kty"] != "OKP" || k["crv"] != "Ed25519" || k["alg"] != "EdDSA" || k["x"] == "" {
+		t.Errorf("ed25519 key = %v", k)
+	}
+}
+
+func TestCheckProductionKeys(t *testing.T) {
+	useKeys(t, "dev-1", "dev-1=HS256:"+sampleSecrets[0])
+	if err := CheckProductionKeys(); err == nil {
+		t.Error("sample secret accepted")
+	}
+
+	useKeys(t, "prod", "prod=HS256:"+testSecret)
+	if err := CheckProductionKeys(); err != nil {
+		t.Errorf("own secret: %v", err)
+	}
+
+	rsaPrivate, _ := rsaKeyFiles(t)
+	useKeys(t, "rsa", "rsa=RS256:"+rsaPrivate+",dev-1=HS256:"+sampleSecrets[0])
+	if err := CheckProductionKeys(); err != nil {
+		t.Errorf("RS256 active key: %v", err)
+	}
+}
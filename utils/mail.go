@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"gopkg.in/gomail.v2"
+
+	"github.com/m/config"
+)
+
+// Mailer sends plain-text email through the configured SMTP account.
+type Mailer struct {
+	cfg config.SMTPConfig
+}
+
+func NewMailer(cfg config.SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+func (m *Mailer) Send(to, subject, body string) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.cfg.From)
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/plain", body)
+
+	d := gomail.NewDialer(m.cfg.Host, m.cfg.Port, m.cfg.Username, m.cfg.Password)
+	return d.DialAndSend(msg)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt work factor for newly stored passwords. Raising
+// it makes existing hashes report needsRehash on their next successful login.
+const passwordCost = 12
+
+// MaxPasswordBytes is the longest password bcrypt accepts; anything longer
+// makes HashPassword fail.
+const MaxPasswordBytes = 72
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash reports whether stored already holds a bcrypt hash rather
+// than a legacy plaintext password: it must have a bcrypt prefix and parse
+// as a hash.
+func IsPasswordHash(stored string) bool {
+	if !strings.HasPrefix(stored, "$2a$") && !strings.HasPrefix(stored, "$2b$") && !strings.HasPrefix(stored, "$2y$") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// VerifyPassword checks password against the stored value. Legacy plaintext
+// rows are still accepted; needsRehash tells the caller to replace the stored
+// value with HashPassword(password) after a successful match.
+func VerifyPassword(stored, password string) (ok bool, needsRehash bool) {
+	if !IsPasswordHash(stored) {
+		ok = stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost != passwordCost
+}
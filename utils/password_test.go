@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPasswordHash(hash) || hash == "s3cret" {
+		t.Fatalf("hash = %q", hash)
+	}
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != passwordCost {
+		t.Errorf("cost = %d, want %d", cost, passwordCost)
+	}
+
+	again, _ := HashPassword("s3cret")
+	if again == hash {
+		t.Error("two hashes of the same password are equal; salt missing")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	current, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		stored, password string
+		ok, needsRehash  bool
+	}{
+		"hash matches":           {current, "s3cret", true, false},
+		"hash mismatch":          {current, "wrong", false, false},
+		"older cost matches":     {string(weak), "s3cret", true, true},
+		"older cost mismatch":    {string(weak), "wrong", false, false},
+		"plaintext matches":      {"s3cret", "s3cret", true, true},
+		"plaintext mismatch":     {"s3cret", "wrong", false, false},
+		"plaintext prefix":       {"s3cret", "s3c", false, false},
+		"empty stored":           {"", "", false, false},
+		"hash is not a password": {current, current, false, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ok, needsRehash := VerifyPassword(tc.stored, tc.password)
+			if ok != tc.ok || needsRehash != tc.needsRehash {
+				t.Errorf("VerifyPassword = %v, %v; want %v, %v", ok, needsRehash, tc.ok, tc.needsRehash)
+			}
+		})
+	}
+}
+
+func TestIsPasswordHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(hash[4:])
+
+	for stored, want := range map[string]bool{
+		"$2a$" + body:              true,
+		"$2b$" + body:              true,
+		"$2y$" + body:              true,
+		"$2a$12$abcdefghijklmnopq": false, // the prefix alone is not a hash
+		"$2b$xx$" + body[3:]:       false,
+		"$1$md5crypt":              false,
+		"password":                 false,
+		"":                         false,
+	} {
+		if got := IsPasswordHash(stored); got != want {
+			t.Errorf("IsPasswordHash(%q) = %v, want %v", stored, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package validation checks request DTOs against their `validate` struct tags
+// and reports every failing field as an apperr validation error.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/m/apperr"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names, which is what clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// maxbytes limits a string's length in bytes rather than characters,
+	// which is what bcrypt counts
+	v.RegisterValidation("maxbytes", func(fl validator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		return err == nil && len(fl.Field().String()) <= limit
+	})
+	return v
+}
+
+// Validate checks a struct, or each struct in a slice, and returns nil or an
+// *apperr.Error with one FieldError per failing field. An empty slice is an
+// error: a list payload needs at least one entry.
+func Validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Slice {
+		return fieldErrors(validate.Struct(v), "")
+	}
+
+	if value.Len() == 0 {
+		return apperr.Validation(apperr.FieldError{Field: "body", Message: "must contain at least one item"})
+	}
+	var fields []apperr.FieldError
+	for i := 0; i < value.Len(); i++ {
+		if err := fieldErrors(validate.Struct(value.Index(i).Interface()), fmt.Sprintf("[%d]", i)); err != nil {
+			fields = append(fields, err.(*apperr.Error).Fields...)
+		}
+	}
+	if len(fields) > 0 {
+		return apperr.Validation(fields...)
+	}
+	return nil
+}
+
+func fieldErrors(err error, prefix string) error {
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return apperr.Internal("Failed to validate request", err)
+	}
+
+	fields := make([]apperr.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, apperr.FieldError{
+			Field:   fieldPath(prefix, fe.Namespace()),
+			Message: message(fe),
+		})
+	}
+	return apperr.Validation(fields...)
+}
+
+// fieldPath drops the Go struct name that starts every namespace, so
+// "CheckoutRequest.items[0].quantity" becomes "items[0].quantity".
+func fieldPath(prefix, namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		namespace = namespace[i+1:]
+	}
+	if prefix == "" {
+		return namespace
+	}
+	return prefix + "." + namespace
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return "is required together with " + jsonName(fe.Param())
+	case "required_without":
+		return "is required when " + jsonName(fe.Param()) + " is not set"
+	case "required_unless":
+		field, value, _ := strings.Cut(fe.Param(), " ")
+		return "is required unless " + jsonName(field) + " is " + value
+	case "email":
+		return "must be a valid email address"
+	case "ip":
+		return "must be a valid IP address"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "min":
+		if isCollection(fe.Kind()) {
+			return "must have at least " + fe.Param() + " item(s)"
+		}
+		if fe.Kind() == reflect.String {
+			return "must be at least " + fe.Param() + " characters"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters"
+		}
+		return "must be at most " + fe.Param()
+	case "maxbytes":
+		return "must be at most " + fe.Param() + " bytes"
+	case "datetime":
+		layout := fe.Param()
+		if readable, ok := dateLayouts[layout]; ok {
+			layout = readable
+		}
+		return "must be a date in the format " + layout
+	default:
+		return "is invalid (" + fe.Tag() + ")"
+	}
+}
+
+// jsonName turns the Go field name in a cross-field tag parameter into the
+// snake_case name clients send, e.g. "EndDate" into "end_date".
+func jsonName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		upper := unicode.IsUpper(r)
+		if upper && i > 0 && !unicode.IsUpper(rune(field[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func isCollection(kind reflect.Kind) bool {
+	return kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+}
+
+// dateLayouts turns Go time layouts into something readable.
+var dateLayouts = map[string]string{
+	"2006-01-02": "YYYY-MM-DD",
+}
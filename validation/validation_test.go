@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/m/apperr"
+)
+
+type item struct {
+	Quantity int64 `json:"quantity" validate:"gt=0"`
+}
+
+type order struct {
+	Email     string `json:"email" validate:"required,email"`
+	Items     []item `json:"items" validate:"required,min=1,dive"`
+	StartDate string `json:"start_date" validate:"required_with=EndDate,omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"required_with=StartDate,omitempty,datetime=2006-01-02"`
+}
+
+func fields(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	appErr, ok := err.(*apperr.Error)
+	if !ok || appErr.Code != apperr.CodeValidation {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	got := map[string]string{}
+	for _, f := range appErr.Fields {
+		got[f.Field] = f.Message
+	}
+	return got
+}
+
+func TestValidatePasses(t *testing.T) {
+	err := Validate(&order{Email: "a@example.com", Items: []item{{Quantity: 1}}, StartDate: "2024-01-01", EndDate: "2024-01-31"})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestValidateReportsJSONFieldNames(t *testing.T) {
+	got := fields(t, Validate(&order{Email: "nope", Items: []item{{Quantity: 1}, {Quantity: -1}}, StartDate: "01/02/2024"}))
+
+	want := map[string]string{
+		"email":             "must be a valid email address",
+		"items[1].quantity": "must be greater than 0",
+		"start_date":        "must be a date in the format YYYY-MM-DD",
+		"end_date":          "is required together with start_date",
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("%s = %q, want %q", field, got[field], msg)
+		}
+	}
+}
+
+func TestValidateSlices(t *testing.T) {
+	got := fields(t, Validate(&[]item{}))
+	if _, ok := got["body"]; !ok {
+		t.Errorf("empty slice fields = %v, want body", got)
+	}
+
+	got = fields(t, Validate(&[]item{{Quantity: 2}, {Quantity: 0}}))
+	if got["[1].quantity"] != "must be greater than 0" {
+		t.Errorf("fields = %v", got)
+	}
+}
+
+func TestValidateMaxBytes(t *testing.T) {
+	type secret struct {
+		Password string `json:"password" validate:"maxbytes=4"`
+	}
+	if err := Validate(&secret{Password: "abcd"}); err != nil {
+		t.Errorf("4 bytes: err = %v", err)
+	}
+	// Three characters, six bytes
+	if got := fields(t, Validate(&secret{Password: "ééé"})); got["password"] != "must be at most 4 bytes" {
+		t.Errorf("fields = %v", got)
+	}
+}